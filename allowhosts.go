@@ -0,0 +1,93 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// defaultAllowedHosts are the hosts of the official feed and downloads, always allowed by -allowed-hosts.
+var defaultAllowedHosts = []string{"go.dev", "golang.org", "dl.google.com"}
+
+// hostAllowlist is a set of host names, without ports, that requests may be sent to.
+type hostAllowlist map[string]bool
+
+// newHostAllowlist returns an allowlist of defaultAllowedHosts, the hosts of the baseURLs that
+// are set, and hosts. Host names are compared case-insensitively.
+func newHostAllowlist(hosts []string, baseURLs ...string) (hostAllowlist, error) {
+	allowed := make(hostAllowlist)
+
+	for _, host := range defaultAllowedHosts {
+		allowed[host] = true
+	}
+
+	for _, baseURL := range baseURLs {
+		if baseURL == "" {
+			continue
+		}
+
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		allowed[strings.ToLower(u.Hostname())] = true
+	}
+
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			allowed[strings.ToLower(host)] = true
+		}
+	}
+
+	return allowed, nil
+}
+
+// check returns ErrHostNotAllowed unless the host of u is in a.
+func (a hostAllowlist) check(u *url.URL) error {
+	if !a[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+	}
+
+	return nil
+}
+
+// checkRedirect is an http.Client.CheckRedirect function that refuses redirects to hosts not in a,
+// and otherwise checks them like checkSecureRedirect.
+func (a hostAllowlist) checkRedirect(req *http.Request, via []*http.Request) error {
+	err := checkSecureRedirect(req, via)
+	if err != nil {
+		return err
+	}
+
+	return a.check(req.URL)
+}
+
+// allowlistTransport is an http.RoundTripper that refuses requests to hosts not in its allowlist.
+// Redirects are sent through the transport too, so they are checked even without checkRedirect.
+type allowlistTransport struct {
+	base  http.RoundTripper
+	hosts hostAllowlist
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *allowlistTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := t.hosts.check(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// restrictHosts limits client to the hosts in allowed, checking both requests and redirects.
+func restrictHosts(client *http.Client, allowed hostAllowlist) {
+	client.Transport = &allowlistTransport{base: client.Transport, hosts: allowed}
+	client.CheckRedirect = allowed.checkRedirect
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAllowedHostsRedirect(t *testing.T) {
+	// httptest servers use plain http
+	setAllowInsecure(t, true)
+
+	var targetRequests atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetRequests.Add(1)
+	}))
+	defer target.Close()
+
+	// Reach the target by a host name other than the allowed 127.0.0.1.
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, targetURL, http.StatusFound)
+		default:
+			http.Redirect(w, r, target.URL, http.StatusFound)
+		}
+	}))
+	defer redirector.Close()
+
+	allowed, err := newHostAllowlist([]string{"127.0.0.1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		url              string
+		checkRedirect    bool
+		expectedError    error
+		expectedRequests int32
+	}{
+		{name: "Allowed redirect", url: redirector.URL + "/stay", checkRedirect: true, expectedRequests: 1},
+		{name: "Disallowed redirect", url: redirector.URL + "/away", checkRedirect: true, expectedError: ErrHostNotAllowed},
+		{name: "Disallowed redirect, transport only", url: redirector.URL + "/away", expectedError: ErrHostNotAllowed},
+		{name: "Disallowed host", url: targetURL, checkRedirect: true, expectedError: ErrHostNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targetRequests.Store(0)
+
+			client := &http.Client{Transport: http.DefaultTransport}
+			restrictHosts(client, allowed)
+			if !tc.checkRedirect {
+				client.CheckRedirect = nil
+			}
+
+			resp, err := client.Get(tc.url)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got := targetRequests.Load(); got != tc.expectedRequests {
+				t.Errorf("Unexpected requests to target.\n Got: %d\nWant: %d", got, tc.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestNewHostAllowlist(t *testing.T) {
+	allowed, err := newHostAllowlist([]string{" Mirror.Example.com ", ""}, "https://cdn.example.net:8443/go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, want := range map[string]bool{
+		"go.dev":             true,
+		"dl.google.com":      true,
+		"mirror.example.com": true,
+		"cdn.example.net":    true,
+		"evil.example.com":   false,
+	} {
+		err := allowed.check(&url.URL{Scheme: "https", Host: host + ":443"})
+		if got := err == nil; got != want {
+			t.Errorf("Unexpected result for %s.\n Got: %v\nWant: %v", host, got, want)
+		}
+	}
+}
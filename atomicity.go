@@ -0,0 +1,72 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrRenameNotAtomic = errors.New("rename does not replace files atomically")
+
+// probeAtomicRename checks that rename in dir replaces an existing file in one step, as the
+// download relies on to never leave a partial file behind. It renames a tiny temporary file
+// over another, and returns ErrRenameNotAtomic if the rename fails or leaves either file in an
+// unexpected state, as it may on some FUSE and SMB mounts. The probe files are removed.
+func probeAtomicRename(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+
+	src, err := os.CreateTemp(dir, ".go-latest-probe-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(src.Name())
+
+	dst, err := os.CreateTemp(dir, ".go-latest-probe-*.tmp")
+	if err != nil {
+		src.Close()
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	_, err = src.WriteString("new")
+	if err == nil {
+		_, err = dst.WriteString("old")
+	}
+	src.Close()
+	dst.Close()
+	if err != nil {
+		return err
+	}
+
+	err = rename(src.Name(), dst.Name())
+	if err != nil {
+		return fmt.Errorf("%w in %s: %w", ErrRenameNotAtomic, dir, err)
+	}
+
+	data, err := os.ReadFile(dst.Name())
+	if err != nil || !bytes.Equal(data, []byte("new")) {
+		return fmt.Errorf("%w in %s: the replaced file does not have the new content", ErrRenameNotAtomic, dir)
+	}
+
+	if _, err := os.Lstat(src.Name()); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w in %s: the renamed file still exists", ErrRenameNotAtomic, dir)
+	}
+
+	return nil
+}
+
+// checkAtomicRename warns if the destination directory dir does not appear to support atomic
+// rename, see probeAtomicRename, so the download is not crash-safe. A directory that does not
+// exist or cannot be written is left for the download to report.
+func checkAtomicRename(dir string) {
+	err := probeAtomicRename(dir)
+	if errors.Is(err, ErrRenameNotAtomic) {
+		warn(WarnNonAtomic, "%v; an interrupted download may leave a partial file", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckAtomicRename(t *testing.T) {
+	testCases := []struct {
+		name            string
+		rename          func(oldpath, newpath string) error
+		expectedWarning bool
+	}{
+		{name: "Atomic", rename: os.Rename},
+		{
+			name: "Rename fails",
+			rename: func(oldpath, newpath string) error {
+				return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("operation not supported")}
+			},
+			expectedWarning: true,
+		},
+		{
+			name: "Rename does not replace",
+			rename: func(oldpath, newpath string) error {
+				// Succeed without replacing the destination, as a broken mount might.
+				return nil
+			},
+			expectedWarning: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := rename
+			rename = tc.rename
+			t.Cleanup(func() { rename = orig })
+
+			warnings := captureWarnings(t, true)
+			dir := t.TempDir()
+
+			err := probeAtomicRename(dir)
+			if tc.expectedWarning != errors.Is(err, ErrRenameNotAtomic) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedWarning)
+			}
+
+			checkAtomicRename(dir)
+
+			if got := strings.Contains(warnings.String(), `"code":"`+WarnNonAtomic+`"`); got != tc.expectedWarning {
+				t.Errorf("Unexpected warning.\n Got: %q\nWant warning: %v", warnings.String(), tc.expectedWarning)
+			}
+
+			// The probe files are cleaned up.
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("Unexpected files left: %v", entries)
+			}
+		})
+	}
+}
+
+func TestCheckAtomicRenameMissingDir(t *testing.T) {
+	warnings := captureWarnings(t, false)
+
+	checkAtomicRename(t.TempDir() + "/missing")
+
+	if warnings.Len() != 0 {
+		t.Errorf("Unexpected warning.\n Got: %q\nWant: none", warnings.String())
+	}
+}
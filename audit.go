@@ -0,0 +1,180 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Audit statuses reported by auditInstalled.
+const (
+	AuditOfficial = "official" // VERSION and binary agree on a release in the feed.
+	AuditModified = "modified" // VERSION and binary disagree.
+	AuditUnknown  = "unknown"  // The version is not a release in the feed.
+)
+
+var ErrNoGOROOT = errors.New("cannot determine GOROOT")
+
+// commandRunner runs a command and returns its standard output. Replaced in tests.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+// runCommand is the default commandRunner.
+func runCommand(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// findGOROOT returns $GOROOT, or the output of `go env GOROOT` if it is unset.
+func findGOROOT(run commandRunner) (string, error) {
+	if goroot := os.Getenv("GOROOT"); goroot != "" {
+		return goroot, nil
+	}
+
+	out, err := run("go", "env", "GOROOT")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoGOROOT, err)
+	}
+
+	goroot := strings.TrimSpace(string(out))
+	if goroot == "" {
+		return "", ErrNoGOROOT
+	}
+
+	return goroot, nil
+}
+
+// readVersionFile returns the version on the first line of goroot/VERSION, e.g. go1.21.5.
+func readVersionFile(goroot string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(goroot, "VERSION"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read VERSION: %w", err)
+	}
+
+	version := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if version == "" {
+		return "", errors.New("empty VERSION file")
+	}
+
+	return version, nil
+}
+
+// binaryVersion returns the version reported by goroot/bin/go, e.g. go1.21.5.
+func binaryVersion(goroot string, run commandRunner) (string, error) {
+	return goBinaryVersion(filepath.Join(goroot, "bin", "go"), run)
+}
+
+// goBinaryVersion returns the version reported by the go binary at path, e.g. go1.21.5.
+func goBinaryVersion(path string, run commandRunner) (string, error) {
+	out, err := run(path, "version")
+	if err != nil {
+		return "", fmt.Errorf("failed to run go version: %w", err)
+	}
+
+	// The output is "go version go1.21.5 linux/amd64".
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[0] != "go" || fields[1] != "version" {
+		return "", fmt.Errorf("unexpected go version output: %q", out)
+	}
+
+	return fields[2], nil
+}
+
+// installedVersion returns the version of the Go installed at path, which is either a GOROOT
+// directory or a go binary. For a GOROOT, the VERSION file is used if present and
+// bin/go is run otherwise.
+func installedVersion(path string, run commandRunner) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to find installed Go: %w", err)
+	}
+
+	if !info.IsDir() {
+		return goBinaryVersion(path, run)
+	}
+
+	version, err := readVersionFile(path)
+	if err == nil {
+		return version, nil
+	}
+
+	return binaryVersion(path, run)
+}
+
+// InstallAudit is the result of auditing a Go installation.
+type InstallAudit struct {
+	GOROOT        string
+	FileVersion   string // Version in the VERSION file.
+	BinaryVersion string // Version reported by bin/go.
+	Status        string // One of AuditOfficial, AuditModified, or AuditUnknown.
+}
+
+// auditInstalled checks that the installation at goroot reports the same version in its
+// VERSION file and its go binary, and that the version is a release in releaseInfo.
+// It does not hash the installed tree.
+func auditInstalled(goroot string, releaseInfo ReleaseInfo, run commandRunner) (InstallAudit, error) {
+	audit := InstallAudit{GOROOT: goroot}
+
+	var err error
+
+	audit.FileVersion, err = readVersionFile(goroot)
+	if err != nil {
+		return audit, err
+	}
+
+	audit.BinaryVersion, err = binaryVersion(goroot, run)
+	if err != nil {
+		return audit, err
+	}
+
+	switch {
+	case audit.FileVersion != audit.BinaryVersion:
+		audit.Status = AuditModified
+	case findReleaseErr(releaseInfo, audit.FileVersion) != nil:
+		audit.Status = AuditUnknown
+	default:
+		audit.Status = AuditOfficial
+	}
+
+	return audit, nil
+}
+
+// findReleaseErr returns the error from findRelease, discarding the release.
+func findReleaseErr(releaseInfo ReleaseInfo, version string) error {
+	_, err := findRelease(releaseInfo, version)
+	return err
+}
+
+// runAuditInstalled implements the -audit-installed mode and returns the exit code.
+func runAuditInstalled() int {
+	goroot, err := findGOROOT(runCommand)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitErrVerify
+	}
+
+	releaseInfo, err := loadReleaseInfo(allReleasesURL)
+	if err != nil {
+		fmt.Printf("Error getting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	audit, err := auditInstalled(goroot, releaseInfo, runCommand)
+	if err != nil {
+		fmt.Printf("Error auditing %s: %v\n", goroot, err)
+		return ExitErrVerify
+	}
+
+	fmt.Printf("GOROOT  %s\nVERSION %s\nbin/go  %s\nStatus  %s\n",
+		audit.GOROOT, audit.FileVersion, audit.BinaryVersion, audit.Status)
+
+	if audit.Status != AuditOfficial {
+		return ExitErrVerify
+	}
+
+	return 0
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditInstalled(t *testing.T) {
+	testCases := []struct {
+		name           string
+		versionFile    string
+		binaryOutput   string
+		expectedStatus string
+	}{
+		{
+			name:           "Official",
+			versionFile:    "go1.21.5\ntime 2023-11-29T21:21:53Z\n",
+			binaryOutput:   "go version go1.21.5 linux/amd64\n",
+			expectedStatus: AuditOfficial,
+		},
+		{
+			name:           "Modified",
+			versionFile:    "go1.21.5\n",
+			binaryOutput:   "go version devel go1.22-abc linux/amd64\n",
+			expectedStatus: AuditModified,
+		},
+		{
+			name:           "Unknown",
+			versionFile:    "go1.21.99",
+			binaryOutput:   "go version go1.21.99 linux/amd64\n",
+			expectedStatus: AuditUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			goroot := t.TempDir()
+			if err := os.WriteFile(filepath.Join(goroot, "VERSION"), []byte(tc.versionFile), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			run := func(name string, args ...string) ([]byte, error) {
+				if name != filepath.Join(goroot, "bin", "go") {
+					t.Errorf("Unexpected command %q", name)
+				}
+				return []byte(tc.binaryOutput), nil
+			}
+
+			audit, err := auditInstalled(goroot, testReleaseInfo, run)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if audit.Status != tc.expectedStatus {
+				t.Errorf("Unexpected status.\n Got: %q\nWant: %q", audit.Status, tc.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestInstalledVersion(t *testing.T) {
+	withVersionFile := t.TempDir()
+	if err := os.WriteFile(filepath.Join(withVersionFile, "VERSION"), []byte("go1.21.5\ntime 2023-11-29T21:21:53Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutVersionFile := t.TempDir()
+
+	binary := filepath.Join(t.TempDir(), "go")
+	if err := os.WriteFile(binary, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name string, args ...string) ([]byte, error) {
+		return []byte("go version go1.20.12 linux/amd64\n"), nil
+	}
+
+	testCases := []struct {
+		name      string
+		path      string
+		expected  string
+		expectErr bool
+	}{
+		{name: "GOROOT with VERSION", path: withVersionFile, expected: "go1.21.5"},
+		{name: "GOROOT without VERSION", path: withoutVersionFile, expected: "go1.20.12"},
+		{name: "go binary", path: binary, expected: "go1.20.12"},
+		{name: "Missing", path: filepath.Join(withoutVersionFile, "missing"), expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := installedVersion(tc.path, run)
+
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Unexpected version.\n Got: %q\nWant: %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// casPath returns the path of the file with the hex SHA256 checksum sum in the content-addressed
+// cache at cacheDir. The cache has the layout
+//
+//	<cacheDir>/<first two hex digits of sum>/<sum>
+//
+// so identical content downloaded under different names or for different output directories is
+// stored once, and no directory grows too large. Cached files are only written after they verify,
+// and can be shared by several users of the cache and removed at any time.
+func casPath(cacheDir, sum string) string {
+	sum = strings.ToLower(sum)
+
+	return filepath.Join(cacheDir, sum[:2], sum)
+}
+
+// downloadCached makes file available at path from the cache at opts.CacheDir, first downloading
+// it from fullURL into the cache unless a verified copy is already there. A download that fails
+// verification is removed, so the cache only holds verified content.
+func downloadCached(ctx context.Context, file ReleaseFile, fullURL, path string, opts DownloadOptions) (DownloadResult, error) {
+	cached := casPath(opts.CacheDir, file.SHA256)
+
+	if verified, err := VerifyFile(file, cached, VerifyOptions{}); err == nil {
+		result := DownloadResult{Path: path, Size: verified.Size, Checksum: verified.Checksum}
+
+		if len(opts.ExtraHashes) > 0 {
+			result.Digests, err = fileDigests(cached, append([]string{"sha256"}, opts.ExtraHashes...)...)
+			if err != nil {
+				return DownloadResult{}, err
+			}
+		}
+
+		return result, linkCached(cached, path)
+	}
+
+	err := os.MkdirAll(filepath.Dir(cached), 0o755)
+	if err != nil {
+		return DownloadResult{}, fmt.Errorf("download failed: %w", err)
+	}
+
+	// A cached file that did not verify is always replaced; path was checked by DownloadRelease.
+	cacheOpts := opts
+	cacheOpts.NoClobber = false
+
+	result, err := fetchRelease(ctx, file, fullURL, cached, cacheOpts)
+	if err != nil {
+		if result.Path != "" {
+			os.Remove(cached)
+		}
+		return DownloadResult{}, err
+	}
+
+	result.Path = path
+
+	return result, linkCached(cached, path)
+}
+
+// linkCached replaces path with a symbolic link to the absolute path of cached, or with a copy
+// of cached where symbolic links are not supported.
+func linkCached(cached, path string) error {
+	target, err := filepath.Abs(cached)
+	if err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d.link.tmp", path, os.Getpid())
+	os.Remove(tmp)
+
+	err = os.Symlink(target, tmp)
+	if err != nil {
+		err = copyToTemp(cached, tmp)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link cached file: %w", err)
+	}
+
+	err = rename(tmp, path)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to link cached file: %w", err)
+	}
+
+	return nil
+}
+
+// copyToTemp copies the file at src to a new file at dst.
+func copyToTemp(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownloadReleaseCache(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var requests atomic.Int32
+	files := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+		Size:     1,
+	}
+	cacheDir := t.TempDir()
+	cached := filepath.Join(cacheDir, "85", file.SHA256)
+
+	for i, outputDir := range []string{t.TempDir(), t.TempDir()} {
+		opts := DownloadOptions{BaseURL: server.URL, OutputDir: outputDir, CacheDir: cacheDir, Progress: ProgressNone}
+
+		result, err := DownloadRelease(context.Background(), file, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		path := filepath.Join(outputDir, file.Filename)
+		if result.Path != path {
+			t.Errorf("Unexpected path.\n Got: %q\nWant: %q", result.Path, path)
+		}
+
+		if err := VerifyLocalFile(path, file); err != nil {
+			t.Errorf("Unexpected error verifying %s: %v", path, err)
+		}
+
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Unexpected requests after download %d.\n Got: %d\nWant: 1", i+1, got)
+		}
+
+		// The second download is a cache hit, which reports the verified file too.
+		if result.Checksum != file.SHA256 || result.Size != file.Size {
+			t.Errorf("Unexpected result of download %d.\n Got: %s, %d\nWant: %s, %d",
+				i+1, result.Checksum, result.Size, file.SHA256, file.Size)
+		}
+
+		if i == 1 {
+			entries := checksumEntries(Results{{File: file, DownloadResult: result}})
+			want := []checksumEntry{{Checksum: file.SHA256, Filename: file.Filename}}
+			if !reflect.DeepEqual(entries, want) {
+				t.Errorf("Unexpected checksum entries.\n Got: %+v\nWant: %+v", entries, want)
+			}
+		}
+	}
+
+	if err := VerifyLocalFile(cached, file); err != nil {
+		t.Errorf("Unexpected error verifying cached file: %v", err)
+	}
+}
+
+func TestDownloadReleaseCacheMismatch(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "0000000000000000000000000000000000000000000000000000000000000000",
+		Size:     1,
+	}
+	cacheDir := t.TempDir()
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), CacheDir: cacheDir, Progress: ProgressNone}
+
+	_, err := DownloadRelease(context.Background(), file, opts)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrChecksumMismatch)
+	}
+
+	if _, err := os.Stat(casPath(cacheDir, file.SHA256)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unexpected cached file after mismatch: %v", err)
+	}
+}
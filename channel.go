@@ -0,0 +1,43 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Named channels for -channel.
+const (
+	ChannelStable = "stable" // The newest stable release.
+	ChannelLatest = "latest" // The newest release, including betas and release candidates.
+)
+
+var ErrInvalidChannel = errors.New("invalid channel")
+
+// applyChannel returns criteria changed to select from channel, which is one of:
+//
+//	stable  the newest stable release, the same as no channel
+//	latest  the newest release, stable or not, the same as -unstable
+//	1.21    the newest stable patch release of the go1.21 minor line, also written go1.21;
+//	        releases of other minor lines, newer or older, are never selected
+//
+// A channel does not override an exact criteria.Version.
+func applyChannel(criteria SelectCriteria, channel string) (SelectCriteria, error) {
+	switch channel {
+	case ChannelStable:
+		criteria.IncludeUnstable = false
+	case ChannelLatest:
+		criteria.IncludeUnstable = true
+	default:
+		line, err := parseMinorLine(channel)
+		if err != nil {
+			return criteria, fmt.Errorf("%w: %q, want %s, %s, or a minor line such as 1.21",
+				ErrInvalidChannel, channel, ChannelStable, ChannelLatest)
+		}
+		criteria.Line = line.MinorLine()
+	}
+
+	return criteria, nil
+}
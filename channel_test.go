@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyChannel(t *testing.T) {
+	var info ReleaseInfo
+	for _, r := range []struct {
+		version string
+		stable  bool
+	}{
+		{"go1.23rc1", false},
+		{"go1.22.3", true},
+		{"go1.22.2", true},
+		{"go1.21.9", true},
+		{"go1.21rc2", false},
+		{"go1.20.14", true},
+	} {
+		info = append(info, Release{
+			Version: r.version,
+			Stable:  r.stable,
+			Files: []ReleaseFile{
+				{Filename: r.version + ".linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: r.version, Kind: "archive"},
+			},
+		})
+	}
+
+	testCases := []struct {
+		channel         string
+		version         string
+		expectedVersion string
+		expectedError   error
+	}{
+		{channel: "stable", expectedVersion: "go1.22.3"},
+		{channel: "latest", expectedVersion: "go1.23rc1"},
+		{channel: "1.21", expectedVersion: "go1.21.9"},
+		{channel: "go1.20", expectedVersion: "go1.20.14"},
+		{channel: "1.23", expectedError: ErrNoMatchingFile},
+		{channel: "1.19", expectedError: ErrNoMatchingFile},
+		{channel: "1.21", version: "go1.22.2", expectedVersion: "go1.22.2"},
+		{channel: "nightly", expectedError: ErrInvalidChannel},
+		{channel: "1.21.5", expectedError: ErrInvalidChannel},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.channel+tc.version, func(t *testing.T) {
+			criteria, err := applyChannel(SelectCriteria{OS: "linux", Arch: "amd64", Version: tc.version}, tc.channel)
+
+			var file ReleaseFile
+			if err == nil {
+				file, err = SelectFile(info, criteria)
+			}
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Version != tc.expectedVersion {
+				t.Errorf("Unexpected version.\n Got: %q\nWant: %q", file.Version, tc.expectedVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidBlockHashes = errors.New("invalid block hashes")
+	ErrChunkMismatch      = errors.New("chunk checksum mismatch")
+)
+
+// blockHashesSuffix is appended to the URL of a file to get its block hashes sidecar.
+const blockHashesSuffix = ".blockhashes"
+
+// maxChunkAttempts is how many times a chunk is fetched before the download fails.
+const maxChunkAttempts = 3
+
+// BlockHashes are the SHA256 checksums of consecutive fixed-size chunks of a file, read from
+// a sidecar published next to the file with the .blockhashes suffix. The sidecar is text:
+//
+//	chunk-size 4194304
+//	<hex SHA256 of bytes 0 to 4194303>
+//	<hex SHA256 of bytes 4194304 to 8388607>
+//	...
+//
+// The first line gives the chunk size in bytes, followed by one line per chunk in order.
+// The last chunk may be shorter. Empty lines and lines starting with # are ignored.
+type BlockHashes struct {
+	ChunkSize int64
+	Sums      []string
+}
+
+// parseBlockHashes parses a block hashes sidecar.
+func parseBlockHashes(r io.Reader) (BlockHashes, error) {
+	var hashes BlockHashes
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hashes.ChunkSize == 0 {
+			size, ok := strings.CutPrefix(line, "chunk-size ")
+			n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+			if !ok || err != nil || n <= 0 {
+				return BlockHashes{}, fmt.Errorf("%w: want chunk-size, got %q", ErrInvalidBlockHashes, line)
+			}
+			hashes.ChunkSize = n
+			continue
+		}
+
+		if _, err := hex.DecodeString(line); err != nil || len(line) != 2*sha256.Size {
+			return BlockHashes{}, fmt.Errorf("%w: %q is not a SHA256 checksum", ErrInvalidBlockHashes, line)
+		}
+		hashes.Sums = append(hashes.Sums, strings.ToLower(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return BlockHashes{}, err
+	}
+
+	if hashes.ChunkSize == 0 {
+		return BlockHashes{}, fmt.Errorf("%w: missing chunk-size", ErrInvalidBlockHashes)
+	}
+
+	return hashes, nil
+}
+
+// fetchBlockHashes gets the block hashes sidecar of the file at url.
+// It returns nil without an error if the mirror does not publish one.
+func fetchBlockHashes(ctx context.Context, url string, opts DownloadOptions) (*BlockHashes, error) {
+	err := checkSecureURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+blockHashesSuffix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%q %s", url+blockHashesSuffix, http.StatusText(resp.StatusCode))
+	}
+
+	hashes, err := parseBlockHashes(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hashes, nil
+}
+
+// fetchChunk writes bytes start to start+n-1 of url, fetched with f, to w and to progress,
+// and returns the number of bytes written. A stalled chunk is aborted, see watchBody.
+func fetchChunk(ctx context.Context, f chunkFetcher, url string, start, n int64, w io.Writer,
+	progress *ProgressHashWriter, opts DownloadOptions,
+) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	content, err := f.FetchRange(ctx, url, start, start+n-1)
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	body, stop := opts.watchBody(content, cancel)
+	defer stop()
+
+	// Read one byte more than the chunk, so a longer response is caught by the chunk checksum.
+	return io.Copy(w, io.TeeReader(io.LimitReader(body, n+1), progress))
+}
+
+// canFetchChunks reports whether url is fetched by a chunkFetcher, so it can be downloaded
+// by downloadChunked.
+func canFetchChunks(url string, opts DownloadOptions) bool {
+	fetcher, err := opts.fetcher(url)
+	if err != nil {
+		return false
+	}
+
+	_, ok := fetcher.(chunkFetcher)
+	return ok
+}
+
+// downloadChunked downloads url to dest one chunk at a time with range requests, verifying each
+// chunk against hashes and fetching a corrupt chunk again, up to maxChunkAttempts times, so a
+// corrupt byte costs one chunk rather than the whole file. The fetcher of url must be a
+// chunkFetcher. Like downloadFile, it shows progress, aborts a stalled chunk, and streams each
+// chunk to its offset in a temporary file that is renamed to dest on success. It returns the
+// size and hex checksum of the whole file, computed with h as each verified chunk is read back.
+func downloadChunked(ctx context.Context, url, dest string, expectedSize int64, hashes BlockHashes, h hash.Hash, opts DownloadOptions) (size int64, checksum string, err error) {
+	chunks := (expectedSize + hashes.ChunkSize - 1) / hashes.ChunkSize
+	if int64(len(hashes.Sums)) != chunks {
+		return 0, "", fmt.Errorf("%w: %d checksums for %d chunks", ErrInvalidBlockHashes, len(hashes.Sums), chunks)
+	}
+
+	fmt.Printf("Downloading %q to %q in %d verified chunks\n", url, dest, chunks)
+
+	fetcher, err := opts.fetcher(url)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	cf, ok := fetcher.(chunkFetcher)
+	if !ok {
+		return 0, "", fmt.Errorf("%w: %q cannot be fetched in chunks", ErrDownloadFailed, url)
+	}
+
+	err = opts.checkClobber(dest)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	out, err := os.CreateTemp(opts.tempDir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	mode := opts.FileMode
+	if mode == 0 {
+		mode = 0o644
+	}
+
+	err = out.Chmod(mode)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	// The progress writer hashes each chunk as it arrives, to verify it against hashes.
+	chunkHash := sha256.New()
+	progress := NewProgressHashWriter(expectedSize, chunkHash)
+
+	err = progress.setProgressMode(opts.Progress, opts.ProgressInterval, os.Stderr)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	for i, want := range hashes.Sums {
+		start := int64(i) * hashes.ChunkSize
+		n := min(start+hashes.ChunkSize, expectedSize) - start
+
+		for attempt := 1; ; attempt++ {
+			chunkHash.Reset()
+			progress.Written = start
+
+			var written int64
+			written, err = fetchChunk(ctx, cf, url, start, n, io.NewOffsetWriter(out, start), progress, opts)
+			if err != nil {
+				return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+			}
+
+			if written == n && hex.EncodeToString(chunkHash.Sum(nil)) == want {
+				break
+			}
+
+			if attempt == maxChunkAttempts {
+				return 0, "", fmt.Errorf("%w: chunk %d (bytes %d-%d) after %d attempts",
+					ErrChunkMismatch, i, start, start+n-1, attempt)
+			}
+
+			fmt.Printf("Chunk %d is corrupt, fetching it again.\n", i)
+		}
+
+		// Hash the verified chunk for the whole file, reading it back rather than keeping it.
+		_, err = io.Copy(h, io.NewSectionReader(out, start, n))
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+		size += n
+	}
+
+	progress.Flush()
+	if opts.Progress == "" || opts.Progress == ProgressTerminal {
+		fmt.Println()
+	}
+
+	// A chunk that was too long and fetched again may have left bytes past the end.
+	err = out.Truncate(size)
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = opts.checkClobber(dest)
+	}
+	if err == nil {
+		err = moveFile(out.Name(), dest)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseBlockHashes(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+
+	testCases := []struct {
+		name          string
+		input         string
+		expected      BlockHashes
+		expectedError error
+	}{
+		{
+			name:     "Valid",
+			input:    "# sidecar\nchunk-size 4\n\n" + sum + "\n" + strings.ToUpper(sum) + "\n",
+			expected: BlockHashes{ChunkSize: 4, Sums: []string{sum, sum}},
+		},
+		{name: "Missing chunk size", input: sum + "\n", expectedError: ErrInvalidBlockHashes},
+		{name: "Empty", input: "", expectedError: ErrInvalidBlockHashes},
+		{name: "Zero chunk size", input: "chunk-size 0\n", expectedError: ErrInvalidBlockHashes},
+		{name: "Short checksum", input: "chunk-size 4\nabcd\n", expectedError: ErrInvalidBlockHashes},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseBlockHashes(strings.NewReader(tc.input))
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got.ChunkSize != tc.expected.ChunkSize || fmt.Sprint(got.Sums) != fmt.Sprint(tc.expected.Sums) {
+				t.Errorf("Unexpected hashes.\n Got: %+v\nWant: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+// chunkServer serves data as file with range support and its block hashes sidecar, if
+// sidecar is set. The first corruptions responses to the range of chunk corrupt have a byte flipped.
+type chunkServer struct {
+	data        []byte
+	chunkSize   int
+	sidecar     bool
+	corrupt     int
+	corruptions int
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/file"+blockHashesSuffix {
+		if !s.sidecar {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, "chunk-size %d\n", s.chunkSize)
+		for start := 0; start < len(s.data); start += s.chunkSize {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256(s.data[start:min(start+s.chunkSize, len(s.data))]))
+		}
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[r.Header.Get("Range")]++
+	n := s.requests[r.Header.Get("Range")]
+	s.mu.Unlock()
+
+	data := s.data
+	corruptRange := fmt.Sprintf("bytes=%d-%d", s.corrupt*s.chunkSize, (s.corrupt+1)*s.chunkSize-1)
+	if r.Header.Get("Range") == corruptRange && n <= s.corruptions {
+		data = bytes.Clone(s.data)
+		data[s.corrupt*s.chunkSize] ^= 0xff
+	}
+
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+}
+
+func TestDownloadReleaseChunkVerify(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	data := bytes.Repeat([]byte("0123456789"), 100) // 1000 bytes in 4 chunks of 256.
+	file := ReleaseFile{
+		Filename: "file",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   fmt.Sprintf("%x", sha256.Sum256(data)),
+		Size:     int64(len(data)),
+	}
+
+	testCases := []struct {
+		name             string
+		sidecar          bool
+		corruptions      int
+		expectedError    error
+		expectedRequests map[string]int
+	}{
+		{
+			name:    "Clean",
+			sidecar: true,
+			expectedRequests: map[string]int{
+				"bytes=0-255": 1, "bytes=256-511": 1, "bytes=512-767": 1, "bytes=768-999": 1,
+			},
+		},
+		{
+			name:        "Corrupt chunk fetched again",
+			sidecar:     true,
+			corruptions: 1,
+			expectedRequests: map[string]int{
+				"bytes=0-255": 1, "bytes=256-511": 2, "bytes=512-767": 1, "bytes=768-999": 1,
+			},
+		},
+		{
+			name:          "Corrupt chunk every time",
+			sidecar:       true,
+			corruptions:   maxChunkAttempts,
+			expectedError: ErrChunkMismatch,
+		},
+		{
+			name:             "No sidecar falls back to whole file",
+			expectedRequests: map[string]int{"": 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &chunkServer{
+				data: data, chunkSize: 256, sidecar: tc.sidecar,
+				corrupt: 1, corruptions: tc.corruptions, requests: map[string]int{},
+			}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone, ChunkVerify: true}
+
+			result, err := DownloadRelease(context.Background(), file, opts)
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if err != nil {
+				entries, _ := os.ReadDir(opts.OutputDir)
+				if len(entries) != 0 {
+					t.Errorf("Unexpected files left behind: %d entries", len(entries))
+				}
+				return
+			}
+
+			got, err := os.ReadFile(result.Path)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("Unexpected file content: %v", err)
+			}
+
+			if fmt.Sprint(handler.requests) != fmt.Sprint(tc.expectedRequests) {
+				t.Errorf("Unexpected requests.\n Got: %v\nWant: %v", handler.requests, tc.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestDownloadReleaseChunkVerifyDigests(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	file := ReleaseFile{Filename: "file", OS: "linux", Arch: "amd64", SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Size: int64(len(data))}
+
+	server := httptest.NewServer(&chunkServer{data: data, chunkSize: 256, sidecar: true, requests: map[string]int{}})
+	defer server.Close()
+
+	opts := DownloadOptions{
+		BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone,
+		ChunkVerify: true, ExtraHashes: []string{"sha512"},
+	}
+
+	result, err := DownloadRelease(context.Background(), file, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("%x", sha512.Sum512(data))
+	if result.Digests["sha512"] != want || result.Digests["sha256"] != file.SHA256 {
+		t.Errorf("Unexpected digests.\n Got: %v\nWant: sha256 %s, sha512 %s", result.Digests, file.SHA256, want)
+	}
+}
+
+func TestDownloadReleaseChunkVerifyStall(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	file := ReleaseFile{Filename: "file", OS: "linux", Arch: "amd64", SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Size: int64(len(data))}
+
+	clock := newFakeClock()
+	watching := make(chan struct{}, 8)
+
+	chunks := &chunkServer{data: data, chunkSize: 256, sidecar: true, requests: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=256-511" {
+			chunks.ServeHTTP(w, r)
+			return
+		}
+
+		// Chunks are fetched in turn, so the watchdogs of earlier requests already started.
+		for len(watching) > 0 {
+			<-watching
+		}
+
+		w.Header().Set("Content-Range", "bytes 256-511/1000")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[256:300])
+		w.(http.Flusher).Flush()
+
+		// Stop sending without closing the connection, and let the timeout pass.
+		<-watching
+		clock.Advance(time.Minute)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	opts := DownloadOptions{
+		BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone,
+		ChunkVerify: true, StallTimeout: time.Minute,
+	}
+	opts.afterFunc = func(d time.Duration, f func()) stallTimer {
+		timer := clock.AfterFunc(d, f)
+		watching <- struct{}{}
+
+		return timer
+	}
+
+	_, err := DownloadRelease(context.Background(), file, opts)
+	if !errors.Is(err, ErrStalled) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrStalled)
+	}
+}
+
+func TestDownloadReleaseChunkVerifyFetcher(t *testing.T) {
+	data := []byte("contents of file")
+	registerTestFetcher(t, "mem", memFetcher{"mem://store/file": data})
+
+	file := ReleaseFile{Filename: "file", OS: "linux", Arch: "amd64", SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Size: int64(len(data))}
+
+	// A fetcher without range requests downloads the whole file, without asking for a sidecar.
+	opts := DownloadOptions{BaseURL: "mem://store", OutputDir: t.TempDir(), Progress: ProgressNone, ChunkVerify: true}
+
+	result, err := DownloadRelease(context.Background(), file, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.Checksum != file.SHA256 {
+		t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", result.Checksum, file.SHA256)
+	}
+}
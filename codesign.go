@@ -0,0 +1,55 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrCodesignUnsupported = errors.New("code signature verification is only supported for .pkg on darwin and .msi on windows")
+	ErrCodesignInvalid     = errors.New("invalid code signature")
+)
+
+// goSigner is the organization that signs the official installers.
+const goSigner = "Google LLC"
+
+// codesignCommand returns the command that verifies the code signature of the installer at path
+// on goos and prints its signer: pkgutil for a .pkg on darwin, and signtool with the default
+// Authenticode policy for a .msi on windows.
+func codesignCommand(goos, path string) (string, []string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	switch {
+	case goos == "darwin" && ext == ".pkg":
+		return "pkgutil", []string{"--check-signature", path}, nil
+	case goos == "windows" && ext == ".msi":
+		return "signtool", []string{"verify", "/pa", "/v", path}, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: %s on %s", ErrCodesignUnsupported, filepath.Base(path), goos)
+}
+
+// verifyCodeSignature checks with the tools of goos that the installer at path has a valid
+// code signature that is trusted by the system and names goSigner as the signer.
+func verifyCodeSignature(goos, path string, run commandRunner) error {
+	name, args, err := codesignCommand(goos, path)
+	if err != nil {
+		return err
+	}
+
+	out, err := run(name, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrCodesignInvalid, name, err)
+	}
+
+	if !strings.Contains(string(out), goSigner) {
+		return fmt.Errorf("%w: %s is not signed by %s", ErrCodesignInvalid, filepath.Base(path), goSigner)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCodesignCommand(t *testing.T) {
+	testCases := []struct {
+		name          string
+		goos          string
+		path          string
+		expectedName  string
+		expectedArgs  []string
+		expectedError error
+	}{
+		{
+			name:         "darwin pkg",
+			goos:         "darwin",
+			path:         "go1.21.5.darwin-arm64.pkg",
+			expectedName: "pkgutil",
+			expectedArgs: []string{"--check-signature", "go1.21.5.darwin-arm64.pkg"},
+		},
+		{
+			name:         "windows msi",
+			goos:         "windows",
+			path:         `C:\dl\go1.21.5.windows-amd64.MSI`,
+			expectedName: "signtool",
+			expectedArgs: []string{"verify", "/pa", "/v", `C:\dl\go1.21.5.windows-amd64.MSI`},
+		},
+		{name: "darwin archive", goos: "darwin", path: "go1.21.5.darwin-arm64.tar.gz", expectedError: ErrCodesignUnsupported},
+		{name: "msi on darwin", goos: "darwin", path: "go1.21.5.windows-amd64.msi", expectedError: ErrCodesignUnsupported},
+		{name: "linux", goos: "linux", path: "go1.21.5.linux-amd64.tar.gz", expectedError: ErrCodesignUnsupported},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, args, err := codesignCommand(tc.goos, tc.path)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if name != tc.expectedName || !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Errorf("Unexpected command.\n Got: %s %q\nWant: %s %q", name, args, tc.expectedName, tc.expectedArgs)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeSignature(t *testing.T) {
+	const path = "go1.21.5.darwin-arm64.pkg"
+
+	testCases := []struct {
+		name          string
+		output        string
+		err           error
+		expectedError error
+	}{
+		{
+			name:   "Signed by Google",
+			output: "Status: signed by a developer certificate issued by Apple for distribution\n   1. Developer ID Installer: Google LLC (EQHXZ8M8AV)\n",
+		},
+		{
+			name:          "Other signer",
+			output:        "Status: signed by a developer certificate issued by Apple for distribution\n   1. Developer ID Installer: Example Inc (XXXXXXXXXX)\n",
+			expectedError: ErrCodesignInvalid,
+		},
+		{
+			name:          "Unsigned",
+			output:        "Status: no signature\n",
+			err:           errors.New("exit status 1"),
+			expectedError: ErrCodesignInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := func(name string, args ...string) ([]byte, error) {
+				return []byte(tc.output), tc.err
+			}
+
+			err := verifyCodeSignature("darwin", path, run)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var ErrStaleFile = errors.New("file is not the latest release")
+
+// archiveExtensions are the extensions of Go release files, longest first.
+var archiveExtensions = []string{".tar.gz", ".tar.xz", ".zip", ".msi", ".pkg"}
+
+// fileExtension returns the release file extension of name, or "" if it has none.
+func fileExtension(name string) string {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+
+	return ""
+}
+
+// platformFromFilename returns the platform of a release file named like go1.21.5.linux-amd64.tar.gz.
+// It reports false if name does not follow that pattern.
+func platformFromFilename(name string) (Platform, bool) {
+	ext := fileExtension(name)
+	if ext == "" || !strings.HasPrefix(name, "go") {
+		return Platform{}, false
+	}
+
+	stem := strings.TrimSuffix(name, ext)
+	goos, goarch, ok := strings.Cut(stem[strings.LastIndex(stem, ".")+1:], "-")
+	if !ok || goos == "" || goarch == "" {
+		return Platform{}, false
+	}
+
+	return Platform{OS: goos, Arch: goarch}, true
+}
+
+// compareLatest verifies that the local file at path is bit-for-bit the file of the latest release
+// for its platform, taken from criteria.OS and criteria.Arch. The latest release is chosen as by
+// SelectFile, and its file with the same extension as path is expected. It returns the expected file.
+//
+// A file that does not match is reported with ErrStaleFile if it is named differently than
+// the latest file, such as the archive of an older release, and otherwise with the verification error.
+func compareLatest(path string, info ReleaseInfo, criteria SelectCriteria) (ReleaseFile, error) {
+	release, err := selectRelease(info, criteria)
+	if err != nil {
+		return ReleaseFile{}, err
+	}
+
+	name := filepath.Base(path)
+	ext := fileExtension(name)
+
+	var latest ReleaseFile
+	for _, file := range release.Files {
+		if file.OS == criteria.OS && file.Arch == criteria.Arch && ext != "" && fileExtension(file.Filename) == ext {
+			latest = file
+			break
+		}
+	}
+
+	if latest.Filename == "" {
+		return ReleaseFile{}, fmt.Errorf("%w: no %s file for %s/%s in %s",
+			ErrNoMatchingFile, ext, criteria.OS, criteria.Arch, release.Version)
+	}
+
+	err = VerifyLocalFile(path, latest)
+	if err != nil && name != latest.Filename {
+		return latest, fmt.Errorf("%w: %s is not %s: %w", ErrStaleFile, name, latest.Filename, err)
+	}
+
+	return latest, err
+}
+
+// runCompare implements the -compare mode and returns the exit code.
+// Unless platformSet, the platform in criteria is replaced by the one in the filename of path.
+func runCompare(path, feedURL string, criteria SelectCriteria, platformSet bool) int {
+	if !platformSet {
+		platform, ok := platformFromFilename(filepath.Base(path))
+		if !ok {
+			fmt.Printf("Cannot infer the platform of %q, use -os and -arch.\n", path)
+			return ExitErrUsage
+		}
+		criteria.OS, criteria.Arch = platform.OS, platform.Arch
+	}
+
+	releaseInfo, err := loadReleaseInfo(feedURL)
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	latest, err := compareLatest(path, releaseInfo, criteria)
+	if err != nil {
+		fmt.Printf("MISMATCH %s: %v\n", path, err)
+		return ExitErrVerify
+	}
+
+	fmt.Printf("OK       %s is %s\n", path, latest.Filename)
+
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlatformFromFilename(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected Platform
+		ok       bool
+	}{
+		{name: "go1.21.5.linux-amd64.tar.gz", expected: Platform{"linux", "amd64"}, ok: true},
+		{name: "go1.21.5.linux-armv6l.tar.gz", expected: Platform{"linux", "armv6l"}, ok: true},
+		{name: "go1.22rc2.windows-arm64.msi", expected: Platform{"windows", "arm64"}, ok: true},
+		{name: "go1.21.5.darwin-arm64.pkg", expected: Platform{"darwin", "arm64"}, ok: true},
+		{name: "go1.21.5.src.tar.gz", ok: false},
+		{name: "go1.21.5.linux-amd64.txt", ok: false},
+		{name: "archive.tar.gz", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := platformFromFilename(tc.name)
+
+			if got != tc.expected || ok != tc.ok {
+				t.Errorf("Unexpected platform.\n Got: %v, %v\nWant: %v, %v", got, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestCompareLatest(t *testing.T) {
+	latest := []byte("go1.21.5 archive")
+	stale := []byte("go1.21.4 archive")
+
+	info := ReleaseInfo{
+		{
+			Version: "go1.21.5",
+			Stable:  true,
+			Files: []ReleaseFile{
+				{
+					Filename: "go1.21.5.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Kind: "archive",
+					SHA256: fmt.Sprintf("%x", sha256.Sum256(latest)), Size: int64(len(latest)),
+				},
+			},
+		},
+		{
+			Version: "go1.21.4",
+			Stable:  true,
+			Files: []ReleaseFile{
+				{
+					Filename: "go1.21.4.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Kind: "archive",
+					SHA256: fmt.Sprintf("%x", sha256.Sum256(stale)), Size: int64(len(stale)),
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		filename      string
+		data          []byte
+		expectedError error
+	}{
+		{name: "Latest", filename: "go1.21.5.linux-amd64.tar.gz", data: latest},
+		{name: "Latest renamed", filename: "go.linux-amd64.tar.gz", data: latest},
+		{name: "Stale", filename: "go1.21.4.linux-amd64.tar.gz", data: stale, expectedError: ErrStaleFile},
+		{name: "Corrupted", filename: "go1.21.5.linux-amd64.tar.gz", data: stale, expectedError: ErrChecksumMismatch},
+		{name: "No file with extension", filename: "go1.21.5.linux-amd64.zip", data: latest, expectedError: ErrNoMatchingFile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.filename)
+			if err := os.WriteFile(path, tc.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := compareLatest(path, info, SelectCriteria{OS: "linux", Arch: "amd64"})
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const programName = "go-latest-version"
+
+var ErrUnsupportedShell = errors.New("unsupported shell")
+
+// subcommands are the positional commands accepted after the flags.
+var subcommands = []string{"completion"}
+
+// completionValues lists the values offered for flags that take an enumerable value.
+var completionValues = map[string][]string{
+	"os": {
+		"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+		"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+	},
+	"arch": {
+		"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle",
+		"ppc64", "ppc64le", "riscv64", "s390x", "wasm",
+	},
+	"allowed-kinds":  {"archive", "installer", "source"},
+	"kind":           {"archive", "installer", "source"},
+	"progress":       {ProgressTerminal, ProgressJSONL, ProgressNone},
+	"hash-algo":      {"sha1", "sha256", "sha512"},
+	"size-check":     {SizeCheckError, SizeCheckWarn, SizeCheckOff},
+	"install-layout": {LayoutGOROOT, LayoutAsdf, LayoutGVM},
+	"channel":        {ChannelStable, ChannelLatest},
+}
+
+// completionShells lists the shells that writeCompletion supports.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// isBoolFlag reports whether f takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// writeCompletion writes a completion script for shell covering the flags in fs.
+func writeCompletion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("%w: %q, want one of %v", ErrUnsupportedShell, shell, completionShells)
+	}
+
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags []*flag.Flag) {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+
+	fmt.Fprintf(w, "# bash completion for %s\n", programName)
+	fmt.Fprintln(w, "_go_latest_version() {")
+	fmt.Fprintln(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	fmt.Fprintln(w, "\tcase \"$prev\" in")
+	for _, f := range flags {
+		if values, ok := completionValues[f.Name]; ok {
+			fmt.Fprintf(w, "\t-%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n",
+				f.Name, f.Name, strings.Join(values, " "))
+		}
+	}
+	fmt.Fprintf(w, "\tcompletion) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n",
+		strings.Join(completionShells, " "))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tif [[ \"$cur\" == -* ]]; then")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -o default -F _go_latest_version %s\n", programName)
+}
+
+// zshQuote escapes characters that are special inside an _arguments description.
+var zshQuote = strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:")
+
+func writeZshCompletion(w io.Writer, flags []*flag.Flag) {
+	fmt.Fprintf(w, "#compdef %s\n\n", programName)
+	fmt.Fprintln(w, "_arguments \\")
+	for _, f := range flags {
+		spec := fmt.Sprintf("-%s[%s]", f.Name, zshQuote.Replace(f.Usage))
+		if !isBoolFlag(f) {
+			action := "_files"
+			if values, ok := completionValues[f.Name]; ok {
+				action = "(" + strings.Join(values, " ") + ")"
+			}
+			spec += ":" + f.Name + ":" + action
+		}
+		fmt.Fprintf(w, "\t'%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "\t'1:command:(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "\t'2:shell:(%s)'\n", strings.Join(completionShells, " "))
+}
+
+func writeFishCompletion(w io.Writer, flags []*flag.Flag) {
+	quote := strings.NewReplacer("'", "\\'")
+
+	fmt.Fprintf(w, "# fish completion for %s\n", programName)
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -o %s -d '%s'", programName, f.Name, quote.Replace(f.Usage))
+		if values, ok := completionValues[f.Name]; ok {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(values, " "))
+		} else if !isBoolFlag(f) {
+			line += " -r"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -a '%s'\n",
+		programName, strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -a '%s'\n",
+		programName, strings.Join(completionShells, " "))
+}
+
+// runCompletion implements the completion subcommand and returns the exit code.
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %s completion <%s>\n", programName, strings.Join(completionShells, "|"))
+		return ExitErrUsage
+	}
+
+	err := writeCompletion(os.Stdout, args[0], flag.CommandLine)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitErrUsage
+	}
+
+	return 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("force", false, "Force download")
+	fs.String("os", "", "Select [os]")
+
+	testCases := []struct {
+		shell    string
+		contains []string
+	}{
+		{shell: "bash", contains: []string{"-force -os", "-os|--os)", "linux", "complete -o default -F _go_latest_version"}},
+		{shell: "zsh", contains: []string{"#compdef", "'-force[Force download]'", "'-os[Select \\[os\\]]:os:(aix", "1:command:(completion)"}},
+		{shell: "fish", contains: []string{"-o force -d 'Force download'", "-o os -d 'Select [os]' -x -a 'aix"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.shell, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeCompletion(&out, tc.shell, fs); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for _, want := range tc.contains {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Script missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh", fs); !errors.Is(err, ErrUnsupportedShell) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrUnsupportedShell)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+var ErrUnsupportedDestination = errors.New("unsupported destination")
+
+// Destination is where a verified download is saved, in addition to the local file. It lets
+// downloads go to storage other than the local filesystem, such as cloud buckets.
+//
+// The file is downloaded and verified as usual first, so a destination only ever receives
+// verified bytes. To save it, OpenWriter is called once and the bytes are streamed to the
+// writer, which is then closed. If all bytes were written, Commit is called to make them
+// visible at the destination. Otherwise, Abort is called to discard them. A destination must
+// not expose partially written bytes before Commit.
+type Destination interface {
+	OpenWriter() (io.WriteCloser, error)
+	Commit() error
+	Abort() error
+}
+
+// DestinationFactory returns the Destination for a destination URL and the options of the download.
+type DestinationFactory func(u *url.URL, opts DownloadOptions) (Destination, error)
+
+// destinationFactories maps URL schemes to their factories. Only file is built in.
+var destinationFactories = map[string]DestinationFactory{
+	"file": func(u *url.URL, opts DownloadOptions) (Destination, error) {
+		return &fileDestination{path: filepath.FromSlash(u.Path), mode: opts.FileMode, noClobber: opts.NoClobber}, nil
+	},
+}
+
+// RegisterDestination makes factory handle destination URLs with scheme, such as "s3" or "gs".
+// It is not safe to call concurrently with OpenDestination.
+func RegisterDestination(scheme string, factory DestinationFactory) {
+	destinationFactories[scheme] = factory
+}
+
+// OpenDestination returns the Destination for rawURL, such as file:///tmp/go.tar.gz or
+// s3://bucket/go.tar.gz. A plain path without a scheme is a local file.
+func OpenDestination(rawURL string, opts DownloadOptions) (Destination, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Not a URL, or a Windows drive letter, so treat it as a local path.
+		return &fileDestination{path: rawURL, mode: opts.FileMode, noClobber: opts.NoClobber}, nil
+	}
+
+	factory, ok := destinationFactories[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("%w: no backend for %s://", ErrUnsupportedDestination, u.Scheme)
+	}
+
+	return factory(u, opts)
+}
+
+// fileDestination is a local file that is written to a temporary file in the same directory
+// and renamed into place by Commit, so the file never exists in a partial state.
+type fileDestination struct {
+	path      string
+	mode      os.FileMode // Permission bits of the committed file. If zero, 0644 is used.
+	noClobber bool        // Refuse to replace an existing file, see DownloadOptions.NoClobber.
+	tmp       *os.File
+}
+
+// checkClobber returns ErrDestinationExists if d.noClobber is set and d.path exists.
+func (d *fileDestination) checkClobber() error {
+	return DownloadOptions{NoClobber: d.noClobber}.checkClobber(d.path)
+}
+
+// OpenWriter creates the temporary file.
+func (d *fileDestination) OpenWriter() (io.WriteCloser, error) {
+	err := d.checkClobber()
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	mode := d.mode
+	if mode == 0 {
+		mode = 0o644
+	}
+
+	err = tmp.Chmod(mode)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	d.tmp = tmp
+
+	return tmp, nil
+}
+
+// Commit renames the temporary file to the destination path.
+func (d *fileDestination) Commit() error {
+	err := d.checkClobber()
+	if err != nil {
+		return err
+	}
+
+	return moveFile(d.tmp.Name(), d.path)
+}
+
+// Abort removes the temporary file.
+func (d *fileDestination) Abort() error {
+	if d.tmp == nil {
+		return nil
+	}
+
+	d.tmp.Close()
+
+	return os.Remove(d.tmp.Name())
+}
+
+// saveToDestination copies the verified file at path to dst. The destination is committed
+// only if the whole file was written; otherwise it is aborted.
+func saveToDestination(path string, dst Destination) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := dst.OpenWriter()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Abort()
+		}
+	}()
+
+	_, err = io.Copy(w, f)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return dst.Commit()
+}
+
+// DownloadReleaseTo downloads and verifies file like DownloadRelease, staging it in a temporary
+// directory in opts.TempDir, or the system default, and then saves it to dst. The destination is
+// only written once the file is verified. The Path of the result is empty, as the staged file
+// is removed.
+func DownloadReleaseTo(ctx context.Context, file ReleaseFile, dst Destination, opts DownloadOptions) (DownloadResult, error) {
+	staging, err := os.MkdirTemp(opts.TempDir, ".go-latest-dest-*")
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer os.RemoveAll(staging)
+
+	opts.OutputDir = staging
+	opts.TempDir = ""
+
+	result, err := DownloadRelease(ctx, file, opts)
+	if err == nil {
+		err = saveToDestination(result.Path, dst)
+	}
+	result.Path = ""
+
+	return result, err
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryDestination is an in-memory Destination that records how it was finished.
+type memoryDestination struct {
+	buf       bytes.Buffer
+	committed bool
+	aborted   bool
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (d *memoryDestination) OpenWriter() (io.WriteCloser, error) { return nopWriteCloser{&d.buf}, nil }
+func (d *memoryDestination) Commit() error                       { d.committed = true; return nil }
+func (d *memoryDestination) Abort() error                        { d.aborted = true; return nil }
+
+func TestDownloadReleaseTo(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		file          ReleaseFile
+		expectedError error
+		committed     bool
+		aborted       bool
+	}{
+		{
+			name: "Verified",
+			file: ReleaseFile{
+				Filename: "testfile_1B",
+				OS:       "linux",
+				Arch:     "amd64",
+				SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+				Size:     1,
+			},
+			committed: true,
+		},
+		{
+			name: "Checksum mismatch",
+			file: ReleaseFile{
+				Filename: "testfile_1B",
+				OS:       "linux",
+				Arch:     "amd64",
+				SHA256:   "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				Size:     1,
+			},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name: "Not found",
+			file: ReleaseFile{
+				Filename: "nosuchfile",
+				OS:       "linux",
+				Arch:     "amd64",
+				SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+				Size:     1,
+			},
+			expectedError: ErrDownloadFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := &memoryDestination{}
+			opts := DownloadOptions{BaseURL: server.URL, TempDir: t.TempDir(), Progress: ProgressNone}
+
+			result, err := DownloadReleaseTo(context.Background(), tc.file, dst, opts)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if dst.committed != tc.committed || dst.aborted != tc.aborted {
+				t.Errorf("Unexpected finish.\n Got: committed=%v aborted=%v\nWant: committed=%v aborted=%v",
+					dst.committed, dst.aborted, tc.committed, tc.aborted)
+			}
+
+			if tc.committed && int64(dst.buf.Len()) != result.Size {
+				t.Errorf("Unexpected size.\n Got: %d\nWant: %d", dst.buf.Len(), result.Size)
+			}
+
+			if !tc.committed && dst.buf.Len() != 0 {
+				t.Errorf("Unexpected bytes written to destination: %d", dst.buf.Len())
+			}
+
+			if entries, err := os.ReadDir(opts.TempDir); err != nil || len(entries) != 0 {
+				t.Errorf("Expected the staging directory to be removed, got %v, %v", entries, err)
+			}
+		})
+	}
+}
+
+// failingDestination is a Destination whose writer fails.
+type failingDestination struct{ memoryDestination }
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWriteFailed }
+func (failingWriter) Close() error              { return nil }
+
+var errWriteFailed = errors.New("write failed")
+
+func (d *failingDestination) OpenWriter() (io.WriteCloser, error) { return failingWriter{}, nil }
+
+func TestSaveToDestination(t *testing.T) {
+	path := filepath.Join("testdata", "testfile_1B")
+
+	dst := &memoryDestination{}
+	if err := saveToDestination(path, dst); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !dst.committed || dst.aborted || !bytes.Equal(dst.buf.Bytes(), want) {
+		t.Errorf("Unexpected destination.\n Got: committed=%v aborted=%v %q\nWant: committed=true aborted=false %q",
+			dst.committed, dst.aborted, dst.buf.Bytes(), want)
+	}
+
+	failing := &failingDestination{}
+	if err := saveToDestination(path, failing); !errors.Is(err, errWriteFailed) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, errWriteFailed)
+	}
+
+	if failing.committed || !failing.aborted {
+		t.Errorf("Unexpected finish.\n Got: committed=%v aborted=%v\nWant: committed=false aborted=true",
+			failing.committed, failing.aborted)
+	}
+}
+
+func TestOpenDestination(t *testing.T) {
+	dir := t.TempDir()
+
+	RegisterDestination("mem", func(u *url.URL, opts DownloadOptions) (Destination, error) {
+		return &memoryDestination{}, nil
+	})
+	t.Cleanup(func() { delete(destinationFactories, "mem") })
+
+	testCases := []struct {
+		name          string
+		rawURL        string
+		expectedError error
+	}{
+		{name: "Plain path", rawURL: filepath.Join(dir, "a")},
+		{name: "File URL", rawURL: "file://" + filepath.ToSlash(filepath.Join(dir, "b"))},
+		{name: "Registered scheme", rawURL: "mem://bucket/go.tar.gz"},
+		{name: "Unregistered scheme", rawURL: "s3://bucket/go.tar.gz", expectedError: ErrUnsupportedDestination},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := OpenDestination(tc.rawURL, DownloadOptions{})
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestFileDestination(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+
+	dst, err := OpenDestination(path, DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := dst.OpenWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte{0})
+	w.Close()
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no file before commit, got %v", err)
+	}
+
+	if err := dst.Commit(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, []byte{0}) {
+		t.Errorf("Unexpected file.\n Got: %v, %v\nWant: %v", got, err, []byte{0})
+	}
+}
+
+func TestFileDestinationNoClobber(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := OpenDestination(existing, DownloadOptions{NoClobber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saveToDestination(src, dst)
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrDestinationExists)
+	}
+
+	if got, _ := os.ReadFile(existing); string(got) != "old" {
+		t.Errorf("Unexpected file.\n Got: %q\nWant: %q", got, "old")
+	}
+
+	// A file created while the destination is written is not replaced either.
+	racing := filepath.Join(dir, "racing")
+
+	dst, err = OpenDestination("file://"+filepath.ToSlash(racing), DownloadOptions{NoClobber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := dst.OpenWriter()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w.Write([]byte("new"))
+	w.Close()
+
+	if err := os.WriteFile(racing, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.Commit(); !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrDestinationExists)
+	}
+	dst.Abort()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("Unexpected files left in %s: %v", dir, entries)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ReleaseDiff lists the file descriptors added, removed, and common between two releases.
+type ReleaseDiff struct {
+	Added   []string
+	Removed []string
+	Common  []string
+}
+
+// diffReleases compares the files of releases a and b by OS, architecture, and kind.
+// Each list in the result is sorted.
+func diffReleases(a, b Release) ReleaseDiff {
+	inA := make(map[string]bool)
+	for _, file := range a.Files {
+		inA[fileDescriptor(file)] = true
+	}
+
+	inB := make(map[string]bool)
+	for _, file := range b.Files {
+		inB[fileDescriptor(file)] = true
+	}
+
+	var diff ReleaseDiff
+
+	for desc := range inB {
+		if inA[desc] {
+			diff.Common = append(diff.Common, desc)
+		} else {
+			diff.Added = append(diff.Added, desc)
+		}
+	}
+
+	for desc := range inA {
+		if !inB[desc] {
+			diff.Removed = append(diff.Removed, desc)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Common)
+
+	return diff
+}
+
+// printReleaseDiff writes one tab-separated "status<TAB>descriptor" line per file descriptor.
+func printReleaseDiff(w io.Writer, diff ReleaseDiff) {
+	for _, desc := range diff.Added {
+		fmt.Fprintf(w, "added\t%s\n", desc)
+	}
+
+	for _, desc := range diff.Removed {
+		fmt.Fprintf(w, "removed\t%s\n", desc)
+	}
+
+	for _, desc := range diff.Common {
+		fmt.Fprintf(w, "common\t%s\n", desc)
+	}
+}
+
+// runDiff implements the -diff mode and returns the exit code.
+func runDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Println("Usage: -diff <version1> <version2>")
+		return ExitErrUsage
+	}
+
+	releaseInfo, err := loadReleaseInfo(allReleasesURL)
+	if err != nil {
+		fmt.Printf("Error getting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	var releases [2]Release
+
+	for i, version := range args {
+		releases[i], err = findRelease(releaseInfo, version)
+		if err != nil {
+			fmt.Printf("Error finding release: %v\n", err)
+			return ExitErrMatchFile
+		}
+	}
+
+	printReleaseDiff(os.Stdout, diffReleases(releases[0], releases[1]))
+
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiffReleases(t *testing.T) {
+	a := Release{
+		Version: "go1.21.0",
+		Files: []ReleaseFile{
+			{OS: "linux", Arch: "amd64", Kind: "archive"},
+			{OS: "linux", Arch: "386", Kind: "archive"},
+			{Kind: "source"},
+		},
+	}
+	b := Release{
+		Version: "go1.22.0",
+		Files: []ReleaseFile{
+			{OS: "linux", Arch: "amd64", Kind: "archive"},
+			{OS: "linux", Arch: "loong64", Kind: "archive"},
+			{Kind: "source"},
+		},
+	}
+
+	var out bytes.Buffer
+	printReleaseDiff(&out, diffReleases(a, b))
+
+	want := "added\tlinux/loong64 archive\n" +
+		"removed\tlinux/386 archive\n" +
+		"common\tlinux/amd64 archive\n" +
+		"common\tsource\n"
+
+	if out.String() != want {
+		t.Errorf("Unexpected diff.\n Got: %q\nWant: %q", out.String(), want)
+	}
+}
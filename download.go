@@ -4,13 +4,20 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ProgressHashWriter combines hash computation with progress display for written bytes.
@@ -19,6 +26,23 @@ type ProgressHashWriter struct {
 	expectedLen int       // Length of the Expected as a string. Precalculate to avoid repeatedly computing in Write().
 	Written     int64     // Total bytes written.
 	Hash        hash.Hash // Hash of written bytes.
+
+	resumed int64 // Bytes of Written kept from an earlier attempt, which do not count towards the rate.
+
+	now     func() time.Time // Clock used for rate and ETA. Defaults to time.Now; replace in tests.
+	start   time.Time        // Time of the first Write, or zero before it.
+	out     io.Writer        // Destination for progress display. Defaults to os.Stdout.
+	lastLen int              // Length of the last progress line, used to clear residual characters.
+
+	lastRender time.Time // Time of the last terminal progress line, used to throttle updates.
+	shownDone  bool      // Whether a terminal progress line has shown all Expected bytes written.
+	stale      bool      // Whether writes since the last terminal progress line are not shown yet.
+
+	render    func(tw *ProgressHashWriter)        // Displays progress after each Write. Defaults to renderTerminal.
+	newRender func() func(tw *ProgressHashWriter) // Creates render afresh for Reset, if set by setProgressMode.
+
+	warnings io.Writer // Destination for warnings, such as receiving more than Expected. Defaults to os.Stderr.
+	exceeded bool      // Whether more than Expected has been written and warned about.
 }
 
 // NewProgressHashWriter initializes a new ProgressHashWriter.
@@ -28,12 +52,52 @@ func NewProgressHashWriter(expected int64, h hash.Hash) *ProgressHashWriter {
 		expectedLen: len(strconv.FormatInt(expected, 10)),
 		Written:     0,
 		Hash:        h,
+		now:         time.Now,
+		out:         os.Stdout,
+		render:      renderTerminal,
+		warnings:    os.Stderr,
 	}
 }
 
+// Rate returns the average bytes per second written since the first Write, not counting the
+// bytes of a resumed download that were written by an earlier attempt.
+func (tw *ProgressHashWriter) Rate() float64 {
+	if tw.start.IsZero() {
+		return 0
+	}
+
+	elapsed := tw.now().Sub(tw.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(tw.Written-tw.resumed) / elapsed
+}
+
+// resumeAt records that the first offset bytes were written, and hashed, by an earlier attempt.
+func (tw *ProgressHashWriter) resumeAt(offset int64) {
+	tw.Written = offset
+	tw.resumed = offset
+}
+
+// ETA returns the estimated time until Expected bytes are written, based on the average rate.
+// It returns zero if the rate is not yet known or the expected bytes have been written.
+func (tw *ProgressHashWriter) ETA() time.Duration {
+	rate := tw.Rate()
+	if rate <= 0 || tw.Written >= tw.Expected {
+		return 0
+	}
+
+	return time.Duration(float64(tw.Expected-tw.Written) / rate * float64(time.Second))
+}
+
 // Write tracks and displays progress while updating the hash.
 // Use for real-time progress updates and integrity verification during file downloads.
 func (tw *ProgressHashWriter) Write(data []byte) (int, error) {
+	if tw.start.IsZero() {
+		tw.start = tw.now()
+	}
+
 	// Update the hash with new data.
 	tw.Hash.Write(data)
 
@@ -41,53 +105,403 @@ func (tw *ProgressHashWriter) Write(data []byte) (int, error) {
 	n := len(data)
 	tw.Written += int64(n)
 
+	// Warn once, as early as possible, that the size will not match.
+	if !tw.exceeded && tw.Expected > 0 && tw.Written > tw.Expected {
+		tw.exceeded = true
+		if !warnJSON {
+			// End the progress line so the warning starts on its own.
+			fmt.Fprintln(tw.warnings)
+		}
+		writeWarning(tw.warnings, WarnExcessData, fmt.Sprintf("received more than the expected %d bytes", tw.Expected))
+	}
+
 	// Display current progress.
-	fmt.Printf("\r%3.0f%% (%*d of %d) complete",
-		100.0*float64(tw.Written)/float64(tw.Expected),
-		tw.expectedLen, tw.Written,
-		tw.Expected)
+	tw.render(tw)
 
 	return n, nil
 }
 
-var ErrDownloadFailed = errors.New("download failed")
+// Flush shows the current progress if the last writes were not shown because of throttling.
+// Call it once the download completes, so the final state is always displayed.
+func (tw *ProgressHashWriter) Flush() {
+	if !tw.stale {
+		return
+	}
+
+	tw.lastRender = time.Time{}
+	tw.render(tw)
+}
+
+// Reset discards the bytes written so far, re-initializes the hash, and restarts the progress
+// display, as if nothing had been written. Use when a download restarts from the beginning,
+// such as when a server ignores a range request.
+func (tw *ProgressHashWriter) Reset() {
+	tw.Hash.Reset()
+	tw.Written = 0
+	tw.resumed = 0
+	tw.exceeded = false
+	tw.start = time.Time{}
+
+	// lastLen is kept, as the line of the abandoned attempt is still on screen to be overwritten.
+	tw.lastRender = time.Time{}
+	tw.shownDone = false
+	tw.stale = false
+
+	// The renderers of setProgressMode keep their own state, such as the next milestone.
+	if tw.newRender != nil {
+		tw.render = tw.newRender()
+	}
+}
+
+var (
+	ErrDownloadFailed = errors.New("download failed")
+	ErrInsecureURL    = errors.New("insecure URL")
+)
+
+// allowInsecure permits plain http URLs. Set by the -allow-insecure flag for testing against local servers.
+var allowInsecure bool
+
+// checkSecureURL returns ErrInsecureURL unless rawURL uses https, or http when allowInsecure is set.
+func checkSecureURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "https" || (u.Scheme == "http" && allowInsecure) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q does not use https", ErrInsecureURL, rawURL)
+}
+
+// checkSecureRedirect is an http.Client.CheckRedirect function that applies checkSecureURL to
+// every redirect, so an https URL cannot be downgraded to http, and otherwise stops after
+// 10 redirects like the default policy.
+func checkSecureRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	return checkSecureURL(req.URL.String())
+}
+
+// DownloadOptions controls how a file is downloaded.
+type DownloadOptions struct {
+	// BaseURL is the URL that release filenames are relative to.
+	// If empty, the official https://go.dev/dl is used. Its scheme selects the Fetcher,
+	// see RegisterFetcher.
+	BaseURL string
+
+	// Client is the HTTP client used for the download. If nil, a shared client with retries is used.
+	Client *http.Client
+
+	// OutputDir is the directory the file is saved in. If empty, the current directory is used.
+	OutputDir string
+
+	// FileMode sets the permission bits of the downloaded file, regardless of umask.
+	// If zero, 0644 is used.
+	FileMode os.FileMode
+
+	// Progress selects the progress display: ProgressTerminal (the default), ProgressJSONL, or ProgressNone.
+	// JSON Lines progress is written to stderr so it does not mix with other output.
+	Progress string
+
+	// ProgressInterval, if positive, logs terminal progress once per interval when stdout is
+	// not a terminal. If zero, a line is logged at every 10 percent instead.
+	ProgressInterval time.Duration
+
+	// Resume keeps an interrupted download in a partial file next to the destination and
+	// continues it with a range request on the next attempt.
+	Resume bool
+
+	// TempDir is the directory the file is downloaded to before being moved into OutputDir.
+	// If empty, the destination directory is used, so the final rename is atomic.
+	// If TempDir is on a different filesystem, the file is copied to a temporary file next to
+	// the destination and renamed from there, so the destination is still never partial, but
+	// the copy takes time and needs space on both filesystems.
+	TempDir string
+
+	// ChunkVerify downloads the file in chunks verified against a block hashes sidecar, if the
+	// mirror publishes one, so only corrupt chunks are fetched again. See BlockHashes.
+	// Without a sidecar, or if the file is fetched by a Fetcher that cannot fetch a byte range,
+	// the whole file is downloaded and verified as usual.
+	ChunkVerify bool
+
+	// CacheDir, if set, is a content-addressed cache that the file is downloaded into, or found
+	// in if it was downloaded before. The file in OutputDir links to the cached copy. See casPath.
+	CacheDir string
+
+	// NoClobber refuses with ErrDestinationExists to replace a file that already exists at the
+	// destination, whether or not it is valid. By default an existing file is replaced.
+	NoClobber bool
+
+	// MaxFileSize, if positive, refuses with ErrFileTooLarge a file that the feed or the
+	// Content-Length of the response says is larger, before any of it is written.
+	MaxFileSize int64
+
+	// StallTimeout aborts the download with ErrStalled if no bytes arrive for this long.
+	// If zero, a stalled download waits forever.
+	StallTimeout time.Duration
+
+	// Mirrors are base URLs, like BaseURL, that a file failing checksum verification is
+	// downloaded from again, in turn, so a retry does not go back to the corrupt source.
+	Mirrors []string
+
+	// ExtraHashes names algorithms of hashAlgorithms, e.g. "sha512", computed in the same pass
+	// as the SHA256 checksum and returned in DownloadResult.Digests, such as for a manifest.
+	// The file is still verified against the SHA256 checksum of the feed only.
+	ExtraHashes []string
+
+	// ChecksumRetries is how many times a file failing checksum verification is downloaded
+	// again, each time from the next of BaseURL and Mirrors, starting over after the last.
+	ChecksumRetries int
+
+	// Manifest, if not nil, is a checksum manifest whose signature was verified, see
+	// ManifestOptions. A file is only downloaded, or kept, if the manifest lists it with the
+	// checksum of the feed, and fails with ErrNotInManifest or ErrManifestMismatch otherwise.
+	Manifest ChecksumManifest
+
+	afterFunc func(time.Duration, func()) stallTimer // Starts the stall timer. Defaults to time.AfterFunc; replace in tests.
+}
+
+// checkManifest returns an error if opts.Manifest is set and does not agree with file.
+func (opts DownloadOptions) checkManifest(file ReleaseFile) error {
+	if opts.Manifest == nil {
+		return nil
+	}
+
+	return checkManifest(opts.Manifest, file)
+}
+
+// sources returns the base URLs a file is downloaded from, in the order they are tried.
+func (opts DownloadOptions) sources() []string {
+	return append([]string{opts.BaseURL}, opts.Mirrors...)
+}
+
+// tempDir returns the directory for temporary files of a download to dest.
+func (opts DownloadOptions) tempDir(dest string) string {
+	if opts.TempDir != "" {
+		return opts.TempDir
+	}
+
+	return filepath.Dir(dest)
+}
+
+// watchBody returns body wrapped in a stallWatchdog that calls cancel if the download stalls
+// for opts.StallTimeout, or body itself if there is no stall timeout. stop releases the watchdog.
+func (opts DownloadOptions) watchBody(body io.Reader, cancel func()) (r io.Reader, stop func()) {
+	if opts.StallTimeout <= 0 {
+		return body, func() {}
+	}
+
+	w := newStallWatchdog(body, opts.StallTimeout, cancel, opts.afterFunc)
+
+	return w, w.Stop
+}
+
+var (
+	ErrFileTooLarge      = errors.New("file too large")
+	ErrDestinationExists = errors.New("destination exists")
+)
+
+// checkClobber returns ErrDestinationExists if opts.NoClobber is set and dest exists.
+func (opts DownloadOptions) checkClobber(dest string) error {
+	if !opts.NoClobber {
+		return nil
+	}
+
+	_, err := os.Lstat(dest)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrDestinationExists, dest)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// checkFileSize returns ErrFileTooLarge if size exceeds opts.MaxFileSize.
+func (opts DownloadOptions) checkFileSize(size int64) error {
+	if opts.MaxFileSize > 0 && size > opts.MaxFileSize {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrFileTooLarge, size, opts.MaxFileSize)
+	}
+
+	return nil
+}
+
+// partSuffix is appended to the destination to name the partial file of a resumable download.
+const partSuffix = ".part"
+
+// client returns the HTTP client to use for opts.
+func (opts DownloadOptions) client() *http.Client {
+	if opts.Client != nil {
+		return opts.Client
+	}
+
+	return httpClient
+}
 
 // DownloadFileWithProgressAndChecksum downloads a file with a progress display and checksum computation.
 // It downloads a file from url, saves it to a specified filepath, and returns size and checksum for verification.
 // If the file already exists at the filepath, it will be overwritten.
-func DownloadFileWithProgressAndChecksum(url, filepath string, expectedSize int64, h hash.Hash) (size int64, checksum string, err error) {
-	fmt.Printf("Downloading %q to %q\n", url, filepath)
+//
+// Regular files are written to a temporary file that is renamed over filepath only after a
+// successful download, so an interrupted download never leaves a partial file at filepath.
+// Destinations that exist and are not regular files, such as named pipes and devices, are written directly.
+//
+// If the response has a Last-Modified header, it is used as the modification time of a regular file.
+func DownloadFileWithProgressAndChecksum(url, filepath string, expectedSize int64, h hash.Hash, opts DownloadOptions) (size int64, checksum string, err error) {
+	return downloadFile(context.Background(), url, filepath, expectedSize, "", h, opts)
+}
+
+// downloadFile implements DownloadFileWithProgressAndChecksum with a context that cancels the
+// request. The expected hex SHA256 expectedSum, if known, identifies the download to resume.
+func downloadFile(ctx context.Context, url, dest string, expectedSize int64, expectedSum string, h hash.Hash, opts DownloadOptions) (size int64, checksum string, err error) {
+	fmt.Printf("Downloading %q to %q\n", url, dest)
 
-	// Create or overwrite the file
-	out, err := os.Create(filepath)
+	// Refuse to download over an unencrypted connection, or a scheme without a Fetcher.
+	fetcher, err := opts.fetcher(url)
 	if err != nil {
 		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
 	}
-	defer out.Close()
 
-	// Get the content from url.
-	resp, err := http.Get(url)
+	err = opts.checkClobber(dest)
 	if err != nil {
 		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
 	}
-	defer resp.Body.Close()
 
-	// Check for successful response.
-	if resp.StatusCode != http.StatusOK {
-		return 0, "", fmt.Errorf("%w: %q %s", ErrDownloadFailed,
-			url, http.StatusText(resp.StatusCode))
+	// Only regular files can be replaced atomically with a rename.
+	atomic := true
+	if info, statErr := os.Stat(dest); statErr == nil && !info.Mode().IsRegular() {
+		atomic = false
+	}
+
+	resume := atomic && opts.Resume
+
+	var out *os.File
+	switch {
+	case resume:
+		// Use a stable name so a later attempt can find and continue the partial file.
+		out, err = os.OpenFile(filepath.Join(opts.tempDir(dest), filepath.Base(dest)+partSuffix), os.O_RDWR|os.O_CREATE, 0o600)
+	case atomic:
+		// Use a unique temporary name so concurrent downloads of the same file do not
+		// corrupt each other. The rename below commits the file; the last writer wins.
+		out, err = os.CreateTemp(opts.tempDir(dest), filepath.Base(dest)+".*.tmp")
+	default:
+		out, err = os.Create(dest)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+
+	outPath := out.Name()
+	defer func() {
+		out.Close()
+
+		// Remove the temporary file if the download did not complete.
+		// A partial file is kept so the download can be resumed.
+		if err != nil && atomic && !resume {
+			os.Remove(outPath)
+		}
+	}()
+
+	if atomic {
+		// CreateTemp uses 0600, so always set the mode of the final file.
+		mode := opts.FileMode
+		if mode == 0 {
+			mode = 0o644
+		}
+
+		err = out.Chmod(mode)
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
 	}
 
 	// Initialize the ProgressHashWriter
 	teeWriter := NewProgressHashWriter(expectedSize, h)
 
-	// Download the file, displaying progress and computing hash
-	_, err = io.Copy(out, io.TeeReader(resp.Body, teeWriter))
+	err = teeWriter.setProgressMode(opts.Progress, opts.ProgressInterval, os.Stderr)
 	if err != nil {
 		return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
 	}
 
-	fmt.Println()
+	// Keep and hash the bytes of a previous attempt at the same download, if any,
+	// leaving the file positioned at their end.
+	var offset int64
+	if resume {
+		statePath := outPath + stateSuffix
+		state := resumeState{URL: url, ExpectedSize: expectedSize, Checksum: expectedSum}
+
+		offset = resumeOffset(out, state)
+		if expectedSize > 0 && offset > expectedSize {
+			// More than the whole file cannot be the same download, so start over.
+			offset = 0
+		}
+
+		err = out.Truncate(offset)
+		if err == nil {
+			_, err = io.CopyN(teeWriter.Hash, out, offset)
+		}
+		if err == nil {
+			// Save the state before any byte is written, so a killed process can resume too.
+			err = writeResumeState(statePath, state)
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+		teeWriter.resumeAt(offset)
+
+		// Keep the state for a later attempt, or clean up once this one succeeds.
+		defer func() {
+			if err == nil {
+				os.Remove(statePath)
+			}
+		}()
+	}
+
+	// A partial file that already holds the whole file, such as one kept by an attempt that was
+	// killed before its rename, is only verified, as a range request past its end would fail.
+	var modTime time.Time
+	if !resume || expectedSize <= 0 || offset < expectedSize {
+		modTime, err = fetchInto(ctx, fetcher, url, offset, out, teeWriter, opts)
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+	}
+
+	teeWriter.Flush()
+	if opts.Progress == "" || opts.Progress == ProgressTerminal {
+		fmt.Println()
+	}
+
+	if atomic {
+		err = out.Close()
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+
+		// The destination may have been created during the download.
+		err = opts.checkClobber(dest)
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+
+		err = moveFile(outPath, dest)
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+		}
+
+		// Preserve the upstream modification time, if known.
+		if !modTime.IsZero() {
+			err = os.Chtimes(dest, modTime, modTime)
+			if err != nil {
+				return 0, "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+			}
+		}
+	}
 
 	// Return the size and checksum of the downloaded file
 	size = teeWriter.Written
@@ -95,3 +509,306 @@ func DownloadFileWithProgressAndChecksum(url, filepath string, expectedSize int6
 
 	return size, checksum, nil
 }
+
+// fetchInto fetches url with fetcher from offset and writes it to out, which is positioned at
+// offset, and to tw. If the fetcher sends the whole file instead, out and tw start over. It
+// returns the modification time of the content, if known. A stalled download is aborted, see
+// watchBody.
+func fetchInto(ctx context.Context, fetcher Fetcher, url string, offset int64, out *os.File,
+	tw *ProgressHashWriter, opts DownloadOptions,
+) (time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Get the content from url.
+	content, err := fetchFrom(ctx, fetcher, url, offset)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer content.Body.Close()
+
+	if offset > 0 && content.Offset == 0 {
+		// The fetcher could not continue the download and sent the whole file, so start over.
+		tw.Reset()
+
+		err = out.Truncate(0)
+		if err == nil {
+			_, err = out.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// The length of a partial response is only the rest of the file.
+	if content.Length > 0 {
+		err = opts.checkFileSize(content.Offset + content.Length)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	body, stop := opts.watchBody(content.Body, cancel)
+	defer stop()
+
+	// Download the file, displaying progress and computing hash
+	_, err = io.Copy(out, io.TeeReader(body, tw))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return content.ModTime, nil
+}
+
+// rename is os.Rename, replaced in tests to simulate moves across filesystems.
+var rename = os.Rename
+
+// writeFileAtomic writes data to a temporary file in the directory of path and renames it
+// over path, so readers of path never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return rename(tmp.Name(), path)
+}
+
+// moveFile renames src to dst. If they are on different filesystems, src is copied to a
+// temporary file in the directory of dst, which is then renamed over dst, and src is removed.
+func moveFile(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Chmod(info.Mode().Perm())
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+var (
+	ErrInvalidFilename    = errors.New("invalid filename")
+	ErrInvalidReleaseFile = errors.New("invalid release file")
+)
+
+// isSafeFilename reports whether name is a single path element that can be joined to a
+// directory or URL without escaping it.
+func isSafeFilename(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// Validate checks that f has the fields needed to download and verify it: a safe Filename,
+// an OS and Arch unless it is the source archive, a hex SHA256 digest, and a non-negative Size.
+func (f ReleaseFile) Validate() error {
+	if !isSafeFilename(f.Filename) {
+		return fmt.Errorf("%w: %w: %q", ErrInvalidReleaseFile, ErrInvalidFilename, f.Filename)
+	}
+
+	if f.Kind != "source" && (f.OS == "" || f.Arch == "") {
+		return fmt.Errorf("%w: %s has no OS or Arch", ErrInvalidReleaseFile, f.Filename)
+	}
+
+	if _, err := hex.DecodeString(f.SHA256); err != nil || len(f.SHA256) != 2*sha256.Size {
+		return fmt.Errorf("%w: %s has invalid SHA256 %q", ErrInvalidReleaseFile, f.Filename, f.SHA256)
+	}
+
+	if f.Size < 0 {
+		return fmt.Errorf("%w: %s has negative size %d", ErrInvalidReleaseFile, f.Filename, f.Size)
+	}
+
+	return nil
+}
+
+// DownloadURL returns the URL of file relative to baseURL, such as https://go.dev/dl
+// or https://dl.google.com/go. If baseURL is empty, https://go.dev/dl is used.
+// The filename must be a single path element; it is escaped as needed.
+func DownloadURL(file ReleaseFile, baseURL string) (string, error) {
+	if baseURL == "" {
+		baseURL = downloadPrefixURL
+	}
+
+	name := file.Filename
+	if !isSafeFilename(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFilename, name)
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	if base.Scheme == "" || base.Host == "" {
+		return "", fmt.Errorf("invalid base URL: %q is not absolute", baseURL)
+	}
+
+	return base.JoinPath(name).String(), nil
+}
+
+// DownloadResult describes a downloaded and verified release file.
+type DownloadResult struct {
+	Path     string        // Local path of the downloaded file.
+	Size     int64         // Bytes downloaded.
+	Checksum string        // Hex SHA256 checksum of the downloaded bytes.
+	Duration time.Duration // Time taken by the download.
+
+	// Digests are the hex digests of the downloaded bytes, keyed by algorithm, including
+	// "sha256" and any DownloadOptions.ExtraHashes. Nil if ExtraHashes is empty.
+	Digests map[string]string
+}
+
+// DownloadRelease validates file, then downloads it from opts.BaseURL into opts.OutputDir and verifies its
+// SHA256 checksum and size against file. The file is left in place even if verification fails,
+// and the returned result describes what was downloaded. With opts.CacheDir, a file found in the
+// cache is not downloaded again, and the result has no Duration.
+//
+// A checksum mismatch is retried up to opts.ChecksumRetries times, rotating through opts.Mirrors.
+// If the last attempt fails too, the error lists the hosts that were tried.
+func DownloadRelease(ctx context.Context, file ReleaseFile, opts DownloadOptions) (DownloadResult, error) {
+	err := file.Validate()
+	if err == nil {
+		err = opts.checkManifest(file)
+	}
+	if err == nil {
+		err = opts.checkFileSize(file.Size)
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	path := filepath.Join(opts.OutputDir, file.Filename)
+
+	err = opts.checkClobber(path)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	sources := opts.sources()
+
+	var hosts []string
+	for attempt := 0; ; attempt++ {
+		fullURL, err := DownloadURL(file, sources[attempt%len(sources)])
+		if err != nil {
+			return DownloadResult{}, err
+		}
+
+		if u, err := url.Parse(fullURL); err == nil {
+			hosts = append(hosts, u.Host)
+		}
+
+		var result DownloadResult
+		if opts.CacheDir != "" {
+			result, err = downloadCached(ctx, file, fullURL, path, opts)
+		} else {
+			result, err = fetchRelease(ctx, file, fullURL, path, opts)
+		}
+
+		if !errors.Is(err, ErrChecksumMismatch) {
+			return result, err
+		}
+
+		if attempt >= opts.ChecksumRetries {
+			if attempt > 0 {
+				err = fmt.Errorf("%w (tried %s)", err, strings.Join(hosts, ", "))
+			}
+			return result, err
+		}
+
+		logger.Info("checksum mismatch, downloading again", "url", fullURL, "next", sources[(attempt+1)%len(sources)])
+
+		// Remove the rejected file, so it is not mistaken for the retry or refused by NoClobber.
+		if info, statErr := os.Lstat(path); statErr == nil && info.Mode().IsRegular() {
+			os.Remove(path)
+		}
+	}
+}
+
+// fetchRelease downloads file from fullURL to path and verifies it, as described by DownloadRelease.
+func fetchRelease(ctx context.Context, file ReleaseFile, fullURL, path string, opts DownloadOptions) (DownloadResult, error) {
+	var err error
+	start := time.Now()
+
+	var hashes *BlockHashes
+	if opts.ChunkVerify && canFetchChunks(fullURL, opts) {
+		hashes, err = fetchBlockHashes(ctx, fullURL, opts)
+		if err != nil {
+			return DownloadResult{}, fmt.Errorf("download failed: %w", err)
+		}
+	}
+
+	var h hash.Hash = sha256.New()
+	var mh *multiHash
+	if len(opts.ExtraHashes) > 0 {
+		mh, err = newMultiHash(append([]string{"sha256"}, opts.ExtraHashes...)...)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+		h = mh
+	}
+
+	var size int64
+	var checksum string
+	if hashes != nil {
+		size, checksum, err = downloadChunked(ctx, fullURL, path, file.Size, *hashes, h, opts)
+	} else {
+		size, checksum, err = downloadFile(ctx, fullURL, path, file.Size, file.SHA256, h, opts)
+	}
+	if err != nil {
+		return DownloadResult{}, fmt.Errorf("download failed: %w", err)
+	}
+
+	result := DownloadResult{
+		Path:     path,
+		Size:     size,
+		Checksum: checksum,
+		Duration: time.Since(start),
+	}
+
+	if mh != nil {
+		result.Digests = mh.Digests()
+	}
+
+	return result, checkChecksumAndSize(file, size, checksum)
+}
@@ -1,16 +1,112 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// fakeClock is a manually advanced clock for deterministic time-based tests.
+// Its timers fire during Advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2023, 11, 26, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+
+	var due []func()
+	for _, timer := range c.timers {
+		if timer.active && !timer.when.After(c.t) {
+			timer.active = false
+			due = append(due, timer.f)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+// AfterFunc is time.AfterFunc on the fake clock.
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) stallTimer {
+	timer := &fakeTimer{c: c, f: f}
+	c.mu.Lock()
+	c.timers = append(c.timers, timer)
+	c.mu.Unlock()
+	timer.Reset(d)
+
+	return timer
+}
+
+type fakeTimer struct {
+	c      *fakeClock
+	f      func()
+	when   time.Time
+	active bool
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	wasActive := t.active
+	t.when, t.active = t.c.t.Add(d), true
+
+	return wasActive
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+
+	return wasActive
+}
+
+// setAllowInsecure sets allowInsecure for the duration of the test.
+func setAllowInsecure(t *testing.T, allow bool) {
+	old := allowInsecure
+	allowInsecure = allow
+	t.Cleanup(func() { allowInsecure = old })
+}
+
 func TestDownloadFileWithProgressAndChecksum(t *testing.T) {
+	// httptest servers use plain http
+	setAllowInsecure(t, true)
+
 	// mock HTTP response and return named files from testdata directory
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		filePath := filepath.Join("testdata", r.URL.Path)
@@ -98,7 +194,7 @@ func TestDownloadFileWithProgressAndChecksum(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			size, checksum, err := DownloadFileWithProgressAndChecksum(tc.url, tc.filepath, tc.expectedSize, sha256.New())
+			size, checksum, err := DownloadFileWithProgressAndChecksum(tc.url, tc.filepath, tc.expectedSize, sha256.New(), DownloadOptions{})
 
 			if !errors.Is(err, tc.expectedError) {
 				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
@@ -114,3 +210,921 @@ func TestDownloadFileWithProgressAndChecksum(t *testing.T) {
 		})
 	}
 }
+
+func TestProgressHashWriterRate(t *testing.T) {
+	clock := newFakeClock()
+
+	// The rate is measured with the injected clock from the first write.
+	w := NewProgressHashWriter(1000, sha256.New())
+	w.now = clock.Now
+	w.out = io.Discard
+
+	clock.Advance(time.Hour)
+	w.Write(make([]byte, 100))
+	clock.Advance(time.Second)
+	w.Write(make([]byte, 100))
+
+	if rate := w.Rate(); rate != 200 {
+		t.Errorf("Unexpected rate.\n Got: %v\nWant: %v", rate, 200.0)
+	}
+
+	if eta := w.ETA(); eta != 4*time.Second {
+		t.Errorf("Unexpected ETA.\n Got: %v\nWant: %v", eta, 4*time.Second)
+	}
+}
+
+func TestProgressHashWriterRateResumed(t *testing.T) {
+	clock := newFakeClock()
+
+	// The bytes kept from an earlier attempt are not part of the rate of this one.
+	w := NewProgressHashWriter(1000, sha256.New())
+	w.now = clock.Now
+	w.out = io.Discard
+	w.resumeAt(900)
+
+	w.Write(make([]byte, 10))
+	clock.Advance(time.Second)
+	w.Write(make([]byte, 10))
+
+	if rate := w.Rate(); rate != 20 {
+		t.Errorf("Unexpected rate.\n Got: %v\nWant: %v", rate, 20.0)
+	}
+
+	if eta := w.ETA(); eta != 4*time.Second {
+		t.Errorf("Unexpected ETA.\n Got: %v\nWant: %v", eta, 4*time.Second)
+	}
+}
+
+func TestProgressHashWriterETA(t *testing.T) {
+	testCases := []struct {
+		name        string
+		expected    int64
+		written     int64
+		elapsed     time.Duration
+		expectedETA time.Duration
+	}{
+		{
+			name:        "Half done",
+			expected:    1000,
+			written:     500,
+			elapsed:     10 * time.Second,
+			expectedETA: 10 * time.Second,
+		},
+		{
+			name:        "Quarter done",
+			expected:    1000,
+			written:     250,
+			elapsed:     time.Second,
+			expectedETA: 3 * time.Second,
+		},
+		{
+			name:        "Complete",
+			expected:    1000,
+			written:     1000,
+			elapsed:     time.Second,
+			expectedETA: 0,
+		},
+		{
+			name:        "No time elapsed",
+			expected:    1000,
+			written:     500,
+			elapsed:     0,
+			expectedETA: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+
+			w := NewProgressHashWriter(tc.expected, sha256.New())
+			w.now = clock.Now
+			w.start = clock.Now()
+
+			w.Write(make([]byte, tc.written))
+			clock.Advance(tc.elapsed)
+
+			if eta := w.ETA(); eta != tc.expectedETA {
+				t.Errorf("Unexpected ETA.\n Got: %v\nWant: %v", eta, tc.expectedETA)
+			}
+		})
+	}
+}
+
+func TestDownloadRejectsInsecureURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request to insecure server")
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+
+	_, _, err := DownloadFileWithProgressAndChecksum(server.URL+"/testfile_1B", filePath, 1, sha256.New(), DownloadOptions{})
+	if !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInsecureURL)
+	}
+
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("Unexpected file created for rejected download: %v", err)
+	}
+}
+
+func TestDownloadRejectsInsecureRedirect(t *testing.T) {
+	var insecureRequests atomic.Int32
+	insecure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		insecureRequests.Add(1)
+		w.Write([]byte("1"))
+	}))
+	defer insecure.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, insecure.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer secure.Close()
+
+	// Send the shared client to the TLS test server, keeping its redirect policy.
+	oldTransport := httpClient.Transport
+	httpClient.Transport = secure.Client().Transport
+	t.Cleanup(func() { httpClient.Transport = oldTransport })
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+
+	_, _, err := DownloadFileWithProgressAndChecksum(secure.URL+"/testfile_1B", filePath, 1, sha256.New(), DownloadOptions{})
+	if !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInsecureURL)
+	}
+
+	if got := insecureRequests.Load(); got != 0 {
+		t.Errorf("Unexpected requests to insecure server.\n Got: %d\nWant: %d", got, 0)
+	}
+
+	// With -allow-insecure, the redirect is followed.
+	setAllowInsecure(t, true)
+
+	_, _, err = DownloadFileWithProgressAndChecksum(secure.URL+"/testfile_1B", filePath, 1, sha256.New(), DownloadOptions{})
+	if err != nil || insecureRequests.Load() != 1 {
+		t.Errorf("Unexpected result with allow insecure.\n Got: %v, %d requests\nWant: <nil>, 1 request", err, insecureRequests.Load())
+	}
+}
+
+func TestProgressHashWriterClearsResidual(t *testing.T) {
+	var out bytes.Buffer
+
+	clock := newFakeClock()
+
+	w := NewProgressHashWriter(10, sha256.New())
+	w.now = clock.Now
+	w.out = &out
+	w.warnings = io.Discard
+
+	// Write more than expected to produce a long line, then restart so the next line is shorter.
+	w.Write(make([]byte, 1000))
+	long := w.lastLen
+
+	w.Written = 0
+	out.Reset()
+	clock.Advance(terminalProgressInterval)
+	w.Write(make([]byte, 1))
+
+	got := strings.TrimPrefix(out.String(), "\r")
+	want := " 10% ( 1 of 10) complete, 5 B/s, ETA 2s"
+
+	if len(got) < long {
+		t.Errorf("Line not cleared.\n Got length: %d\nWant length: >= %d", len(got), long)
+	}
+
+	if strings.TrimRight(got, " ") != want {
+		t.Errorf("Unexpected progress.\n Got: %q\nWant: %q", got, want)
+	}
+}
+
+func TestDownloadToDevice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no /dev/null on windows")
+	}
+
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	size, checksum, err := DownloadFileWithProgressAndChecksum(server.URL+"/testfile_1B", os.DevNull, 1, sha256.New(), DownloadOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantChecksum := "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"
+	if checksum != wantChecksum {
+		t.Errorf("Unexpected checksum.\n Got: %q\nWant: %q", checksum, wantChecksum)
+	}
+
+	if size != 1 {
+		t.Errorf("Unexpected size.\n Got: %d\nWant: %d", size, 1)
+	}
+
+	matches, _ := filepath.Glob(os.DevNull + ".*.tmp")
+	if len(matches) != 0 {
+		t.Errorf("Unexpected temp files next to device: %v", matches)
+	}
+}
+
+func TestDownloadLeavesNoPartialFile(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+
+	_, _, err := DownloadFileWithProgressAndChecksum(server.URL+"/nosuchfile", filePath, 1, sha256.New(), DownloadOptions{})
+	if !errors.Is(err, ErrDownloadFailed) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrDownloadFailed)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		t.Errorf("Unexpected file %q after failed download", entry.Name())
+	}
+}
+
+func TestDownloadSetsModTimeAndMode(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	modTime := time.Date(2023, 11, 7, 18, 34, 56, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Write([]byte{0})
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+
+	_, _, err := DownloadFileWithProgressAndChecksum(server.URL, filePath, 1, sha256.New(), DownloadOptions{FileMode: 0o600})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Unexpected mtime.\n Got: %v\nWant: %v", info.ModTime(), modTime)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("Unexpected mode.\n Got: %v\nWant: %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+}
+
+func TestDownloadRelease(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+		Size:     1,
+	}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir()}
+
+	result, err := DownloadRelease(context.Background(), file, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantPath := filepath.Join(opts.OutputDir, file.Filename)
+	if result.Path != wantPath {
+		t.Errorf("Unexpected path.\n Got: %q\nWant: %q", result.Path, wantPath)
+	}
+
+	if result.Checksum != file.SHA256 || result.Size != file.Size {
+		t.Errorf("Unexpected result.\n Got: %+v\nWant: %+v", result, file)
+	}
+
+	file.SHA256 = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	_, err = DownloadRelease(context.Background(), file, opts)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestConcurrentDownloadsToSamePath(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1MB",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e",
+		Size:     1024 * 1024,
+	}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = DownloadRelease(context.Background(), file, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Download %d failed: %v", i, err)
+		}
+	}
+
+	if err := VerifyLocalFile(filepath.Join(opts.OutputDir, file.Filename), file); err != nil {
+		t.Errorf("Final file does not verify: %v", err)
+	}
+
+	entries, err := os.ReadDir(opts.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("Unexpected files left behind: %d entries", len(entries))
+	}
+}
+
+func TestReleaseFileValidate(t *testing.T) {
+	valid := ReleaseFile{
+		Filename: "go1.21.5.linux-amd64.tar.gz",
+		OS:       "linux",
+		Arch:     "amd64",
+		Kind:     "archive",
+		SHA256:   "e2bc0b3e4b64111ec117295c088bde5f00eeed1567999ff77bc859d7df70078e",
+		Size:     66618285,
+	}
+
+	testCases := []struct {
+		name      string
+		modify    func(f *ReleaseFile)
+		expectErr bool
+	}{
+		{name: "Valid", modify: func(f *ReleaseFile) {}},
+		{name: "Source without OS", modify: func(f *ReleaseFile) { f.Kind, f.OS, f.Arch = "source", "", "" }},
+		{name: "Empty size", modify: func(f *ReleaseFile) { f.Size = 0 }},
+		{name: "Empty filename", modify: func(f *ReleaseFile) { f.Filename = "" }, expectErr: true},
+		{name: "Path in filename", modify: func(f *ReleaseFile) { f.Filename = "../go.tar.gz" }, expectErr: true},
+		{name: "Backslash in filename", modify: func(f *ReleaseFile) { f.Filename = `dir\go.zip` }, expectErr: true},
+		{name: "Dot dot filename", modify: func(f *ReleaseFile) { f.Filename = ".." }, expectErr: true},
+		{name: "Missing OS", modify: func(f *ReleaseFile) { f.OS = "" }, expectErr: true},
+		{name: "Missing Arch", modify: func(f *ReleaseFile) { f.Arch = "" }, expectErr: true},
+		{name: "Missing SHA256", modify: func(f *ReleaseFile) { f.SHA256 = "" }, expectErr: true},
+		{name: "Short SHA256", modify: func(f *ReleaseFile) { f.SHA256 = f.SHA256[:63] }, expectErr: true},
+		{name: "Non-hex SHA256", modify: func(f *ReleaseFile) { f.SHA256 = strings.Repeat("z", 64) }, expectErr: true},
+		{name: "Negative size", modify: func(f *ReleaseFile) { f.Size = -1 }, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := valid
+			tc.modify(&file)
+
+			err := file.Validate()
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidReleaseFile) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidReleaseFile)
+			}
+		})
+	}
+}
+
+func TestDownloadURL(t *testing.T) {
+	testCases := []struct {
+		name          string
+		filename      string
+		baseURL       string
+		expectedURL   string
+		expectedError bool
+	}{
+		{
+			name:        "Default host",
+			filename:    "go1.21.5.linux-amd64.tar.gz",
+			expectedURL: "https://go.dev/dl/go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:        "go.dev with trailing slash",
+			filename:    "go1.21.5.linux-amd64.tar.gz",
+			baseURL:     "https://go.dev/dl/",
+			expectedURL: "https://go.dev/dl/go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:        "dl.google.com",
+			filename:    "go1.21.5.linux-amd64.tar.gz",
+			baseURL:     "https://dl.google.com/go",
+			expectedURL: "https://dl.google.com/go/go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:        "Special characters",
+			filename:    "go 1.21?#.tar.gz",
+			baseURL:     "https://dl.google.com/go",
+			expectedURL: "https://dl.google.com/go/go%201.21%3F%23.tar.gz",
+		},
+		{name: "Path separator", filename: "../go.tar.gz", expectedError: true},
+		{name: "Backslash", filename: "..\\go.tar.gz", expectedError: true},
+		{name: "Empty filename", filename: "", expectedError: true},
+		{name: "Malformed base URL", filename: "go.tar.gz", baseURL: "://bad", expectedError: true},
+		{name: "Relative base URL", filename: "go.tar.gz", baseURL: "go.dev/dl", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DownloadURL(ReleaseFile{Filename: tc.filename}, tc.baseURL)
+
+			if (err != nil) != tc.expectedError {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if got != tc.expectedURL {
+				t.Errorf("Unexpected URL.\n Got: %q\nWant: %q", got, tc.expectedURL)
+			}
+		})
+	}
+}
+
+func TestProgressHashWriterReset(t *testing.T) {
+	tw := NewProgressHashWriter(2, sha256.New())
+	tw.render = renderNone
+	tw.warnings = io.Discard
+
+	tw.Write([]byte("stale"))
+	tw.Reset()
+	tw.Write([]byte{0})
+
+	if tw.Written != 1 {
+		t.Errorf("Unexpected written.\n Got: %d\nWant: %d", tw.Written, 1)
+	}
+
+	want := "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"
+	if got := fmt.Sprintf("%x", tw.Hash.Sum(nil)); got != want {
+		t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", got, want)
+	}
+}
+
+func TestProgressHashWriterResetRender(t *testing.T) {
+	t.Run("terminal", func(t *testing.T) {
+		var out bytes.Buffer
+		tw := NewProgressHashWriter(100, sha256.New())
+		tw.now = newFakeClock().Now
+		tw.out = &out
+
+		// A completed attempt shows done, and the restart is shown at once, not throttled.
+		tw.Write(make([]byte, 100))
+		tw.Reset()
+		out.Reset()
+		tw.Write(make([]byte, 10))
+
+		if got, want := out.String(), "\r 10% ( 10 of 100) complete"; !strings.HasPrefix(got, want) {
+			t.Errorf("Unexpected progress.\n Got: %q\nWant: %q", got, want)
+		}
+
+		// The restarted download shows done again when it completes.
+		out.Reset()
+		tw.Write(make([]byte, 90))
+		if got := out.String(); !strings.Contains(got, "100% (100 of 100)") {
+			t.Errorf("Unexpected progress.\n Got: %q\nWant: 100%% (100 of 100)", got)
+		}
+	})
+
+	t.Run("milestones", func(t *testing.T) {
+		var log bytes.Buffer
+		tw := NewProgressHashWriter(100, sha256.New())
+		tw.out = io.Discard
+		if err := tw.setProgressMode(ProgressTerminal, 0, &log); err != nil {
+			t.Fatal(err)
+		}
+
+		tw.Write(make([]byte, 50))
+		tw.Reset()
+		log.Reset()
+
+		// The milestones passed by the abandoned attempt are logged again.
+		tw.Write(make([]byte, 20))
+		if got := strings.Count(log.String(), "download progress"); got != 1 {
+			t.Errorf("Unexpected progress lines.\n Got: %d\nWant: %d\n%s", got, 1, log.String())
+		}
+	})
+}
+
+func TestProgressHashWriterExceedsExpected(t *testing.T) {
+	var out, warnings bytes.Buffer
+
+	clock := newFakeClock()
+
+	w := NewProgressHashWriter(10, sha256.New())
+	w.now = clock.Now
+	w.out = &out
+	w.warnings = &warnings
+
+	w.Write(make([]byte, 10))
+	if warnings.Len() != 0 {
+		t.Errorf("Unexpected warning %q before exceeding expected", warnings.String())
+	}
+
+	clock.Advance(terminalProgressInterval)
+	w.Write(make([]byte, 90))
+	clock.Advance(terminalProgressInterval)
+	w.Write(make([]byte, 900))
+
+	lines := strings.Split(strings.TrimPrefix(out.String(), "\r"), "\r")
+	want := []string{
+		"100% (10 of 10) complete",
+		"100% (100 of 10) complete, 500 B/s",
+		"100% (1000 of 10) complete, 2.5 kB/s",
+	}
+
+	for i := range want {
+		if i >= len(lines) || strings.TrimRight(lines[i], " ") != want[i] {
+			t.Errorf("Unexpected progress.\n Got: %q\nWant: %q", lines, want)
+			break
+		}
+	}
+
+	if strings.Count(warnings.String(), "Warning") != 1 {
+		t.Errorf("Expected a single warning, got %q", warnings.String())
+	}
+}
+
+func TestDownloadReleaseMaxFileSize(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var requests atomic.Int32
+	files := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name             string
+		file             ReleaseFile
+		expectedRequests int32
+	}{
+		{
+			name: "Feed size exceeds limit",
+			file: ReleaseFile{
+				Filename: "testfile_1MB", OS: "linux", Arch: "amd64", Size: 1048576,
+				SHA256: "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e",
+			},
+		},
+		{
+			name: "Content-Length exceeds limit",
+			file: ReleaseFile{
+				Filename: "testfile_1MB", OS: "linux", Arch: "amd64", Size: 1,
+				SHA256: "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e",
+			},
+			expectedRequests: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests.Store(0)
+			opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), MaxFileSize: 1024, Progress: ProgressNone}
+
+			_, err := DownloadRelease(context.Background(), tc.file, opts)
+			if !errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrFileTooLarge)
+			}
+
+			if got := requests.Load(); got != tc.expectedRequests {
+				t.Errorf("Unexpected requests.\n Got: %d\nWant: %d", got, tc.expectedRequests)
+			}
+
+			entries, _ := os.ReadDir(opts.OutputDir)
+			if len(entries) != 0 {
+				t.Errorf("Unexpected files in output dir: %v", entries)
+			}
+		})
+	}
+}
+
+func TestProgressHashWriterThrottle(t *testing.T) {
+	var out bytes.Buffer
+
+	clock := newFakeClock()
+
+	w := NewProgressHashWriter(100, sha256.New())
+	w.now = clock.Now
+	w.out = &out
+
+	// The first write is shown, and the writes within the interval after it are not.
+	for i := 0; i < 10; i++ {
+		w.Write(make([]byte, 1))
+	}
+
+	clock.Advance(terminalProgressInterval)
+	w.Write(make([]byte, 1))
+
+	// Reaching the expected size is shown even within the interval.
+	w.Write(make([]byte, 89))
+
+	lines := strings.Split(strings.TrimPrefix(out.String(), "\r"), "\r")
+	want := []string{
+		"  1% (  1 of 100) complete",
+		" 11% ( 11 of 100) complete, 55 B/s, ETA 2s",
+		"100% (100 of 100) complete, 500 B/s",
+	}
+
+	if len(lines) != len(want) {
+		t.Fatalf("Unexpected progress.\n Got: %q\nWant: %q", lines, want)
+	}
+
+	for i := range want {
+		if strings.TrimRight(lines[i], " ") != want[i] {
+			t.Errorf("Unexpected progress.\n Got: %q\nWant: %q", lines[i], want[i])
+		}
+	}
+}
+
+func TestProgressHashWriterFlush(t *testing.T) {
+	var out bytes.Buffer
+
+	clock := newFakeClock()
+
+	// With an unknown size, the end of the download is not seen by Write.
+	w := NewProgressHashWriter(0, sha256.New())
+	w.now = clock.Now
+	w.out = &out
+
+	w.Write(make([]byte, 1))
+	w.Write(make([]byte, 1))
+	w.Flush()
+	w.Flush()
+
+	lines := strings.Split(strings.TrimPrefix(out.String(), "\r"), "\r")
+	if len(lines) != 2 || !strings.Contains(lines[1], "(2 of 0)") {
+		t.Errorf("Unexpected progress.\n Got: %q\nWant: a final line with 2 bytes written", lines)
+	}
+}
+
+func BenchmarkProgressHashWriterWrite(b *testing.B) {
+	data := make([]byte, 512)
+
+	b.Run("Throttled", func(b *testing.B) {
+		w := NewProgressHashWriter(int64(b.N)*int64(len(data)), sha256.New())
+		w.out = io.Discard
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			w.Write(data)
+		}
+	})
+
+	// EveryWrite renders on every Write, as before throttling, for comparison.
+	b.Run("EveryWrite", func(b *testing.B) {
+		w := NewProgressHashWriter(int64(b.N)*int64(len(data)), sha256.New())
+		w.out = io.Discard
+
+		clock := newFakeClock()
+		w.now = func() time.Time {
+			clock.Advance(terminalProgressInterval)
+			return clock.Now()
+		}
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			w.Write(data)
+		}
+	})
+}
+
+func TestDownloadReleaseNoClobber(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var requests atomic.Int32
+	files := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+		Size:     1,
+	}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), NoClobber: true, Progress: ProgressNone}
+
+	path := filepath.Join(opts.OutputDir, file.Filename)
+	if err := os.WriteFile(path, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := DownloadRelease(context.Background(), file, opts)
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrDestinationExists)
+	}
+
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: 0", got)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "keep me" {
+		t.Errorf("Unexpected destination.\n Got: %q, %v\nWant: %q", data, err, "keep me")
+	}
+
+	entries, _ := os.ReadDir(opts.OutputDir)
+	if len(entries) != 1 {
+		t.Errorf("Unexpected files in output dir: %v", entries)
+	}
+
+	// Without the existing file, the download goes ahead.
+	os.Remove(path)
+
+	if _, err := DownloadRelease(context.Background(), file, opts); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestDownloadReleaseChecksumRetries(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	newServer := func(corrupt bool) (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		files := http.FileServer(http.Dir("testdata"))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if corrupt {
+				w.Write([]byte("X"))
+				return
+			}
+			files.ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+
+	corrupt, corruptRequests := newServer(true)
+	corrupt2, corrupt2Requests := newServer(true)
+	good, goodRequests := newServer(false)
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+		Size:     1,
+	}
+
+	testCases := []struct {
+		name          string
+		mirrors       []string
+		retries       int
+		expectedError error
+		expectedHosts []string
+		expected      [3]int32 // Requests to corrupt, corrupt2, and good.
+	}{
+		{
+			name:     "Rotates to good mirror",
+			mirrors:  []string{good.URL},
+			retries:  1,
+			expected: [3]int32{1, 0, 1},
+		},
+		{
+			name:          "No retries",
+			mirrors:       []string{good.URL},
+			expectedError: ErrChecksumMismatch,
+			expected:      [3]int32{1, 0, 0},
+		},
+		{
+			name:          "All mirrors corrupt",
+			mirrors:       []string{corrupt2.URL},
+			retries:       2,
+			expectedError: ErrChecksumMismatch,
+			expectedHosts: []string{corrupt.Listener.Addr().String(), corrupt2.Listener.Addr().String()},
+			expected:      [3]int32{2, 1, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			corruptRequests.Store(0)
+			corrupt2Requests.Store(0)
+			goodRequests.Store(0)
+
+			opts := DownloadOptions{
+				BaseURL:         corrupt.URL,
+				Mirrors:         tc.mirrors,
+				ChecksumRetries: tc.retries,
+				OutputDir:       t.TempDir(),
+				NoClobber:       true,
+				Progress:        ProgressNone,
+			}
+
+			_, err := DownloadRelease(context.Background(), file, opts)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			for _, host := range tc.expectedHosts {
+				if err == nil || !strings.Contains(err.Error(), host) {
+					t.Errorf("Unexpected error.\n Got: %v\nWant: mention of %s", err, host)
+				}
+			}
+
+			got := [3]int32{corruptRequests.Load(), corrupt2Requests.Load(), goodRequests.Load()}
+			if got != tc.expected {
+				t.Errorf("Unexpected requests.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+
+			if tc.expectedError == nil {
+				err = VerifyLocalFile(filepath.Join(opts.OutputDir, file.Filename), file)
+				if err != nil {
+					t.Errorf("Unexpected error verifying download: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadReleaseExtraHashes(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	data, err := os.ReadFile("testdata/testfile_1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int32
+	files := http.FileServer(http.Dir("testdata"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sha256Sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	sha512Sum := fmt.Sprintf("%x", sha512.Sum512(data))
+
+	file := ReleaseFile{
+		Filename: "testfile_1MB",
+		OS:       "linux",
+		Arch:     "amd64",
+		SHA256:   sha256Sum,
+		Size:     int64(len(data)),
+	}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone, ExtraHashes: []string{"sha512"}}
+
+	r, err := DownloadRelease(context.Background(), file, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string]string{"sha256": sha256Sum, "sha512": sha512Sum}
+	if !reflect.DeepEqual(r.Digests, want) {
+		t.Errorf("Unexpected digests.\n Got: %v\nWant: %v", r.Digests, want)
+	}
+
+	if r.Checksum != sha256Sum {
+		t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", r.Checksum, sha256Sum)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: 1", got)
+	}
+
+	// An unknown algorithm is refused before downloading.
+	opts.ExtraHashes = []string{"md5"}
+	if _, err := DownloadRelease(context.Background(), file, opts); !errors.Is(err, ErrUnknownHashAlgo) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrUnknownHashAlgo)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: 1", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// dumpFeed writes the release feed data to w, exactly as fetched or, if indent is set,
+// pretty-printed. The feed is decoded first, so that only a feed that -feed-file would
+// accept is written.
+func dumpFeed(w io.Writer, data []byte, indent bool) error {
+	_, err := DecodeReleases(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if indent {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		data = buf.Bytes()
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// runDumpFeed implements the -dump-feed mode and returns the exit code.
+// The feed is read from feedFile if set, otherwise from feedURL, and written to dest,
+// or to stdout if dest is "-".
+func runDumpFeed(dest, feedURL, feedFile string, indent bool) int {
+	var data []byte
+	var err error
+	if feedFile != "" {
+		data, err = os.ReadFile(feedFile)
+	} else {
+		data, err = fetchBytes(feedURL)
+	}
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	if dest == "-" {
+		err = dumpFeed(os.Stdout, data, indent)
+	} else {
+		var buf bytes.Buffer
+		err = dumpFeed(&buf, data, indent)
+		if err == nil {
+			err = writeFileAtomic(dest, buf.Bytes())
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error dumping release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	return 0
+}
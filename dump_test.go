@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDumpFeed(t *testing.T) {
+	want, err := DecodeReleases(bytes.NewReader([]byte(testFeedJSON)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, indent := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		err := dumpFeed(&buf, []byte(testFeedJSON), indent)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !indent && buf.String() != testFeedJSON {
+			t.Errorf("Unexpected raw feed.\n Got: %s\nWant: %s", buf.String(), testFeedJSON)
+		}
+
+		got, err := DecodeReleases(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error decoding dumped feed (indent %v): %v", indent, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unexpected round trip (indent %v).\n Got: %+v\nWant: %+v", indent, got, want)
+		}
+	}
+}
+
+func TestDumpFeedInvalid(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := dumpFeed(&buf, []byte(`[]`), false)
+	if err == nil {
+		t.Errorf("Expected error for an empty feed")
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("Unexpected output.\n Got: %q\nWant: %q", buf.String(), "")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported archive format")
+	ErrUnsafePath         = errors.New("archive entry escapes the target directory")
+)
+
+// stripComponents removes the first n slash-separated elements from the archive entry name.
+// It reports false if name has no elements left after stripping, in which case the entry is skipped, like tar.
+func stripComponents(name string, n int) (string, bool) {
+	name = path.Clean(name)
+
+	for i := 0; i < n; i++ {
+		_, rest, found := strings.Cut(name, "/")
+		if !found {
+			return "", false
+		}
+		name = rest
+	}
+
+	return name, name != ""
+}
+
+// extractArchive extracts the .tar.gz or .tar.xz archive at archivePath into dir, dropping the first
+// strip path components of each entry, like tar --strip-components.
+// Entries that would be written outside of dir are rejected with ErrUnsafePath, including
+// entries written through a symlink, which could point anywhere once chained with others.
+func extractArchive(archivePath, dir string, strip int) error {
+	if !strings.HasSuffix(archivePath, ".tar.gz") && !strings.HasSuffix(archivePath, ".tar.xz") {
+		return fmt.Errorf("%w: %s", ErrUnsupportedArchive, filepath.Base(archivePath))
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, archivePath)
+	if err != nil {
+		return err
+	}
+
+	return extractTar(tar.NewReader(r), dir, strip)
+}
+
+// decompress returns a reader of the tar stream in r, the contents of the .tar.gz or .tar.xz
+// archive named name.
+func decompress(r io.Reader, name string) (io.Reader, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.xz"):
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedArchive, err)
+		}
+		return xr, nil
+	case strings.HasSuffix(name, ".tar.gz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedArchive, err)
+		}
+		return gz, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedArchive, filepath.Base(name))
+}
+
+// extractTar writes the entries of tr into dir, as described by extractArchive.
+func extractTar(tr *tar.Reader, dir string, strip int) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := stripComponents(hdr.Name, strip)
+		if !ok {
+			continue
+		}
+
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("%w: %q", ErrUnsafePath, hdr.Name)
+		}
+
+		err = checkNoSymlink(dir, name)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, hdr.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			err = writeTarFile(tr, target, hdr.FileInfo().Mode().Perm())
+		case tar.TypeSymlink:
+			// Resolve the link relative to its own directory to make sure it stays inside dir.
+			if filepath.IsAbs(hdr.Linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), hdr.Linkname)) {
+				return fmt.Errorf("%w: %q links to %q", ErrUnsafePath, hdr.Name, hdr.Linkname)
+			}
+			if err = os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		default:
+			// Go release archives only contain directories, files, and symlinks.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkNoSymlink returns ErrUnsafePath if name, or a directory on the way to it, already exists
+// in dir as a symlink, such as one extracted earlier. Following it could write outside of dir:
+// "d -> ." and then "d/e -> ../evil" each stay inside dir, but "d/e/x" would not.
+func checkNoSymlink(dir, name string) error {
+	p := dir
+	for _, elem := range strings.Split(name, "/") {
+		p = filepath.Join(p, elem)
+
+		fi, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: passes through symlink %q", ErrUnsafePath, p)
+		}
+	}
+
+	return nil
+}
+
+// writeTarFile copies the current entry of tr to target with the given permissions.
+func writeTarFile(tr *tar.Reader, target string, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0o755)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, tr)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
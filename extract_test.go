@@ -0,0 +1,229 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+// tarEntry describes an entry of a test archive.
+type tarEntry struct {
+	name     string
+	body     string
+	linkname string
+	dir      bool
+}
+
+// writeTestArchive writes a .tar.gz containing entries to a temporary directory and returns its path.
+func writeTestArchive(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+
+	return writeTestArchiveExt(t, entries, ".tar.gz")
+}
+
+// writeTestArchiveExt writes a .tar.gz or .tar.xz archive, depending on ext, containing entries.
+func writeTestArchiveExt(t *testing.T, entries []tarEntry, ext string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "go"+ext)
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var zw io.WriteCloser = gzip.NewWriter(f)
+	if ext == ".tar.xz" {
+		zw, err = xz.NewWriter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw := tar.NewWriter(zw)
+
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			hdr = &tar.Header{Name: e.name, Mode: 0o755, Typeflag: tar.TypeDir}
+		case e.linkname != "":
+			hdr = &tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: e.linkname}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestStripComponents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		entry    string
+		strip    int
+		expected string
+		ok       bool
+	}{
+		{name: "No strip", entry: "go/bin/go", strip: 0, expected: "go/bin/go", ok: true},
+		{name: "Strip one", entry: "go/bin/go", strip: 1, expected: "bin/go", ok: true},
+		{name: "Strip two", entry: "go/bin/go", strip: 2, expected: "go", ok: true},
+		{name: "Strip all", entry: "go/bin/go", strip: 3, ok: false},
+		{name: "Top directory", entry: "go/", strip: 1, ok: false},
+		{name: "Leading dot", entry: "./go/VERSION", strip: 1, expected: "VERSION", ok: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := stripComponents(tc.entry, tc.strip)
+
+			if got != tc.expected || ok != tc.ok {
+				t.Errorf("Unexpected result.\n Got: %q, %v\nWant: %q, %v", got, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	entries := []tarEntry{
+		{name: "go/", dir: true},
+		{name: "go/VERSION", body: "go1.21.5"},
+		{name: "go/bin/go", body: "binary"},
+	}
+
+	testCases := []struct {
+		name    string
+		strip   int
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "Standard layout",
+			strip:   0,
+			present: []string{"go/VERSION", "go/bin/go"},
+			absent:  []string{"VERSION"},
+		},
+		{
+			name:    "Strip go directory",
+			strip:   1,
+			present: []string{"VERSION", "bin/go"},
+			absent:  []string{"go"},
+		},
+	}
+
+	for _, ext := range []string{".tar.gz", ".tar.xz"} {
+		archive := writeTestArchiveExt(t, entries, ext)
+
+		for _, tc := range testCases {
+			t.Run(tc.name+" "+ext, func(t *testing.T) {
+				dir := t.TempDir()
+
+				err := extractArchive(archive, dir, tc.strip)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				for _, name := range tc.present {
+					if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+						t.Errorf("Expected %s to exist: %v", name, err)
+					}
+				}
+
+				for _, name := range tc.absent {
+					if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+						t.Errorf("Expected %s to not exist", name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries []tarEntry
+		strip   int
+	}{
+		{
+			name:    "Parent directory",
+			entries: []tarEntry{{name: "go/../../evil", body: "x"}},
+		},
+		{
+			name:    "Parent directory after strip",
+			entries: []tarEntry{{name: "go/../../../evil", body: "x"}},
+			strip:   1,
+		},
+		{
+			name:    "Absolute symlink",
+			entries: []tarEntry{{name: "go/link", linkname: "/etc/passwd"}},
+		},
+		{
+			name:    "Escaping symlink",
+			entries: []tarEntry{{name: "go/link", linkname: "../../outside"}},
+		},
+		{
+			name: "Chained symlinks",
+			entries: []tarEntry{
+				{name: "d", linkname: "."},
+				{name: "d/e", linkname: "../evil"},
+				{name: "d/e/x", body: "x"},
+			},
+		},
+		{
+			name: "File through symlink",
+			entries: []tarEntry{
+				{name: "go/link", linkname: "."},
+				{name: "go/link/file", body: "x"},
+			},
+		},
+		{
+			name: "Overwrite symlink",
+			entries: []tarEntry{
+				{name: "go/link", linkname: "file"},
+				{name: "go/link", body: "x"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "target")
+
+			err := extractArchive(writeTestArchive(t, tc.entries), dir, tc.strip)
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrUnsafePath)
+			}
+
+			if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+				t.Error("Expected nothing written outside of the target")
+			}
+		})
+	}
+}
+
+func TestExtractArchiveUnsupported(t *testing.T) {
+	err := extractArchive("go1.21.5.windows-amd64.zip", t.TempDir(), 0)
+	if !errors.Is(err, ErrUnsupportedArchive) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrUnsupportedArchive)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+)
+
+// Kinds of FeedDivergence.
+const (
+	DivergenceMissing  = "missing"  // The file is in the reference feed only.
+	DivergenceExtra    = "extra"    // The file is in the other feed only.
+	DivergenceChecksum = "mismatch" // The file is in both feeds with different checksums.
+)
+
+// FeedDivergence is a file that differs between a reference feed and another feed.
+type FeedDivergence struct {
+	Kind     string
+	Version  string
+	Filename string
+	Want     string // SHA256 in the reference feed, if present.
+	Got      string // SHA256 in the other feed, if present.
+}
+
+// feedKey identifies a file across feeds.
+type feedKey struct {
+	Version, Filename string
+}
+
+// feedChecksums maps each file in info to its SHA256.
+func feedChecksums(info ReleaseInfo) map[feedKey]string {
+	sums := make(map[feedKey]string)
+
+	for _, release := range info {
+		for _, file := range release.Files {
+			sums[feedKey{release.Version, file.Filename}] = file.SHA256
+		}
+	}
+
+	return sums
+}
+
+// diffFeeds compares the files of want, the reference feed, with those of got by version and
+// filename. It returns the number of files compared and the divergences sorted by version and
+// filename. Checksums are compared exactly, as the feed always uses lower case hex.
+func diffFeeds(want, got ReleaseInfo) (int, []FeedDivergence) {
+	wantSums, gotSums := feedChecksums(want), feedChecksums(got)
+
+	var divergences []FeedDivergence
+
+	for key, wantSum := range wantSums {
+		gotSum, ok := gotSums[key]
+		switch {
+		case !ok:
+			divergences = append(divergences, FeedDivergence{
+				Kind: DivergenceMissing, Version: key.Version, Filename: key.Filename, Want: wantSum,
+			})
+		case gotSum != wantSum:
+			divergences = append(divergences, FeedDivergence{
+				Kind: DivergenceChecksum, Version: key.Version, Filename: key.Filename, Want: wantSum, Got: gotSum,
+			})
+		}
+	}
+
+	for key, gotSum := range gotSums {
+		if _, ok := wantSums[key]; !ok {
+			divergences = append(divergences, FeedDivergence{
+				Kind: DivergenceExtra, Version: key.Version, Filename: key.Filename, Got: gotSum,
+			})
+		}
+	}
+
+	sort.Slice(divergences, func(i, j int) bool {
+		a, b := divergences[i], divergences[j]
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Filename < b.Filename
+	})
+
+	compared := len(wantSums)
+	for key := range gotSums {
+		if _, ok := wantSums[key]; !ok {
+			compared++
+		}
+	}
+
+	return compared, divergences
+}
+
+// printFeedDiff writes one tab-separated "kind<TAB>version<TAB>filename" line per divergence,
+// followed by the reference and other checksums of a mismatch, and then a summary line.
+func printFeedDiff(w io.Writer, compared int, divergences []FeedDivergence) {
+	for _, d := range divergences {
+		if d.Kind == DivergenceChecksum {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Kind, d.Version, d.Filename, d.Want, d.Got)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Kind, d.Version, d.Filename)
+	}
+
+	fmt.Fprintf(w, "%d files compared, %d divergent\n", compared, len(divergences))
+}
+
+// referenceFeedURL returns the official feed to compare otherURL with, listing all releases
+// if otherURL asks for all releases.
+func referenceFeedURL(otherURL string) string {
+	u, err := url.Parse(otherURL)
+	if err == nil && u.Query().Get("include") == "all" {
+		return allReleasesURL
+	}
+
+	return releaseURL
+}
+
+// runCompareFeeds implements the -compare-feeds mode and returns the exit code.
+// It returns ExitErrVerify if the feed at otherURL diverges from the official feed.
+func runCompareFeeds(otherURL string) int {
+	want, err := getReleaseInfo(referenceFeedURL(otherURL))
+	if err != nil {
+		fmt.Printf("Error getting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	got, err := getReleaseInfo(otherURL)
+	if err != nil {
+		fmt.Printf("Error getting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	compared, divergences := diffFeeds(want, got)
+	printFeedDiff(os.Stdout, compared, divergences)
+
+	if len(divergences) > 0 {
+		return ExitErrVerify
+	}
+
+	return 0
+}
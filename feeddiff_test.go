@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffFeeds(t *testing.T) {
+	const official = `[
+	{"version": "go1.21.5", "stable": true, "files": [
+		{"filename": "go1.21.5.linux-amd64.tar.gz", "sha256": "aaaa"},
+		{"filename": "go1.21.5.linux-arm64.tar.gz", "sha256": "bbbb"},
+		{"filename": "go1.21.5.src.tar.gz", "sha256": "cccc"}
+	]}
+]`
+	const mirror = `[
+	{"version": "go1.21.5", "stable": true, "files": [
+		{"filename": "go1.21.5.linux-amd64.tar.gz", "sha256": "aaaa"},
+		{"filename": "go1.21.5.linux-arm64.tar.gz", "sha256": "dddd"}
+	]}
+]`
+
+	want, err := DecodeReleases(strings.NewReader(official))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeReleases(strings.NewReader(mirror))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compared, divergences := diffFeeds(want, got)
+
+	var out bytes.Buffer
+	printFeedDiff(&out, compared, divergences)
+
+	expected := "mismatch\tgo1.21.5\tgo1.21.5.linux-arm64.tar.gz\tbbbb\tdddd\n" +
+		"missing\tgo1.21.5\tgo1.21.5.src.tar.gz\n" +
+		"3 files compared, 2 divergent\n"
+
+	if out.String() != expected {
+		t.Errorf("Unexpected diff.\n Got: %q\nWant: %q", out.String(), expected)
+	}
+
+	compared, divergences = diffFeeds(want, want)
+	if compared != 3 || len(divergences) != 0 {
+		t.Errorf("Unexpected diff of identical feeds.\n Got: %d compared, %v\nWant: 3 compared, none", compared, divergences)
+	}
+}
+
+func TestDiffFeedsExtra(t *testing.T) {
+	want := ReleaseInfo{{Version: "go1.21.5", Files: []ReleaseFile{{Filename: "a", SHA256: "aaaa"}}}}
+	got := ReleaseInfo{{Version: "go1.21.5", Files: []ReleaseFile{{Filename: "a", SHA256: "aaaa"}, {Filename: "b", SHA256: "bbbb"}}}}
+
+	compared, divergences := diffFeeds(want, got)
+	if compared != 2 || len(divergences) != 1 || divergences[0].Kind != DivergenceExtra || divergences[0].Filename != "b" {
+		t.Errorf("Unexpected diff.\n Got: %d compared, %+v\nWant: 2 compared, extra b", compared, divergences)
+	}
+}
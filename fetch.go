@@ -0,0 +1,203 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+
+// Fetcher opens the content of a URL for download, such as a file on IPFS or a magnet link.
+//
+// Fetch returns the content and its length in bytes, or -1 if the length is not known.
+// The caller closes the content. The download is verified against the feed as usual, so
+// a Fetcher does not need to check what it returns.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (io.ReadCloser, int64, error)
+}
+
+// fetchers maps lowercase URL schemes to the Fetcher that downloads them.
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{
+		"http":  httpFetcher{},
+		"https": httpFetcher{},
+	}
+)
+
+// RegisterFetcher makes f download URLs with scheme, e.g. "ipfs", replacing any Fetcher
+// registered for it before, including the built-in one for http and https.
+func RegisterFetcher(scheme string, f Fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+
+	fetchers[strings.ToLower(scheme)] = f
+}
+
+// fetcher returns the Fetcher of the scheme of rawURL. The built-in http fetcher uses the
+// client of opts. It returns ErrUnsupportedScheme if no Fetcher is registered for the scheme,
+// and ErrInsecureURL for an insecure URL, see checkSecureURL.
+func (opts DownloadOptions) fetcher(rawURL string) (Fetcher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+
+	fetchersMu.RLock()
+	f, ok := fetchers[scheme]
+	fetchersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, rawURL)
+	}
+
+	if hf, ok := f.(httpFetcher); ok {
+		err = checkSecureURL(rawURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if hf.client == nil {
+			hf.client = opts.client()
+		}
+		f = hf
+	}
+
+	return f, nil
+}
+
+// fetchedContent is content opened by a Fetcher, possibly partway into the file.
+type fetchedContent struct {
+	Body    io.ReadCloser
+	Length  int64     // Bytes in Body, or -1 if not known.
+	Offset  int64     // Position in the file that Body starts at.
+	ModTime time.Time // Modification time of the file, or zero if not known.
+}
+
+// rangeFetcher is a Fetcher that can also continue an interrupted download.
+type rangeFetcher interface {
+	// FetchFrom opens url starting at offset, if it can, or else from the start.
+	FetchFrom(ctx context.Context, url string, offset int64) (fetchedContent, error)
+}
+
+// chunkFetcher is a Fetcher that can also fetch a byte range, for a download in verified chunks.
+type chunkFetcher interface {
+	// FetchRange opens bytes start to end, inclusive, of url.
+	FetchRange(ctx context.Context, url string, start, end int64) (io.ReadCloser, error)
+}
+
+// fetchFrom opens url with f starting at offset, if f is a rangeFetcher that can, or else
+// from the start of the file.
+func fetchFrom(ctx context.Context, f Fetcher, url string, offset int64) (fetchedContent, error) {
+	if rf, ok := f.(rangeFetcher); ok {
+		return rf.FetchFrom(ctx, url, offset)
+	}
+
+	body, length, err := f.Fetch(ctx, url)
+	if err != nil {
+		return fetchedContent{}, err
+	}
+
+	return fetchedContent{Body: body, Length: length}, nil
+}
+
+// httpFetcher is the built-in Fetcher of http and https URLs.
+// It continues interrupted downloads with range requests.
+type httpFetcher struct {
+	client *http.Client // If nil, the shared client is used.
+}
+
+// Fetch implements Fetcher.
+func (f httpFetcher) Fetch(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	content, err := f.FetchFrom(ctx, url, 0)
+	return content.Body, content.Length, err
+}
+
+// get sends a GET request for url with the Range header set to byteRange, if not empty.
+func (f httpFetcher) get(ctx context.Context, url, byteRange string) (*http.Response, error) {
+	client := f.client
+	if client == nil {
+		client = httpClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	return client.Do(req)
+}
+
+// FetchFrom implements rangeFetcher with a range request. If the server ignores the range,
+// the whole file is returned.
+func (f httpFetcher) FetchFrom(ctx context.Context, url string, offset int64) (fetchedContent, error) {
+	var byteRange string
+	if offset > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	resp, err := f.get(ctx, url, byteRange)
+	if err != nil {
+		return fetchedContent{}, err
+	}
+
+	content := fetchedContent{Body: resp.Body, Length: resp.ContentLength}
+
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		content.ModTime = modTime
+	}
+
+	// Check for successful response.
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return fetchedContent{}, fmt.Errorf("%q unexpected Content-Range %q",
+				url, resp.Header.Get("Content-Range"))
+		}
+		content.Offset = offset
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored any range and sent the whole file.
+	default:
+		resp.Body.Close()
+		return fetchedContent{}, fmt.Errorf("%q %s", url, http.StatusText(resp.StatusCode))
+	}
+
+	return content, nil
+}
+
+// FetchRange implements chunkFetcher with a range request, which the server must honor.
+func (f httpFetcher) FetchRange(ctx context.Context, url string, start, end int64) (io.ReadCloser, error) {
+	resp, err := f.get(ctx, url, fmt.Sprintf("bytes=%d-%d", start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%q range %d-%d: %s", url, start, end, http.StatusText(resp.StatusCode))
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-%d/", start, end)) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%q unexpected Content-Range %q", url, resp.Header.Get("Content-Range"))
+	}
+
+	return resp.Body, nil
+}
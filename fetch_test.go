@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memFetcher is a Fetcher of files held in memory, keyed by URL.
+type memFetcher map[string][]byte
+
+func (m memFetcher) Fetch(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	data, ok := m[url]
+	if !ok {
+		return nil, 0, fmt.Errorf("%q not found", url)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// registerTestFetcher registers f for scheme for the duration of the test.
+func registerTestFetcher(t *testing.T, scheme string, f Fetcher) {
+	t.Helper()
+
+	RegisterFetcher(scheme, f)
+	t.Cleanup(func() {
+		fetchersMu.Lock()
+		defer fetchersMu.Unlock()
+		delete(fetchers, scheme)
+	})
+}
+
+func TestDownloadReleaseFetcher(t *testing.T) {
+	data := []byte("contents of go1.21.5.linux-amd64.tar.gz")
+
+	registerTestFetcher(t, "mem", memFetcher{
+		"mem://store/go1.21.5.linux-amd64.tar.gz": data,
+		"mem://store/go1.21.5.linux-arm64.tar.gz": []byte("corrupted"),
+	})
+
+	file := ReleaseFile{
+		Filename: "go1.21.5.linux-amd64.tar.gz",
+		OS:       "linux",
+		Arch:     "amd64",
+		Version:  "go1.21.5",
+		SHA256:   fmt.Sprintf("%x", sha256.Sum256(data)),
+		Size:     int64(len(data)),
+	}
+
+	corrupt := file
+	corrupt.Filename = "go1.21.5.linux-arm64.tar.gz"
+	corrupt.Arch = "arm64"
+
+	testCases := []struct {
+		name          string
+		baseURL       string
+		file          ReleaseFile
+		expectedError error
+	}{
+		{name: "Registered scheme", baseURL: "mem://store", file: file},
+		{name: "Verified", baseURL: "mem://store", file: corrupt, expectedError: ErrChecksumMismatch},
+		{name: "Fetch error", baseURL: "mem://other", file: file, expectedError: ErrDownloadFailed},
+		{name: "Unregistered scheme", baseURL: "ipfs://store", file: file, expectedError: ErrUnsupportedScheme},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DownloadOptions{BaseURL: tc.baseURL, OutputDir: t.TempDir(), Progress: ProgressNone}
+
+			r, err := DownloadRelease(context.Background(), tc.file, opts)
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if tc.expectedError != nil {
+				return
+			}
+
+			if r.Checksum != file.SHA256 {
+				t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", r.Checksum, file.SHA256)
+			}
+
+			got, err := os.ReadFile(filepath.Join(opts.OutputDir, file.Filename))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("Unexpected file.\n Got: %q, %v\nWant: %q", got, err, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// GitHubOutputs are the step outputs written in -github-output mode.
+//
+// The keys emitted, in order, are:
+//
+//	latest_version    version selected from the feed, e.g. go1.21.5
+//	current_version   version of the running Go, e.g. go1.21.4
+//	update_available  "true" if latest_version differs from current_version, otherwise "false"
+//	downloaded_file   path of the downloaded file, or empty if nothing was downloaded
+type GitHubOutputs struct {
+	LatestVersion   string
+	CurrentVersion  string
+	UpdateAvailable bool
+	DownloadedFile  string
+}
+
+// WriteTo writes the outputs as key=value lines to w.
+func (o GitHubOutputs) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w,
+		"latest_version=%s\ncurrent_version=%s\nupdate_available=%t\ndownloaded_file=%s\n",
+		o.LatestVersion, o.CurrentVersion, o.UpdateAvailable, o.DownloadedFile)
+
+	return int64(n), err
+}
+
+// emitGitHubOutputs appends the outputs to the file named by the GITHUB_OUTPUT environment variable.
+// If GITHUB_OUTPUT is unset, the outputs are written to stdout when fallback is set and skipped otherwise.
+func emitGitHubOutputs(o GitHubOutputs, fallback bool) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		if !fallback {
+			fmt.Println("GITHUB_OUTPUT is not set, skipping GitHub outputs.")
+			return nil
+		}
+
+		_, err := o.WriteTo(os.Stdout)
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_OUTPUT: %w", err)
+	}
+
+	_, err = o.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write GITHUB_OUTPUT: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmitGitHubOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "github_output")
+	if err := os.WriteFile(path, []byte("existing=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	outputs := GitHubOutputs{
+		LatestVersion:   "go1.21.5",
+		CurrentVersion:  "go1.21.4",
+		UpdateAvailable: true,
+		DownloadedFile:  "go1.21.5.linux-amd64.tar.gz",
+	}
+
+	if err := emitGitHubOutputs(outputs, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "existing=1\n" +
+		"latest_version=go1.21.5\n" +
+		"current_version=go1.21.4\n" +
+		"update_available=true\n" +
+		"downloaded_file=go1.21.5.linux-amd64.tar.gz\n"
+
+	if string(got) != want {
+		t.Errorf("Unexpected output.\n Got: %q\nWant: %q", got, want)
+	}
+}
@@ -1,3 +1,14 @@
 module github.com/bnixon67/go-latest-version
 
-go 1.16
+go 1.23.0
+
+require (
+	github.com/ProtonMail/go-crypto v1.5.1
+	github.com/ulikunitz/xz v0.5.17
+	golang.org/x/sys v0.35.0
+)
+
+require (
+	github.com/cloudflare/circl v1.6.3 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+)
@@ -0,0 +1,71 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrHookFailed  = errors.New("post-download command failed")
+	ErrInvalidHook = errors.New("invalid post-download command")
+)
+
+// Environment variables set for the post-download command.
+const (
+	envHookFile    = "GO_DL_FILE"
+	envHookVersion = "GO_DL_VERSION"
+	envHookSHA256  = "GO_DL_SHA256"
+)
+
+// parseHookCommand splits a -post-download-cmd value into its arguments at spaces.
+// Quoting is not supported; use a script for anything more involved.
+func parseHookCommand(s string) ([]string, error) {
+	args := strings.Fields(s)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: empty command", ErrInvalidHook)
+	}
+
+	return args, nil
+}
+
+// runPostDownloadHook runs the command args after file was downloaded and verified at path.
+// The placeholders {file} and {version} in args are replaced by path and the version of file,
+// and GO_DL_FILE, GO_DL_VERSION, and GO_DL_SHA256 are added to its environment.
+// The output of the command goes to stdout and stderr. A non-zero exit status is reported as
+// ErrHookFailed.
+func runPostDownloadHook(args []string, path string, file ReleaseFile, stdout, stderr io.Writer) error {
+	expand := strings.NewReplacer("{file}", path, "{version}", file.Version)
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = expand.Replace(arg)
+	}
+
+	cmd := exec.Command(expanded[0], expanded[1:]...)
+	cmd.Env = append(os.Environ(),
+		envHookFile+"="+path,
+		envHookVersion+"="+file.Version,
+		envHookSHA256+"="+file.SHA256,
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("%w: %s: exit status %d", ErrHookFailed, expanded[0], exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHookFailed, err)
+	}
+
+	return nil
+}
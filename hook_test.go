@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestRunPostDownloadHook(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	file := ReleaseFile{
+		Filename: "go1.21.5.linux-amd64.tar.gz",
+		Version:  "go1.21.5",
+		SHA256:   "e2bc0b3e4b64111ec117295c088bde5f00eeed1567999ff77bc859d7df70078e",
+	}
+
+	testCases := []struct {
+		name          string
+		args          []string
+		expected      string
+		expectedError error
+	}{
+		{
+			name:     "Environment",
+			args:     []string{"sh", "-c", `printf '%s %s %s' "$GO_DL_FILE" "$GO_DL_VERSION" "$GO_DL_SHA256"`},
+			expected: "out/" + file.Filename + " go1.21.5 " + file.SHA256,
+		},
+		{
+			name:     "Placeholders",
+			args:     []string{"echo", "{file}", "{version}"},
+			expected: "out/" + file.Filename + " go1.21.5\n",
+		},
+		{
+			name:          "Non-zero exit",
+			args:          []string{"sh", "-c", "exit 3"},
+			expectedError: ErrHookFailed,
+		},
+		{
+			name:          "Missing command",
+			args:          []string{"go-latest-version-no-such-command"},
+			expectedError: ErrHookFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			err := runPostDownloadHook(tc.args, "out/"+file.Filename, file, &stdout, &stdout)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got := stdout.String(); got != tc.expected {
+				t.Errorf("Unexpected output.\n Got: %q\nWant: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseHookCommand(t *testing.T) {
+	args, err := parseHookCommand("  upload.sh  {file} ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(args) != 2 || args[0] != "upload.sh" || args[1] != "{file}" {
+		t.Errorf("Unexpected args.\n Got: %q\nWant: %q", args, []string{"upload.sh", "{file}"})
+	}
+
+	_, err = parseHookCommand("  ")
+	if !errors.Is(err, ErrInvalidHook) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidHook)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrEmbeddedVersion = errors.New("embedded version does not match")
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ArchiveInspection describes a release archive read by inspectArchive.
+type ArchiveInspection struct {
+	Size             int64  // Size of the compressed archive.
+	Checksum         string // Hex SHA256 checksum of the compressed archive.
+	UncompressedSize int64  // Size of the uncompressed tar stream.
+	ContentChecksum  string // Hex SHA256 checksum of the uncompressed tar stream.
+	Version          string // First line of go/VERSION, or "" if the archive has none.
+}
+
+// CompressionRatio returns UncompressedSize divided by Size, or 0 if Size is 0.
+func (a ArchiveInspection) CompressionRatio() float64 {
+	if a.Size == 0 {
+		return 0
+	}
+
+	return float64(a.UncompressedSize) / float64(a.Size)
+}
+
+// CompressionSummary describes the compressed and uncompressed sizes of the archive,
+// e.g. "148 MB compressed → 412 MB uncompressed (2.8x)".
+func (a ArchiveInspection) CompressionSummary() string {
+	return fmt.Sprintf("%s compressed → %s uncompressed (%.1fx)",
+		formatBytes(a.Size), formatBytes(a.UncompressedSize), a.CompressionRatio())
+}
+
+// formatBytes formats n using decimal units, e.g. 148 MB, with one decimal place below 10.
+func formatBytes(n int64) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	unit := ""
+	for _, u := range []string{"kB", "MB", "GB", "TB"} {
+		value /= 1000
+		unit = u
+		if value < 999.5 {
+			break
+		}
+	}
+
+	if value < 9.95 {
+		return fmt.Sprintf("%.1f %s", value, unit)
+	}
+
+	return fmt.Sprintf("%.0f %s", value, unit)
+}
+
+// inspectArchive reads the .tar.gz or .tar.xz archive named name from r in a single pass.
+// The compressed bytes are hashed as they are read and decompressed on the fly, so the archive
+// is neither read twice nor extracted to disk.
+func inspectArchive(r io.Reader, name string) (ArchiveInspection, error) {
+	var inspection ArchiveInspection
+
+	counter := &countingReader{r: r}
+	compressed := sha256.New()
+	tee := io.TeeReader(counter, compressed)
+
+	tr, err := decompress(tee, name)
+	if err != nil {
+		return inspection, err
+	}
+
+	uncompressed := &countingReader{r: tr}
+	content := sha256.New()
+	archive := tar.NewReader(io.TeeReader(uncompressed, content))
+
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return inspection, fmt.Errorf("%w: %w", ErrUnsupportedArchive, err)
+		}
+
+		if hdr.Typeflag == tar.TypeReg && path.Clean(hdr.Name) == "go/VERSION" {
+			line, err := bufio.NewReader(archive).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return inspection, err
+			}
+			inspection.Version = strings.TrimSpace(line)
+		}
+	}
+
+	// Hash the padding after the end of the tar stream and the rest of the compressed file.
+	_, err = io.Copy(io.Discard, uncompressed)
+	if err == nil {
+		_, err = io.Copy(io.Discard, tee)
+	}
+	if err != nil {
+		return inspection, err
+	}
+
+	inspection.Size = counter.n
+	inspection.Checksum = fmt.Sprintf("%x", compressed.Sum(nil))
+	inspection.UncompressedSize = uncompressed.n
+	inspection.ContentChecksum = fmt.Sprintf("%x", content.Sum(nil))
+
+	return inspection, nil
+}
+
+// verifyArchive inspects the archive at path and verifies its checksum and size against file
+// and that its embedded version is file.Version.
+func verifyArchive(path string, file ReleaseFile) (ArchiveInspection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ArchiveInspection{}, err
+	}
+	defer f.Close()
+
+	inspection, err := inspectArchive(f, path)
+	if err != nil {
+		return inspection, err
+	}
+
+	err = checkChecksumAndSize(file, inspection.Size, inspection.Checksum)
+	if err != nil {
+		return inspection, err
+	}
+
+	if inspection.Version != file.Version {
+		return inspection, fmt.Errorf("%w: %q, want %q", ErrEmbeddedVersion, inspection.Version, file.Version)
+	}
+
+	return inspection, nil
+}
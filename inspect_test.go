@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInspectArchive(t *testing.T) {
+	entries := []tarEntry{
+		{name: "go/", dir: true},
+		{name: "go/VERSION", body: "go1.21.5\ntime 2023-11-29T21:21:53Z\n"},
+		{name: "go/bin/go", body: "binary"},
+	}
+
+	for _, ext := range []string{".tar.gz", ".tar.xz"} {
+		t.Run(ext, func(t *testing.T) {
+			archive := writeTestArchiveExt(t, entries, ext)
+
+			size, checksum, err := hashFile(archive, sha256.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			tr, err := decompress(f, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := sha256.New()
+			uncompressed, err := io.Copy(content, tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Seek(0, io.SeekStart)
+
+			got, err := inspectArchive(f, archive)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			want := ArchiveInspection{
+				Size:             size,
+				Checksum:         checksum,
+				UncompressedSize: uncompressed,
+				ContentChecksum:  fmt.Sprintf("%x", content.Sum(nil)),
+				Version:          "go1.21.5",
+			}
+			if got != want {
+				t.Errorf("Unexpected inspection.\n Got: %+v\nWant: %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestVerifyArchive(t *testing.T) {
+	archive := writeTestArchive(t, []tarEntry{{name: "go/VERSION", body: "go1.21.5\n"}})
+
+	size, checksum, err := hashFile(archive, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		file          ReleaseFile
+		expectedError error
+	}{
+		{
+			name: "Verified",
+			file: ReleaseFile{Version: "go1.21.5", SHA256: checksum, Size: size},
+		},
+		{
+			name:          "Checksum mismatch",
+			file:          ReleaseFile{Version: "go1.21.5", SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Size: size},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:          "Version mismatch",
+			file:          ReleaseFile{Version: "go1.21.4", SHA256: checksum, Size: size},
+			expectedError: ErrEmbeddedVersion,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifyArchive(archive, tc.file)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestInspectArchiveCompression(t *testing.T) {
+	archive := writeTestArchive(t, []tarEntry{
+		{name: "go/VERSION", body: "go1.21.5\n"},
+		{name: "go/README", body: strings.Repeat("The Go Programming Language\n", 1000)},
+	})
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := inspectArchive(f, archive)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size != info.Size() {
+		t.Errorf("Unexpected size.\n Got: %d\nWant: %d", got.Size, info.Size())
+	}
+
+	// Three 512-byte headers and blocks for the entries, 28000 bytes of README padded to
+	// 28160, and two zero blocks at the end.
+	const wantUncompressed = 512 + 512 + 512 + 28160 + 1024
+	if got.UncompressedSize != wantUncompressed {
+		t.Errorf("Unexpected uncompressed size.\n Got: %d\nWant: %d", got.UncompressedSize, wantUncompressed)
+	}
+
+	want := fmt.Sprintf("%s compressed → 31 kB uncompressed (%.1fx)",
+		formatBytes(got.Size), float64(wantUncompressed)/float64(got.Size))
+	if summary := got.CompressionSummary(); summary != want {
+		t.Errorf("Unexpected summary.\n Got: %q\nWant: %q", summary, want)
+	}
+	if got.CompressionRatio() <= 1 {
+		t.Errorf("Unexpected ratio.\n Got: %v\nWant: > 1", got.CompressionRatio())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1000, "1.0 kB"},
+		{31_000, "31 kB"},
+		{148_000_000, "148 MB"},
+		{412_345_678, "412 MB"},
+		{999_600, "1.0 MB"},
+		{2_500_000_000, "2.5 GB"},
+	}
+
+	for _, tc := range testCases {
+		if got := formatBytes(tc.n); got != tc.want {
+			t.Errorf("Unexpected format of %d.\n Got: %q\nWant: %q", tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotGoInstall = errors.New("not a Go install")
+
+// goInstallMarkers are the paths, relative to a GOROOT, that every Go install has.
+var goInstallMarkers = []string{"bin/go", "VERSION"}
+
+// installInstructions returns the command that installs the archive at path for goos,
+// or an empty string if goos uses an installer instead.
+func installInstructions(goos, path string) string {
+	if goos == "windows" || goos == "darwin" {
+		return ""
+	}
+
+	// Official archives use gzip, but mirrors may offer xz.
+	flags := "-xzf"
+	if strings.HasSuffix(path, ".tar.xz") {
+		flags = "-xJf"
+	}
+
+	cmd := fmt.Sprintf("rm -rf /usr/local/go && tar -C /usr/local %s %s", flags, shellQuote(path))
+
+	return "sudo -- sh -c " + shellQuote(cmd)
+}
+
+// shellQuote returns s quoted as a single word for a POSIX shell, or s itself if it has
+// no characters the shell would interpret, such as the spaces of a Downloads folder.
+func shellQuote(s string) string {
+	safe := s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("@%+=:,./-_", r))
+	}) < 0
+	if safe {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// muslLoaderPattern matches the dynamic loader of musl-based systems such as Alpine.
+const muslLoaderPattern = "/lib/ld-musl-*"
+
+// isMusl reports whether the system uses musl libc rather than glibc.
+// The glob function, normally filepath.Glob, is used to look for the musl loader.
+func isMusl(glob func(pattern string) ([]string, error)) bool {
+	matches, err := glob(muslLoaderPattern)
+	return err == nil && len(matches) > 0
+}
+
+// muslWarning is shown on musl systems before the install instructions.
+const muslWarning = `This system appears to use musl libc (e.g. Alpine).
+The official Go archives are built for glibc and may not run as-is.
+Install a glibc compatibility layer (e.g. apk add gcompat) or build Go from source,
+see https://go.dev/doc/install/source`
+
+// printInstallInstructions writes to w how to install file, downloaded to path, on host.
+// A file for another platform, or for a special platform such as js/wasm, is reported as a
+// cross-target download instead, as it cannot be installed as the Go of host.
+func printInstallInstructions(w io.Writer, file ReleaseFile, path string, host Platform) {
+	target := Platform{OS: file.OS, Arch: file.Arch}
+	if file.OS != "" && isCrossTarget(target, host) {
+		fmt.Fprintf(w, "Downloaded %s for %s, a cross-target download, so it is not installed on %s.\n",
+			path, target, host)
+		return
+	}
+
+	cmd := installInstructions(host.OS, path)
+	if cmd == "" {
+		return
+	}
+
+	if host.OS == "linux" && isMusl(filepath.Glob) {
+		warn(WarnMusl, "%s", muslWarning)
+	}
+
+	fmt.Fprintln(w, "Run the following command to install:")
+	fmt.Fprintln(w, cmd)
+}
+
+// archiveFilename returns the name of the official archive of version for goos and goarch,
+// e.g. go1.21.5.linux-amd64.tar.gz.
+func archiveFilename(version, goos, goarch string) string {
+	return fmt.Sprintf("%s.%s-%s.tar.gz", version, goos, goarch)
+}
+
+// runPrintInstallCommand prints the install command for the archive selected by criteria
+// in outputDir, without downloading or verifying anything.
+// If criteria.Version is set, the official filename is derived from it without network access.
+// Otherwise, the latest release is looked up in the feed at feedURL.
+func runPrintInstallCommand(criteria SelectCriteria, feedURL, outputDir string) int {
+	filename := archiveFilename(criteria.Version, criteria.OS, criteria.Arch)
+
+	if criteria.Version == "" {
+		fmt.Println("No -version given, looking up the latest release in the feed.")
+
+		releaseInfo, err := loadReleaseInfo(feedURL)
+		if err != nil {
+			fmt.Printf("Error gettting release info: %v\n", err)
+			return ExitErrReleaseInfo
+		}
+
+		criteria.Kinds = []string{"archive"}
+
+		file, err := SelectFile(releaseInfo, criteria)
+		if err != nil {
+			fmt.Printf("Error finding matching release file: %v\n", err)
+			return ExitErrMatchFile
+		}
+
+		filename = file.Filename
+	}
+
+	cmd := installInstructions(criteria.OS, filepath.Join(outputDir, filename))
+	if cmd == "" {
+		fmt.Printf("No install command for %s, use the installer instead.\n", criteria.OS)
+		return 0
+	}
+
+	fmt.Println(cmd)
+
+	return 0
+}
+
+// curlCommand returns a shell command that downloads file from baseURL into outputDir with curl
+// and verifies its SHA256 checksum with sha256sum, equivalent to what this tool does.
+func curlCommand(file ReleaseFile, baseURL, outputDir string) (string, error) {
+	url, err := DownloadURL(file, baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(outputDir, file.Filename)
+
+	return fmt.Sprintf("curl -L %s -o %s && echo %s | sha256sum -c",
+		shellQuote(url), shellQuote(path), shellQuote(file.SHA256+"  "+path)), nil
+}
+
+// runPrintCurl prints the curl command for the file selected by criteria from the feed at
+// feedURL, without downloading it.
+func runPrintCurl(criteria SelectCriteria, feedURL, baseURL, outputDir string) int {
+	releaseInfo, err := loadReleaseInfo(feedURL)
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	file, err := SelectFile(releaseInfo, criteria)
+	if err != nil {
+		fmt.Printf("Error finding matching release file: %v\n", err)
+		return ExitErrMatchFile
+	}
+
+	cmd, err := curlCommand(file, baseURL, outputDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitErrUsage
+	}
+
+	fmt.Println(cmd)
+
+	return 0
+}
+
+// goRootIn returns the GOROOT that extracting an archive into dir, dropping strip leading path
+// components, creates, or an empty string if the entries are not kept under a single root.
+func goRootIn(dir string, strip int) string {
+	switch strip {
+	case 0:
+		return filepath.Join(dir, "go")
+	case 1:
+		return dir
+	}
+
+	return ""
+}
+
+// findGoInstallMarkers returns which of goInstallMarkers are found in root and which are missing.
+func findGoInstallMarkers(root string) (found, missing []string) {
+	for _, marker := range goInstallMarkers {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(marker)))
+		if err == nil && info.Mode().IsRegular() {
+			found = append(found, marker)
+		} else {
+			missing = append(missing, marker)
+		}
+	}
+
+	return found, missing
+}
+
+// removeGoInstall removes the existing Go install at root, so that extracting a new release
+// does not leave files of the old one behind. It does nothing if root does not exist or is an
+// empty directory. To avoid removing an unrelated directory, it returns ErrNotGoInstall if root is missing any
+// of goInstallMarkers, unless force is set.
+func removeGoInstall(root string, force bool) error {
+	if _, err := os.Lstat(root); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if entries, err := os.ReadDir(root); err == nil && len(entries) == 0 {
+		return nil
+	}
+
+	found, missing := findGoInstallMarkers(root)
+	logger.Info("existing install", "dir", root, "found", found, "missing", missing)
+
+	if len(missing) > 0 && !force {
+		return fmt.Errorf("%w: %q has no %s, use -force-install to replace it anyway",
+			ErrNotGoInstall, root, strings.Join(missing, " or "))
+	}
+
+	return os.RemoveAll(root)
+}
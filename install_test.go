@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsMusl(t *testing.T) {
+	testCases := []struct {
+		name     string
+		glob     func(string) ([]string, error)
+		expected bool
+	}{
+		{
+			name:     "musl loader present",
+			glob:     func(string) ([]string, error) { return []string{"/lib/ld-musl-x86_64.so.1"}, nil },
+			expected: true,
+		},
+		{
+			name:     "no musl loader",
+			glob:     func(string) ([]string, error) { return nil, nil },
+			expected: false,
+		},
+		{
+			name:     "glob error",
+			glob:     func(string) ([]string, error) { return nil, errors.New("bad pattern") },
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isMusl(tc.glob); got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestInstallInstructions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		goos     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "linux gzip",
+			goos:     "linux",
+			path:     archiveFilename("go1.21.5", "linux", "amd64"),
+			expected: `sudo -- sh -c 'rm -rf /usr/local/go && tar -C /usr/local -xzf go1.21.5.linux-amd64.tar.gz'`,
+		},
+		{
+			name:     "freebsd xz",
+			goos:     "freebsd",
+			path:     "go1.21.5.freebsd-amd64.tar.xz",
+			expected: `sudo -- sh -c 'rm -rf /usr/local/go && tar -C /usr/local -xJf go1.21.5.freebsd-amd64.tar.xz'`,
+		},
+		{
+			name:     "path with space",
+			goos:     "linux",
+			path:     "/home/gopher/My Downloads/go1.21.5.linux-amd64.tar.gz",
+			expected: `sudo -- sh -c 'rm -rf /usr/local/go && tar -C /usr/local -xzf '\''/home/gopher/My Downloads/go1.21.5.linux-amd64.tar.gz'\'''`,
+		},
+		{
+			name:     "darwin uses installer",
+			goos:     "darwin",
+			path:     archiveFilename("go1.21.5", "darwin", "arm64"),
+			expected: "",
+		},
+		{
+			name:     "windows uses installer",
+			goos:     "windows",
+			path:     "go1.21.5.windows-amd64.msi",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := installInstructions(tc.goos, tc.path); got != tc.expected {
+				t.Errorf("Unexpected command.\n Got: %q\nWant: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCurlCommand(t *testing.T) {
+	file, err := SelectFile(testReleaseInfo, SelectCriteria{Version: "go1.21.5", OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := curlCommand(file, "https://go.dev/dl/", "out")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"curl -L https://go.dev/dl/" + file.Filename + " ",
+		"-o out/" + file.Filename,
+		`echo '` + file.SHA256 + `  out/` + file.Filename + `' | sha256sum -c`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Unexpected command.\n Got: %s\nWant substring: %s", got, want)
+		}
+	}
+
+	got, err = curlCommand(file, "https://go.dev/dl/", "My Downloads")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"-o 'My Downloads/" + file.Filename + "' ",
+		`echo '` + file.SHA256 + `  My Downloads/` + file.Filename + `' | sha256sum -c`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Unexpected command.\n Got: %s\nWant substring: %s", got, want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		s, want string
+	}{
+		{"/usr/local/go1.21.5.linux-amd64.tar.gz", "/usr/local/go1.21.5.linux-amd64.tar.gz"},
+		{"", "''"},
+		{"My Downloads/go.tar.gz", "'My Downloads/go.tar.gz'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME/go", "'$HOME/go'"},
+	}
+
+	for _, tc := range testCases {
+		if got := shellQuote(tc.s); got != tc.want {
+			t.Errorf("Unexpected quoting of %q.\n Got: %s\nWant: %s", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestPrintInstallInstructionsCrossTarget(t *testing.T) {
+	info := ReleaseInfo{{
+		Version: "go1.21.5",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{Filename: "go1.21.5.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.js-wasm.tar.gz", OS: "js", Arch: "wasm", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.windows-amd64.zip", OS: "windows", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+		},
+	}}
+
+	host := Platform{OS: "linux", Arch: "amd64"}
+
+	testCases := []struct {
+		name     string
+		target   Platform
+		host     Platform
+		expected string
+	}{
+		{
+			name:     "js/wasm",
+			target:   Platform{OS: "js", Arch: "wasm"},
+			host:     host,
+			expected: "Downloaded go1.21.5.js-wasm.tar.gz for js/wasm, a cross-target download, so it is not installed on linux/amd64.\n",
+		},
+		{
+			name:     "js/wasm on a wasm host",
+			target:   Platform{OS: "js", Arch: "wasm"},
+			host:     Platform{OS: "js", Arch: "wasm"},
+			expected: "Downloaded go1.21.5.js-wasm.tar.gz for js/wasm, a cross-target download, so it is not installed on js/wasm.\n",
+		},
+		{
+			name:     "Other OS",
+			target:   Platform{OS: "windows", Arch: "amd64"},
+			host:     host,
+			expected: "Downloaded go1.21.5.windows-amd64.zip for windows/amd64, a cross-target download, so it is not installed on linux/amd64.\n",
+		},
+		{
+			name:     "Native",
+			target:   host,
+			host:     host,
+			expected: "Run the following command to install:\n" + installInstructions("linux", "go1.21.5.linux-amd64.tar.gz") + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := SelectFile(info, SelectCriteria{OS: tc.target.OS, Arch: tc.target.Arch})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var out bytes.Buffer
+			printInstallInstructions(&out, file, file.Filename, tc.host)
+
+			// The musl warning goes to stderr, so it does not affect the output.
+			if out.String() != tc.expected {
+				t.Errorf("Unexpected output.\n Got: %q\nWant: %q", out.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestRemoveGoInstall(t *testing.T) {
+	writeFiles := func(t *testing.T, root string, names ...string) {
+		for _, name := range names {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		files   []string // Files in the existing directory, or nil if it does not exist.
+		force   bool
+		want    error
+		removed bool
+	}{
+		{"go install", []string{"bin/go", "VERSION", "src/fmt/print.go"}, false, nil, true},
+		{"not go", []string{"notes.txt", "bin/tool"}, false, ErrNotGoInstall, false},
+		{"no version", []string{"bin/go"}, false, ErrNotGoInstall, false},
+		{"not go forced", []string{"notes.txt"}, true, nil, true},
+		{"missing", nil, false, nil, true},
+		{"empty", []string{}, false, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "go")
+			if tc.files != nil {
+				if err := os.Mkdir(root, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				writeFiles(t, root, tc.files...)
+			}
+
+			err := removeGoInstall(root, tc.force)
+			if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.want)
+			}
+
+			_, err = os.Stat(root)
+			if removed := errors.Is(err, os.ErrNotExist); removed != tc.removed {
+				t.Errorf("Unexpected removed.\n Got: %v\nWant: %v", removed, tc.removed)
+			}
+		})
+	}
+}
+
+func TestFindGoInstallMarkers(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "VERSION"), []byte("go1.21.5"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, missing := findGoInstallMarkers(root)
+	if strings.Join(found, ",") != "VERSION" || strings.Join(missing, ",") != "bin/go" {
+		t.Errorf("Unexpected markers.\n Got: %v, %v\nWant: %v, %v", found, missing, []string{"VERSION"}, []string{"bin/go"})
+	}
+}
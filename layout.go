@@ -0,0 +1,87 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Install layouts accepted by -install-layout.
+const (
+	LayoutGOROOT = "goroot" // The archive is extracted into the install directory as is.
+	LayoutAsdf   = "asdf"   // <base>/installs/golang/<version>/go, as used by asdf-golang.
+	LayoutGVM    = "gvm"    // <base>/gos/go<version>, as used by gvm.
+)
+
+var ErrInvalidLayout = errors.New("invalid install layout")
+
+// layoutBase returns the default base directory of layout when -install-dir is not set,
+// honoring the environment variables of the version managers.
+func layoutBase(layout string, getenv func(string) string) (string, error) {
+	var envVar, dir string
+
+	switch layout {
+	case LayoutAsdf:
+		envVar, dir = "ASDF_DATA_DIR", ".asdf"
+	case LayoutGVM:
+		envVar, dir = "GVM_ROOT", ".gvm"
+	default:
+		return "", fmt.Errorf("%w: %q needs -install-dir", ErrInvalidLayout, layout)
+	}
+
+	if base := getenv(envVar); base != "" {
+		return base, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, dir), nil
+}
+
+// installTarget returns the directory to extract the archive of version into for layout, and
+// the number of leading path components to strip, given the base directory and -strip-components.
+// Go archives hold a single top-level go directory, which asdf keeps and gvm drops.
+func installTarget(layout, base, version string, strip int) (dir string, n int, err error) {
+	switch layout {
+	case LayoutGOROOT, "":
+		return base, strip, nil
+	case LayoutAsdf, LayoutGVM:
+		if strip != 0 {
+			return "", 0, fmt.Errorf("%w: -strip-components is set by %s", ErrInvalidLayout, layout)
+		}
+	default:
+		return "", 0, fmt.Errorf("%w: %q, want %s, %s, or %s", ErrInvalidLayout, layout, LayoutGOROOT, LayoutAsdf, LayoutGVM)
+	}
+
+	if !strings.HasPrefix(version, "go") || !isSafeFilename(version) {
+		return "", 0, fmt.Errorf("%w: unexpected version %q", ErrInvalidLayout, version)
+	}
+
+	if layout == LayoutAsdf {
+		return filepath.Join(base, "installs", "golang", strings.TrimPrefix(version, "go")), 0, nil
+	}
+
+	return filepath.Join(base, "gos", version), 1, nil
+}
+
+// prepareInstallTarget checks that base is an existing directory and creates dir within it.
+func prepareInstallTarget(base, dir string) error {
+	info, err := os.Stat(base)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLayout, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %q is not a directory", ErrInvalidLayout, base)
+	}
+
+	return os.MkdirAll(dir, 0o755)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallTarget(t *testing.T) {
+	base := filepath.Join("home", "user", ".asdf")
+
+	testCases := []struct {
+		name          string
+		layout        string
+		strip         int
+		expectedDir   string
+		expectedStrip int
+		expectedError error
+	}{
+		{name: "GOROOT", layout: LayoutGOROOT, expectedDir: base},
+		{name: "GOROOT with strip", layout: LayoutGOROOT, strip: 1, expectedDir: base, expectedStrip: 1},
+		{name: "asdf", layout: LayoutAsdf, expectedDir: filepath.Join(base, "installs", "golang", "1.21.5")},
+		{name: "gvm", layout: LayoutGVM, expectedDir: filepath.Join(base, "gos", "go1.21.5"), expectedStrip: 1},
+		{name: "asdf with strip", layout: LayoutAsdf, strip: 1, expectedError: ErrInvalidLayout},
+		{name: "Unknown", layout: "nix", expectedError: ErrInvalidLayout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, n, err := installTarget(tc.layout, base, "go1.21.5", tc.strip)
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if dir != tc.expectedDir || n != tc.expectedStrip {
+				t.Errorf("Unexpected target.\n Got: %q, %d\nWant: %q, %d", dir, n, tc.expectedDir, tc.expectedStrip)
+			}
+		})
+	}
+}
+
+func TestLayoutBase(t *testing.T) {
+	env := map[string]string{"ASDF_DATA_DIR": "/opt/asdf"}
+	getenv := func(key string) string { return env[key] }
+
+	got, err := layoutBase(LayoutAsdf, getenv)
+	if err != nil || got != "/opt/asdf" {
+		t.Errorf("Unexpected base.\n Got: %q, %v\nWant: %q", got, err, "/opt/asdf")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	got, err = layoutBase(LayoutGVM, getenv)
+	if want := filepath.Join(home, ".gvm"); err != nil || got != want {
+		t.Errorf("Unexpected base.\n Got: %q, %v\nWant: %q", got, err, want)
+	}
+
+	_, err = layoutBase(LayoutGOROOT, getenv)
+	if !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidLayout)
+	}
+}
+
+func TestPrepareInstallTarget(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "installs", "golang", "1.21.5")
+
+	if err := prepareInstallTarget(base, dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("Expected %s to be created: %v", dir, err)
+	}
+
+	missing := filepath.Join(base, "missing")
+	if err := prepareInstallTarget(missing, filepath.Join(missing, "gos")); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidLayout)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrLockTimeout = errors.New("timed out waiting for install lock")
+
+// lockPollInterval is how often a held install lock is retried.
+const lockPollInterval = 100 * time.Millisecond
+
+// installLockPath returns the lockfile guarding installs into target.
+// It is kept in the parent directory so it survives target being removed and recreated.
+func installLockPath(target string) string {
+	target = filepath.Clean(target)
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".lock")
+}
+
+// acquireInstallLock takes an exclusive lock on the lockfile for target, waiting up to
+// timeout for another install to finish. A zero timeout fails immediately if the lock is held.
+// The returned function releases the lock.
+func acquireInstallLock(target string, timeout time.Duration) (release func() error, err error) {
+	path := installLockPath(target)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open install lock: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %q: %w", path, err)
+		}
+
+		if locked {
+			return func() error {
+				unlockFile(f)
+				return f.Close()
+			}, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: %q is held by another install", ErrLockTimeout, path)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
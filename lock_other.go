@@ -0,0 +1,18 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// tryLockFile always succeeds, warning that concurrent installs are not prevented;
+// file locking is only implemented on unix systems and windows.
+func tryLockFile(f *os.File) (bool, error) {
+	warn(WarnNoLock, "install lock %q is not enforced on this system", f.Name())
+	return true, nil
+}
+
+// unlockFile does nothing, see tryLockFile.
+func unlockFile(f *os.File) {}
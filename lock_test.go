@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAcquireInstallLock(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("file locking is only implemented on unix")
+	}
+
+	target := filepath.Join(t.TempDir(), "go")
+
+	release, err := acquireInstallLock(target, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A second install fails while the lock is held, after waiting for the timeout.
+	errc := make(chan error)
+	go func() {
+		_, err := acquireInstallLock(target, 3*lockPollInterval)
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrLockTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Second lock did not time out")
+	}
+
+	// A waiting install proceeds once the lock is released.
+	go func() {
+		release2, err := acquireInstallLock(target, 5*time.Second)
+		if err == nil {
+			release2()
+		}
+		errc <- err
+	}()
+
+	time.Sleep(2 * lockPollInterval)
+
+	if err := release(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := <-errc; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrInvalidLockfile = errors.New("invalid lockfile")
+
+// lockfileFormat is the version of the lockfile format written by writeLockfile.
+const lockfileFormat = 1
+
+// Lockfile pins a release file and where to download it from, so that later runs download
+// exactly that file without consulting the release feed. It is written by -write-lock and
+// read by -lock as JSON, with the fields of the file in the feed plus the format and URL:
+//
+//	{
+//	  "format": 1,
+//	  "filename": "go1.22.3.linux-amd64.tar.gz",
+//	  "os": "linux",
+//	  "arch": "amd64",
+//	  "version": "go1.22.3",
+//	  "sha256": "8920ea521bad8f6b7bc377b4824982e011c19af27df88a815e3586ea895f1b36",
+//	  "size": 68958945,
+//	  "kind": "archive",
+//	  "url": "https://go.dev/dl/go1.22.3.linux-amd64.tar.gz"
+//	}
+type Lockfile struct {
+	Format int `json:"format"`
+	ReleaseFile
+	URL string `json:"url"`
+}
+
+// newLockfile returns the Lockfile of file, downloaded from baseURL as by DownloadURL.
+func newLockfile(file ReleaseFile, baseURL string) (Lockfile, error) {
+	fullURL, err := DownloadURL(file, baseURL)
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	return Lockfile{Format: lockfileFormat, ReleaseFile: file, URL: fullURL}, nil
+}
+
+// BaseURL returns the URL that the file is downloaded relative to, see DownloadURL.
+// It returns ErrInvalidLockfile if the lockfile is not valid or its URL does not end with
+// the filename.
+func (l Lockfile) BaseURL() (string, error) {
+	if l.Format != lockfileFormat {
+		return "", fmt.Errorf("%w: format %d, want %d", ErrInvalidLockfile, l.Format, lockfileFormat)
+	}
+
+	err := l.ReleaseFile.Validate()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidLockfile, err)
+	}
+
+	u, err := url.Parse(l.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w: unexpected url %q", ErrInvalidLockfile, l.URL)
+	}
+
+	dir, name := path.Split(u.Path)
+	if name != l.Filename {
+		return "", fmt.Errorf("%w: url %q is not of %s", ErrInvalidLockfile, l.URL, l.Filename)
+	}
+
+	u.Path = strings.TrimSuffix(dir, "/")
+	u.RawPath = ""
+	baseURL := u.String()
+
+	// The URL must be exactly the one the file is downloaded from.
+	if fullURL, err := DownloadURL(l.ReleaseFile, baseURL); err != nil || fullURL != l.URL {
+		return "", fmt.Errorf("%w: unexpected url %q", ErrInvalidLockfile, l.URL)
+	}
+
+	return baseURL, nil
+}
+
+// writeLockfile writes lock to path as indented JSON.
+func writeLockfile(path string, lock Lockfile) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// readLockfile reads and validates the lockfile at path.
+func readLockfile(path string) (Lockfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Lockfile{}, err
+	}
+	defer f.Close()
+
+	var lock Lockfile
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&lock); err != nil {
+		return Lockfile{}, fmt.Errorf("%w: %s: %w", ErrInvalidLockfile, path, err)
+	}
+
+	if _, err := lock.BaseURL(); err != nil {
+		return Lockfile{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return lock, nil
+}
+
+// downloadOptions returns opts changed to download the locked file only from the URL in the
+// lockfile, ignoring BaseURL and Mirrors. The lockfile must have been read by readLockfile.
+func (l Lockfile) downloadOptions(opts DownloadOptions) DownloadOptions {
+	opts.BaseURL, _ = l.BaseURL()
+	opts.Mirrors = nil
+
+	return opts
+}
+
+// releaseInfo returns a release feed holding only the locked file, which stands in for the feed
+// when it is not fetched. A beta or rc is still refused by checkStableRelease.
+func (l Lockfile) releaseInfo() ReleaseInfo {
+	return ReleaseInfo{{Version: l.Version, Stable: true, Files: []ReleaseFile{l.ReleaseFile}}}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	// httptest servers use plain http
+	setAllowInsecure(t, true)
+
+	var requests int
+	server := httptest.NewServer(http.StripPrefix("/dl", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeFile(w, r, filepath.Join("testdata", r.URL.Path))
+	})))
+	defer server.Close()
+
+	file := ReleaseFile{
+		Filename: "testfile_1B",
+		OS:       "linux",
+		Arch:     "amd64",
+		Version:  "go1.22.3",
+		SHA256:   "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+		Size:     1,
+		Kind:     "archive",
+	}
+
+	lock, err := newLockfile(file, server.URL+"/dl")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "go.lock")
+	if err := writeLockfile(path, lock); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := readLockfile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := Lockfile{Format: lockfileFormat, ReleaseFile: file, URL: server.URL + "/dl/testfile_1B"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected lockfile.\n Got: %+v\nWant: %+v", got, want)
+	}
+
+	// The -base-url and -mirror options are ignored in favor of the locked URL.
+	outputDir := t.TempDir()
+	opts := got.downloadOptions(DownloadOptions{OutputDir: outputDir, BaseURL: "https://go.dev/dl",
+		Mirrors: []string{"https://example.com/go"}, Progress: ProgressNone})
+
+	result, err := DownloadRelease(context.Background(), got.ReleaseFile, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.Checksum != file.SHA256 || result.Path != filepath.Join(outputDir, file.Filename) {
+		t.Errorf("Unexpected result.\n Got: %s, %s\nWant: %s, %s", result.Path, result.Checksum,
+			filepath.Join(outputDir, file.Filename), file.SHA256)
+	}
+
+	if requests != 1 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: %d", requests, 1)
+	}
+
+	// The lockfile stands in for the feed, so the locked file is selected and checked against it.
+	info := got.releaseInfo()
+	if err := checkStableRelease(info, file.Version, false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// A file that does not match the recorded checksum is rejected.
+	lock.SHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if err := writeLockfile(path, lock); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = readLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts = got.downloadOptions(DownloadOptions{OutputDir: t.TempDir(), Progress: ProgressNone})
+	if _, err := DownloadRelease(context.Background(), got.ReleaseFile, opts); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestReadLockfileInvalid(t *testing.T) {
+	valid := `"filename": "go1.22.3.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "version": "go1.22.3",
+		"sha256": "8920ea521bad8f6b7bc377b4824982e011c19af27df88a815e3586ea895f1b36", "size": 68958945, "kind": "archive"`
+
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"format", `{"format": 2, ` + valid + `, "url": "https://go.dev/dl/go1.22.3.linux-amd64.tar.gz"}`},
+		{"other file", `{"format": 1, ` + valid + `, "url": "https://go.dev/dl/go1.22.2.linux-amd64.tar.gz"}`},
+		{"relative url", `{"format": 1, ` + valid + `, "url": "dl/go1.22.3.linux-amd64.tar.gz"}`},
+		{"query", `{"format": 1, ` + valid + `, "url": "https://go.dev/dl/go1.22.3.linux-amd64.tar.gz?x=1"}`},
+		{"checksum", `{"format": 1, "filename": "go1.22.3.linux-amd64.tar.gz", "os": "linux", "arch": "amd64",
+			"sha256": "bad", "url": "https://go.dev/dl/go1.22.3.linux-amd64.tar.gz"}`},
+		{"unknown field", `{"format": 1, ` + valid + `, "url": "https://go.dev/dl/go1.22.3.linux-amd64.tar.gz", "mirror": "x"}`},
+		{"malformed", `{"format": 1,`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "go.lock")
+			if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := readLockfile(path)
+			if !errors.Is(err, ErrInvalidLockfile) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidLockfile)
+			}
+		})
+	}
+}
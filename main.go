@@ -4,15 +4,24 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
 )
 
 // ReleaseFile represents a file available on the go.dev downloads page.
@@ -27,23 +36,122 @@ type ReleaseFile struct {
 	Kind     string `json:"kind"`
 }
 
-// ReleaseInfo represents a collection of Go releases with associated files.
+// Release represents a Go release with associated files.
 // See https://pkg.go.dev/golang.org/x/website/internal/dl#Release
-type ReleaseInfo []struct {
+type Release struct {
 	Version string        `json:"version"`
 	Stable  bool          `json:"stable"`
 	Files   []ReleaseFile `json:"files"`
 }
 
+// ReleaseInfo represents a collection of Go releases with associated files.
+type ReleaseInfo []Release
+
 const (
 	downloadPrefixURL = "https://go.dev/dl"
 	releaseURL        = downloadPrefixURL + "/?mode=json"
+	allReleasesURL    = releaseURL + "&include=all"
 )
 
-// getReleaseInfo gets the latest Go release information from the official URL.
+var (
+	ErrVersionNotFound = errors.New("version not found")
+	ErrNoReleases      = errors.New("no releases available")
+	ErrInvalidRelease  = errors.New("invalid release")
+	ErrUnknownChecksum = errors.New("no file with checksum")
+	ErrConflictingFile = errors.New("conflicting duplicate file")
+	ErrEmptyFeed       = errors.New("empty response")
+	ErrMalformedFeed   = errors.New("malformed JSON")
+)
+
+// findRelease returns the release with the given version.
+func findRelease(releaseInfo ReleaseInfo, version string) (Release, error) {
+	for _, release := range releaseInfo {
+		if release.Version == version {
+			return release, nil
+		}
+	}
+
+	return Release{}, fmt.Errorf("%w: %s", ErrVersionNotFound, version)
+}
+
+// findFileByChecksum returns the file of any release whose SHA256 is checksum, compared case-insensitively.
+func findFileByChecksum(releaseInfo ReleaseInfo, checksum string) (ReleaseFile, error) {
+	for _, release := range releaseInfo {
+		for _, file := range release.Files {
+			if strings.EqualFold(file.SHA256, checksum) {
+				return file, nil
+			}
+		}
+	}
+
+	return ReleaseFile{}, fmt.Errorf("%w: %s", ErrUnknownChecksum, checksum)
+}
+
+// fallbackReleaseURLs are feeds tried in order by getReleaseInfo when the requested feed
+// cannot be read, such as mirrors. Set by the -fallback-release-url flag.
+var fallbackReleaseURLs []string
+
+// releaseFeedFile is a saved release feed read instead of the network by loadReleaseInfo.
+// Set by the -feed-file flag.
+var releaseFeedFile string
+
+// loadReleaseInfo gets the release information of every mode: from releaseFeedFile if set,
+// otherwise from feedURL or its fallbacks by getReleaseInfo.
+func loadReleaseInfo(feedURL string) (ReleaseInfo, error) {
+	if releaseFeedFile != "" {
+		return readReleaseInfoFile(releaseFeedFile)
+	}
+
+	return getReleaseInfo(feedURL)
+}
+
+// getReleaseInfo gets the latest Go release information from releaseURL, or if that fails,
+// from the first of fallbackReleaseURLs that succeeds. Each URL is retried by the shared
+// client before moving on to the next. If all fail, the errors of all are returned.
 // It returns a ReleaseInfo object containing details about available releases.
 func getReleaseInfo(releaseURL string) (ReleaseInfo, error) {
-	resp, err := http.Get(releaseURL)
+	releaseInfo, err := fetchReleaseInfo(releaseURL)
+	if err == nil {
+		return releaseInfo, nil
+	}
+
+	return fallbackReleaseInfo(err)
+}
+
+// fallbackReleaseInfo gets the release information from the first of fallbackReleaseURLs
+// that succeeds, after the requested feed failed with err. If all fail, or there are none,
+// the errors of all are returned.
+func fallbackReleaseInfo(err error) (ReleaseInfo, error) {
+	if len(fallbackReleaseURLs) == 0 {
+		return nil, err
+	}
+
+	errs := []error{err}
+
+	for _, fallbackURL := range fallbackReleaseURLs {
+		logger.Info("release feed failed, trying fallback", "url", fallbackURL, "error", errs[len(errs)-1])
+
+		releaseInfo, err := fetchReleaseInfo(fallbackURL)
+		if err == nil {
+			logger.Info("using fallback release feed", "url", fallbackURL)
+			return releaseInfo, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// fetchReleaseInfo gets the release information from releaseURL.
+func fetchReleaseInfo(releaseURL string) (ReleaseInfo, error) {
+	err := checkSecureURL(releaseURL)
+	if err != nil {
+		return nil,
+			fmt.Errorf("failed to get release info: %w", err)
+	}
+
+	resp, err := httpClient.Get(releaseURL)
 	if err != nil {
 		return nil,
 			fmt.Errorf("failed to get release info: %w", err)
@@ -56,114 +164,1313 @@ func getReleaseInfo(releaseURL string) (ReleaseInfo, error) {
 				releaseURL, http.StatusText(resp.StatusCode))
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return DecodeReleases(resp.Body)
+}
+
+// readReleaseInfoFile reads a release feed saved at path, such as the output of
+// curl "https://go.dev/dl/?mode=json&include=all", for use without network access.
+func readReleaseInfoFile(path string) (ReleaseInfo, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil,
-			fmt.Errorf("failed to read release info: %w", err)
+			fmt.Errorf("failed to get release info: %w", err)
 	}
+	defer f.Close()
+
+	return DecodeReleases(f)
+}
+
+// strictFeed makes DecodeReleases reject feeds with fields it does not know. Set by the -strict-feed flag.
+//
+// By default unknown fields are ignored, so that additions upstream do not break older
+// versions. Strict mode instead surfaces any change in the shape of the feed as an error.
+var strictFeed bool
 
+// DecodeReleases parses a release feed as it is read from r.
+//
+// It checks that:
+//
+//   - r holds a single JSON array of releases and nothing else,
+//   - no release or file has a field unknown to Release or ReleaseFile, if strictFeed is set,
+//   - the array lists at least one release, otherwise ErrNoReleases is returned, and
+//   - every release has a version and at least one file, otherwise ErrInvalidRelease is returned.
+//
+// Releases listed more than once are merged, see mergeDuplicateReleases.
+//
+// A body with no JSON at all returns ErrEmptyFeed. Any other body that is not a valid feed,
+// such as one truncated by a proxy, returns ErrMalformedFeed. Both include the number of bytes
+// received, and syntax and type errors also include the byte offset of the problem.
+func DecodeReleases(r io.Reader) (ReleaseInfo, error) {
 	var releaseInfo ReleaseInfo
 
-	err = json.Unmarshal(body, &releaseInfo)
+	counter := &countingReader{r: r}
+
+	// received returns the size of the body, reading the rest of it if needed.
+	received := func() int64 {
+		io.Copy(io.Discard, counter)
+		return counter.n
+	}
+
+	dec := json.NewDecoder(counter)
+	if strictFeed {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(&releaseInfo)
+	if err == io.EOF {
+		return nil,
+			fmt.Errorf("failed to unmarshal release info: %w, %d bytes received", ErrEmptyFeed, received())
+	}
 	if err != nil {
 		return nil,
-			fmt.Errorf("failed to unmarshal release info: %w", err)
+			fmt.Errorf("failed to unmarshal release info%s: %w, %d bytes received: %w",
+				errorOffset(err), ErrMalformedFeed, received(), err)
 	}
 
-	return releaseInfo, nil
-}
+	// Like json.Unmarshal, reject anything after the feed.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil,
+			fmt.Errorf("failed to unmarshal release info at offset %d: %w, %d bytes received: unexpected data after feed",
+				dec.InputOffset(), ErrMalformedFeed, received())
+	}
 
-// findMatchingReleaseFile returns the release file for the current system's OS and architecture.
-func findMatchingReleaseFile(releaseInfo ReleaseInfo) (ReleaseFile, error) {
-	kind := "archive"
+	if len(releaseInfo) == 0 {
+		return nil,
+			fmt.Errorf("failed to get release info: %w", ErrNoReleases)
+	}
 
-	// for windows and darwin, prefer installer over archive
-	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
-		kind = "installer"
+	for i, release := range releaseInfo {
+		switch {
+		case release.Version == "":
+			return nil,
+				fmt.Errorf("failed to get release info: %w: release %d has no version", ErrInvalidRelease, i)
+		case len(release.Files) == 0:
+			return nil,
+				fmt.Errorf("failed to get release info: %w: %s has no files", ErrInvalidRelease, release.Version)
+		}
+	}
+
+	releaseInfo, err = mergeDuplicateReleases(releaseInfo)
+	if err != nil {
+		return nil,
+			fmt.Errorf("failed to get release info: %w", err)
 	}
 
+	return releaseInfo, nil
+}
+
+// mergeDuplicateReleases merges releases with the same version into the first of them,
+// so selection does not depend on which duplicate comes first. The merged release is stable
+// if any of the duplicates is, and lists each file once. Duplicates are logged with -trace.
+// It returns ErrConflictingFile if two duplicates list the same filename with different
+// checksums or sizes, as there is no way to tell which is right.
+func mergeDuplicateReleases(releaseInfo ReleaseInfo) (ReleaseInfo, error) {
+	index := make(map[string]int, len(releaseInfo))
+	merged := releaseInfo[:0:0]
+
 	for _, release := range releaseInfo {
+		i, ok := index[release.Version]
+		if !ok {
+			index[release.Version] = len(merged)
+			merged = append(merged, release)
+			continue
+		}
+
+		logger.Warn("duplicate release in feed", "version", release.Version)
+
+		first := &merged[i]
+		first.Stable = first.Stable || release.Stable
+
 		for _, file := range release.Files {
-			if file.OS == runtime.GOOS && file.Arch == runtime.GOARCH && file.Kind == kind {
-				return file, nil
+			j := slices.IndexFunc(first.Files, func(f ReleaseFile) bool { return f.Filename == file.Filename })
+			switch {
+			case j < 0:
+				first.Files = append(first.Files, file)
+			case first.Files[j].SHA256 != file.SHA256 || first.Files[j].Size != file.Size:
+				return nil, fmt.Errorf("%w: %s in %s has checksums %s and %s",
+					ErrConflictingFile, file.Filename, release.Version, first.Files[j].SHA256, file.SHA256)
 			}
 		}
 	}
 
-	return ReleaseFile{}, fmt.Errorf("no matching file found for OS: %s, Arch: %s", runtime.GOOS, runtime.GOARCH)
+	return merged, nil
+}
+
+// errorOffset describes the input offset of a JSON syntax or type error, if err is one.
+func errorOffset(err error) string {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprintf(" at offset %d", syntaxErr.Offset)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf(" at offset %d", typeErr.Offset)
+	}
+
+	return ""
+}
+
+// findMatchingReleaseFile returns the release file for the current system's OS and architecture.
+func findMatchingReleaseFile(releaseInfo ReleaseInfo) (ReleaseFile, error) {
+	return SelectFile(releaseInfo, SelectCriteria{OS: runtime.GOOS, Arch: runtime.GOARCH})
 }
 
 // downloadAndVerifyFile downloads a Go release file and verifies its integrity.
 // It checks the SHA256 checksum and file size against the provided metadata.
-func downloadAndVerifyFile(file ReleaseFile) error {
-	fullURL, err := url.JoinPath(downloadPrefixURL, file.Filename)
-	if err != nil {
-		return fmt.Errorf("failed to join path: %w", err)
-	}
+// If skipIfValid is set and the file already exists with the expected checksum and size, the download is skipped
+// and the result has only the Path set.
+func downloadAndVerifyFile(file ReleaseFile, skipIfValid bool, opts DownloadOptions) (DownloadResult, error) {
+	path := filepath.Join(opts.OutputDir, file.Filename)
 
-	size, checksum, err := DownloadFileWithProgressAndChecksum(fullURL, file.Filename, file.Size, sha256.New())
+	err := opts.checkManifest(file)
 	if err != nil {
-		return fmt.Errorf("download failed: %w", err)
-
+		return DownloadResult{}, err
 	}
 
-	if file.SHA256 != checksum {
-		return fmt.Errorf("checksum incorrect: got %v want %v",
-			checksum, file.SHA256)
+	if skipIfValid {
+		if _, err := VerifyFile(file, path, VerifyOptions{}); err == nil {
+			fmt.Printf("%s already present and verified.\n", path)
+			return DownloadResult{Path: path}, nil
+		}
 	}
 
-	if file.Size != size {
-		return fmt.Errorf("file size incorrect: got %v want %v",
-			size, file.Size)
+	result, err := DownloadRelease(context.Background(), file, opts)
+	if err != nil {
+		return DownloadResult{}, err
 	}
 
-	return nil
+	return result, nil
 }
 
 const (
 	ExitErrReleaseInfo = 1
 	ExitErrMatchFile   = 2
 	ExitErrDownload    = 3
+	ExitErrUsage       = 4
+	ExitErrVerify      = 5
+	ExitErrInstall     = 6
+	ExitErrUnofficial  = 7
+	ExitErrPrerelease  = 8
+	ExitErrHook        = 9
+
+	// ExitUpdateAvailable is returned by -check when a newer version is available.
+	ExitUpdateAvailable = 10
+
+	// ExitOutOfSupport is returned by -supported-window when the running version is not supported.
+	ExitOutOfSupport = 11
+
+	// ExitCurrentEOL is returned by -check when the current version is end-of-life, unless -allow-eol is set.
+	ExitCurrentEOL = 12
 )
 
 func main() {
 	// Define and parse the forceDownload flag.
 	var forceDownload bool
 	flag.BoolVar(&forceDownload, "force", false, "Force download of the latest Go release")
+
+	var skipIfValid bool
+	flag.BoolVar(&skipIfValid, "skip-if-valid", true, "Skip the download if a verified copy already exists (overridden by -force)")
+	flag.BoolVar(&allowInsecure, "allow-insecure", false, "Allow plain http URLs (for testing against local servers)")
+	flag.StringVar(&sizeCheck, "size-check", SizeCheckError, "Size mismatch handling when the checksum matches: error, warn, or off")
+
+	var diff bool
+	flag.BoolVar(&diff, "diff", false, "Compare the files of two releases, e.g. -diff go1.21.0 go1.22.0")
+
+	var compareFeeds string
+	flag.StringVar(&compareFeeds, "compare-feeds", "", "Compare the checksums of the release feed at `url`, such as a mirror, with the official feed")
+
+	var verifyAllDir string
+	var strict bool
+	flag.StringVar(&verifyAllDir, "verify-all", "", "Verify every release archive in `dir` against the feed")
+	flag.BoolVar(&strict, "strict", false, "With -verify-all, treat files not in the feed as failures")
+
+	var verifyOnly, expectedChecksum, expectedSize, hashAlgo string
+	flag.StringVar(&verifyOnly, "verify-only", "", "Verify local `file` against -expected-checksum and -expected-size without network access")
+	flag.StringVar(&expectedChecksum, "expected-checksum", "", "Expected SHA256 for -verify-only (default $"+envExpectedSHA256+")")
+	flag.StringVar(&expectedSize, "expected-size", "", "Expected size in bytes for -verify-only (default $"+envExpectedSize+")")
+	flag.StringVar(&hashAlgo, "hash-algo", "", "Hash algorithm for -verify-only: sha1, sha256, or sha512 (default: inferred from checksum length)")
+
+	var compareFile string
+	flag.StringVar(&compareFile, "compare", "", "Verify that local `file` is the latest release for its platform (inferred from the filename unless -os or -arch is set)")
+
+	var audit bool
+	flag.BoolVar(&audit, "audit-installed", false, "Check that the Go at $GOROOT is an unmodified official release")
+
+	criteria := SelectCriteria{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	var preferInstaller, preferArchive bool
+	flag.StringVar(&criteria.OS, "os", runtime.GOOS, "Select a file for the given operating system")
+	flag.StringVar(&criteria.Arch, "arch", runtime.GOARCH, "Select a file for the given architecture")
+	flag.StringVar(&criteria.Version, "version", "", "Select an exact `version`, e.g. go1.21.5")
+
+	flag.Func("block-version", "Never select `versions`, a comma-separated list such as go1.21.5 (repeatable)", func(s string) error {
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				criteria.BlockedVersions = append(criteria.BlockedVersions, v)
+			}
+		}
+		return nil
+	})
+
+	flag.Func("max-minor", "Select the newest patch of minor `line`, e.g. 1.21, or an older one, but never a newer minor", func(s string) error {
+		line, err := parseMinorLine(s)
+		if err != nil {
+			return err
+		}
+		criteria.MaxMinor = line.MinorLine()
+		return nil
+	})
+
+	var channel string
+	flag.StringVar(&channel, "channel", "", "Select from `channel`: stable (the newest stable release), latest (including betas and rcs), or a minor line such as 1.21")
+
+	var allowPrerelease bool
+	flag.BoolVar(&allowPrerelease, "allow-prerelease", false, "Allow downloading and installing a beta or rc release, even one selected with -unstable or -version")
+
+	var byChecksum string
+	flag.StringVar(&byChecksum, "by-checksum", "", "Select the file with this SHA256 `checksum` from all releases, regardless of version or platform")
+	flag.BoolVar(&criteria.IncludeUnstable, "unstable", false, "Consider unstable (beta and rc) releases")
+	flag.BoolVar(&preferInstaller, "prefer-installer", false, "Prefer an installer over an archive")
+	flag.BoolVar(&preferArchive, "prefer-archive", false, "Prefer an archive over an installer")
+	flag.StringVar(&criteria.Kind, "kind", "", "Select only a file of `kind`: archive, installer, or source (for any -os and -arch), overriding -prefer-installer and -prefer-archive")
+
+	flag.BoolVar(&strictFeed, "strict-feed", false, "Reject a release feed with unknown fields instead of ignoring them")
+
+	flag.Func("fallback-release-url", "Read the release feed from `url` if the official feed fails (repeatable, tried in order)", func(s string) error {
+		fallbackReleaseURLs = append(fallbackReleaseURLs, s)
+		return nil
+	})
+
+	flag.StringVar(&releaseFeedFile, "feed-file", "", "Read the release feed from `file` instead of the network, in every mode but -compare-feeds")
+
+	var dumpFeedDest string
+	var indent bool
+	flag.StringVar(&dumpFeedDest, "dump-feed", "", "Write the release feed to `file`, or - for stdout, for later use with -feed-file")
+	flag.BoolVar(&indent, "indent", false, "With -dump-feed, pretty-print the feed")
+
+	var lockPath, writeLockPath string
+	flag.StringVar(&lockPath, "lock", "", "Use exactly the file recorded in the lockfile at `path`, downloaded from its recorded URL, instead of fetching the release feed and selecting a file")
+	flag.StringVar(&writeLockPath, "write-lock", "", "Record the selected file, its checksum, and its URL in a lockfile at `path` for later use with -lock")
+
+	var allowedKinds string
+	flag.StringVar(&allowedKinds, "allowed-kinds", "", "Comma-separated file kinds that may ever be selected (default: all)")
+
+	var preferExt string
+	flag.StringVar(&preferExt, "prefer-ext", ".tar.xz,.tar.gz", "Comma-separated extensions in order of preference when a release offers several")
+
+	var downloadOpts DownloadOptions
+	flag.StringVar(&downloadOpts.BaseURL, "base-url", downloadPrefixURL, "Download release files relative to `url`")
+	flag.Func("mirror", "Download a file that fails checksum verification again from `url`, like -base-url (repeatable, tried in turn)", func(s string) error {
+		downloadOpts.Mirrors = append(downloadOpts.Mirrors, s)
+		return nil
+	})
+	flag.IntVar(&downloadOpts.ChecksumRetries, "retries-on-checksum", 0, "Download a file that fails checksum verification up to `N` more times, rotating through -base-url and each -mirror")
+	flag.StringVar(&downloadOpts.OutputDir, "output-dir", "", "Save the downloaded file in `dir` (default: $GO_DL_OUTPUT_DIR, else the Downloads directory of the user if it exists, else the current directory)")
+	flag.StringVar(&downloadOpts.Progress, "progress", ProgressTerminal, "Progress display: terminal, jsonl (to stderr), or none")
+	flag.DurationVar(&downloadOpts.ProgressInterval, "progress-interval", 0, "When stdout is not a terminal, log progress once per `interval` (default: every 10%)")
+	var destURL string
+	flag.StringVar(&destURL, "dest", "", "Also save the verified file to `url`, e.g. file:///tmp/go.tar.gz, once it is downloaded to -output-dir and passes all checks")
+	flag.StringVar(&downloadOpts.TempDir, "temp-dir", "", "Download to `dir` before moving the file to -output-dir (default: -output-dir)")
+	flag.BoolVar(&downloadOpts.Resume, "resume", false, "Keep an interrupted download and continue it on the next run")
+	flag.BoolVar(&downloadOpts.NoClobber, "no-clobber", false, "Never replace an existing file in -output-dir or at -dest, even if it is not valid")
+	flag.Int64Var(&downloadOpts.MaxFileSize, "max-file-size", 0, "Refuse to download a file larger than `bytes` (default: no limit)")
+	flag.StringVar(&downloadOpts.CacheDir, "cache-dir", "", "Keep downloads in the content-addressed cache `dir`, as <dir>/<sha256[:2]>/<sha256>, and link to them from -output-dir")
+	flag.BoolVar(&downloadOpts.ChunkVerify, "chunk-verify", false, "Verify each chunk against the mirror's .blockhashes file, if any, and fetch only corrupt chunks again")
+	flag.DurationVar(&downloadOpts.StallTimeout, "stall-timeout", DefaultStallTimeout, "Abort a download that receives no data for `duration` (0 to wait forever)")
+
+	retryPolicy := DefaultRetryPolicy
+	flag.IntVar(&retryPolicy.MaxAttempts, "retry-attempts", retryPolicy.MaxAttempts, "Give up a request after `N` attempts (0 for no limit with -retry-max-elapsed)")
+	flag.DurationVar(&retryPolicy.MaxElapsed, "retry-max-elapsed", 0, "Give up retrying a request after `duration` in total, e.g. 5m (0 for no limit)")
+
+	var timeouts Timeouts
+	flag.DurationVar(&timeouts.Connect, "connect-timeout", DefaultConnectTimeout, "Give up connecting to a server after `duration` (0 for no limit)")
+	flag.DurationVar(&timeouts.Header, "header-timeout", DefaultHeaderTimeout, "Give up waiting for response headers after `duration` (0 for no limit)")
+	flag.Func("file-mode", "Set the permission bits of the downloaded file, e.g. 0644", func(s string) error {
+		mode, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("invalid file mode %q", s)
+		}
+
+		downloadOpts.FileMode = os.FileMode(mode)
+		return nil
+	})
+
+	var manifestOpts ManifestOptions
+	var allowedKeys string
+	flag.StringVar(&manifestOpts.URL, "checksums-url", "", "Verify every file against a signed sha256sum manifest at `url` before downloading it, including with -targets, -all-files, and -watch")
+	flag.StringVar(&manifestOpts.SignatureURL, "checksums-sig-url", "", "Detached signature of the manifest (default: manifest url + .asc)")
+	flag.StringVar(&manifestOpts.KeyringPath, "keyring", "", "OpenPGP public keyring `file` used to verify the manifest signature")
+	flag.StringVar(&allowedKeys, "allowed-keys", "", "Comma-separated fingerprints or key IDs allowed to sign the manifest")
+
+	var watch, watchDownload bool
+	var interval time.Duration
+	flag.BoolVar(&watch, "watch", false, "Check for new releases periodically until interrupted")
+	flag.DurationVar(&interval, "interval", 6*time.Hour, "With -watch, time between checks")
+	flag.BoolVar(&watchDownload, "download", false, "With -watch, download newly detected releases")
+
+	var serveAddr string
+	var serveTTL time.Duration
+	flag.StringVar(&serveAddr, "serve", "", "Serve the check result as JSON at /latest on `addr`, e.g. :8080, until interrupted")
+	flag.DurationVar(&serveTTL, "serve-ttl", DefaultServeTTL, "With -serve, time a check result is reused before the feed is checked again")
+
+	var installDir string
+	var strip int
+	flag.StringVar(&installDir, "install-dir", "", "Extract the downloaded archive into `dir`, replacing the Go install already there")
+	flag.IntVar(&strip, "strip-components", 0, "With -install-dir, drop the first `N` path components, e.g. 1 for the leading go/")
+
+	var forceInstall bool
+	flag.BoolVar(&forceInstall, "force-install", false, "With -install-dir, replace the existing go directory even if it does not look like a Go install (no bin/go and VERSION)")
+
+	var installLayout string
+	flag.StringVar(&installLayout, "install-layout", LayoutGOROOT, "Extract into the directory layout of `manager`: goroot, asdf, or gvm (base is -install-dir or the manager's default)")
+
+	var deepVerify bool
+	flag.BoolVar(&deepVerify, "deep-verify", false, "Decompress a downloaded archive to check its embedded version and report its compression ratio")
+
+	var verifyTransparency bool
+	var transparencyLog string
+	flag.BoolVar(&verifyTransparency, "verify-transparency", false, "Require an entry for the checksum of the downloaded file in the transparency log")
+	flag.StringVar(&transparencyLog, "transparency-log", DefaultTransparencyLogURL, "With -verify-transparency, Rekor compatible transparency log at `url`")
+
+	var verifyCodesign bool
+	flag.BoolVar(&verifyCodesign, "verify-codesign", false, "Verify the code signature of a downloaded .pkg on darwin or .msi on windows")
+
+	var runInstaller, yes bool
+	flag.BoolVar(&runInstaller, "install", false, "On windows, print the command to install the downloaded MSI unattended, or run it with -yes")
+	flag.BoolVar(&yes, "yes", false, "With -install, run the installer")
+
+	var lockTimeout time.Duration
+	flag.DurationVar(&lockTimeout, "lock-timeout", time.Minute, "With -install-dir, time to wait for another install to finish (0 fails immediately)")
+
+	var targets string
+	var parallel int
+	flag.StringVar(&targets, "targets", "", "Download the file for each of these comma-separated GOOS/GOARCH `targets`")
+	flag.IntVar(&parallel, "parallel", 1, "With -targets or -all-files, number of files to download at once")
+
+	var allFiles bool
+	flag.BoolVar(&allFiles, "all-files", false, "Download and verify every file of the release given by -version, e.g. to mirror it")
+
+	var sha256sums string
+	flag.StringVar(&sha256sums, "sha256sums", "", "Write the checksums of the verified files to `path` in sha256sum -c format")
+
+	var sha512sums string
+	flag.StringVar(&sha512sums, "sha512sums", "", "Write the SHA512 digests of the files downloaded to -output-dir to `path` in sha512sum -c format, computed in the same pass as the SHA256")
+
+	var listNewer, stableOnly bool
+	flag.BoolVar(&listNewer, "list-newer", false, "List every release newer than the current version with a file for -os and -arch")
+	flag.BoolVar(&stableOnly, "stable-only", false, "With -list-newer, list only stable releases")
+
+	var selectAllPlatforms bool
+	flag.BoolVar(&selectAllPlatforms, "select-all", false, "Print the file that would be selected for every platform of the latest release")
+
+	var supportedWindow bool
+	flag.BoolVar(&supportedWindow, "supported-window", false, "Report whether the running Go is one of the two newest minor versions")
+
+	var printInstallCommand bool
+	flag.BoolVar(&printInstallCommand, "print-install-command", false, "Print the install command for -version (or the latest) and -os without downloading")
+
+	var postDownloadCmd string
+	flag.StringVar(&postDownloadCmd, "post-download-cmd", "", "Run `command` after each verified download, including every file of -all-files and -targets, with {file} and {version} replaced and GO_DL_FILE, GO_DL_VERSION, and GO_DL_SHA256 set")
+
+	var printCurl bool
+	flag.BoolVar(&printCurl, "print-curl", false, "Print a curl command that downloads and verifies the selected file, without downloading it")
+
+	var installedGo string
+	flag.StringVar(&installedGo, "installed-go", "", "Compare against the Go installed at `path`, a GOROOT or go binary, instead of the running Go")
+
+	var requireOfficial bool
+	flag.BoolVar(&requireOfficial, "require-official", false, "Refuse to run unless the running Go is a stable release, not a devel, beta, or rc build")
+
+	var allowEOL bool
+	flag.BoolVar(&allowEOL, "allow-eol", false, "Do not warn about or fail -check for end-of-life versions")
+
+	var check bool
+	flag.BoolVar(&check, "check", false, "Report whether an update is available without downloading")
+
+	var notifier Notifier
+	var notifyTemplate string
+	flag.StringVar(&notifier.URL, "notify-url", "", "With -check or -watch, POST a notification to `url` when an update is detected")
+	flag.StringVar(&notifyTemplate, "notify-template", "", "Go template for the notification body, e.g. for Slack")
+	flag.IntVar(&notifier.Retries, "notify-retries", 2, "Retries for a failed notification")
+	notifier.RetryDelay = time.Second
+
+	var pins string
+	var allowedHosts string
+	flag.StringVar(&allowedHosts, "allowed-hosts", "", "Comma-separated `hosts` that requests and redirects may go to, besides go.dev, golang.org, dl.google.com, and the -base-url host")
+	flag.StringVar(&pins, "pin-sha256", "", "Comma-separated base64 SHA256 hashes of public keys, one of which the certificate chain of a download host (-base-url and -mirror, or go.dev and dl.google.com) must contain; use host=hash to pin another host")
+
+	var trace bool
+	flag.BoolVar(&trace, "trace", false, "Log HTTP request timings to stderr")
+
+	var proxy, noProxy string
+	var traceProxy bool
+	flag.StringVar(&proxy, "proxy", "", "Send requests through the proxy at `url`, overriding HTTPS_PROXY and HTTP_PROXY")
+	flag.StringVar(&noProxy, "no-proxy", "", "Comma-separated `hosts` to request directly, in the format of NO_PROXY, with or without -proxy")
+	flag.BoolVar(&traceProxy, "trace-proxy", false, "Log the proxy selected for each HTTP request to stderr")
+
+	var noAtomicityCheck bool
+	flag.BoolVar(&noAtomicityCheck, "no-atomicity-check", false, "Do not check that the output directory renames files atomically before downloading")
+
+	var timing bool
+	flag.BoolVar(&timing, "timing", false, "Print the time spent fetching the feed, selecting, downloading, and verifying, and include it in the -json result")
+
+	var jsonOutput bool
+	flag.BoolVar(&jsonOutput, "json", false, "Print the result as JSON to stdout; other output goes to stderr")
+
+	var summaryJSON string
+	flag.StringVar(&summaryJSON, "summary-json", "", "Also write the result as JSON to `file`, keeping the usual output on stdout")
+
+	var metricsFile string
+	flag.StringVar(&metricsFile, "metrics-file", "", "Write Prometheus metrics of the run to `path`, e.g. for the node-exporter textfile collector")
+
+	var githubOutput, githubOutputStdout bool
+	flag.BoolVar(&githubOutput, "github-output", false, "Write GitHub Actions step outputs to $GITHUB_OUTPUT")
+	flag.BoolVar(&githubOutputStdout, "github-output-stdout", false, "With -github-output, write to stdout if $GITHUB_OUTPUT is unset")
 	flag.Parse()
 
-	fmt.Printf("Running %s on %s/%s\n",
-		runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	warnJSON = jsonOutput
+
+	outputDirSet := false
+	flag.Visit(func(f *flag.Flag) {
+		outputDirSet = outputDirSet || f.Name == "output-dir"
+	})
+	if !outputDirSet {
+		downloadOpts.OutputDir = defaultOutputDir(runtime.GOOS, os.Getenv, isDir)
+	}
+
+	// A lockfile stands in for the feed and the selection, and pins where the file comes from.
+	var locked *Lockfile
+	if lockPath != "" {
+		if allFiles || targets != "" {
+			fmt.Println("Use -lock without -all-files and -targets.")
+			os.Exit(ExitErrUsage)
+		}
+
+		lock, err := readLockfile(lockPath)
+		if err != nil {
+			fmt.Printf("Error reading -lock: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+
+		downloadOpts = lock.downloadOptions(downloadOpts)
+		locked = &lock
+	}
+
+	// Only a single file is verified beyond its checksum, saved to -dest, and installed, so
+	// refuse these with several files rather than let them seem to have run.
+	if allFiles || targets != "" {
+		var singleOnly []string
+		for name, set := range map[string]bool{
+			"-deep-verify":         deepVerify,
+			"-verify-transparency": verifyTransparency,
+			"-verify-codesign":     verifyCodesign,
+			"-dest":                destURL != "",
+			"-install-dir":         installDir != "",
+			"-install-layout":      installLayout != LayoutGOROOT,
+			"-install":             runInstaller,
+		} {
+			if set {
+				singleOnly = append(singleOnly, name)
+			}
+		}
+
+		if len(singleOnly) > 0 {
+			slices.Sort(singleOnly)
+			fmt.Printf("Use %s without -all-files and -targets.\n", strings.Join(singleOnly, ", "))
+			os.Exit(ExitErrUsage)
+		}
+	}
+
+	if trace || traceProxy {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	transport := timeoutTransport(http.DefaultTransport.(*http.Transport), timeouts)
+	proxyTrace := logProxy
+	if !traceProxy {
+		proxyTrace = nil
+	}
+	if proxyFor, err := newProxyFunc(proxy, noProxy, proxyTrace); err != nil {
+		fmt.Printf("Invalid -proxy: %v\n", err)
+		os.Exit(ExitErrUsage)
+	} else {
+		transport.Proxy = proxyFor
+	}
+	var base http.RoundTripper = transport
+	if pins != "" {
+		hosts, err := downloadHosts(downloadOpts.sources()...)
+		if err != nil {
+			fmt.Printf("Invalid -base-url or -mirror: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+
+		parsed, err := parsePins(strings.Split(pins, ","), hosts)
+		if err != nil {
+			fmt.Printf("Invalid -pin-sha256: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+		base = pinTransport(transport, parsed)
+	}
+	if path, err := netrcPath(os.Getenv); err == nil {
+		entries, err := readNetrc(path)
+		if err != nil {
+			warn(WarnNetrc, "ignoring %s: %v", path, err)
+		}
+		if len(entries) > 0 {
+			base = &netrcTransport{base: base, entries: entries}
+		}
+	}
+
+	retrying := newRetryTransport(base)
+	retrying.policy = retryPolicy
+	httpClient.Transport = retrying
+
+	// Check hosts outside the retries, as a refused host is not worth retrying.
+	if allowedHosts != "" {
+		allowed, err := newHostAllowlist(strings.Split(allowedHosts, ","), downloadOpts.sources()...)
+		if err != nil {
+			fmt.Printf("Invalid -base-url or -mirror: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+		restrictHosts(httpClient, allowed)
+	}
+
+	if trace {
+		enableTrace()
+	}
+
+	switch {
+	case preferInstaller && preferArchive:
+		fmt.Println("Use only one of -prefer-installer and -prefer-archive.")
+		os.Exit(ExitErrUsage)
+	case preferInstaller:
+		criteria.Kinds = []string{"installer", "archive"}
+	case preferArchive:
+		criteria.Kinds = []string{"archive", "installer"}
+	}
+
+	// -targets applies the archive preference of a cross target to each target on its own.
+	host := Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	targetCriteria := criteria
+	criteria = archiveForCrossTarget(criteria, host)
+
+	switch sizeCheck {
+	case SizeCheckError, SizeCheckWarn, SizeCheckOff:
+	default:
+		fmt.Printf("Invalid -size-check %q.\n", sizeCheck)
+		os.Exit(ExitErrUsage)
+	}
+
+	var hookArgs []string
+	if postDownloadCmd != "" {
+		var err error
+		hookArgs, err = parseHookCommand(postDownloadCmd)
+		if err != nil {
+			fmt.Printf("Invalid -post-download-cmd: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+	}
+
+	switch downloadOpts.Progress {
+	case ProgressTerminal, ProgressJSONL, ProgressNone:
+	default:
+		fmt.Printf("Invalid -progress %q.\n", downloadOpts.Progress)
+		os.Exit(ExitErrUsage)
+	}
+
+	if runInstaller && runtime.GOOS != "windows" {
+		fmt.Printf("-install: %v.\n", ErrInstallerUnsupported)
+		os.Exit(ExitErrUsage)
+	}
+
+	if byChecksum != "" {
+		if _, err := detectHashAlgo(byChecksum, "sha256"); err != nil {
+			fmt.Printf("Invalid -by-checksum: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+	}
+
+	if strip < 0 {
+		fmt.Println("-strip-components must not be negative.")
+		os.Exit(ExitErrUsage)
+	}
+
+	if _, _, err := installTarget(installLayout, "", "go", strip); err != nil {
+		fmt.Printf("Invalid -install-layout: %v\n", err)
+		os.Exit(ExitErrUsage)
+	}
+
+	if sha512sums != "" {
+		downloadOpts.ExtraHashes = []string{"sha512"}
+	}
+
+	if allowedKinds != "" {
+		criteria.AllowedKinds = strings.Split(allowedKinds, ",")
+	}
+
+	if criteria.Kind != "" {
+		if err := checkKind(criteria.Kind); err != nil {
+			fmt.Printf("Invalid -kind: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+	}
+
+	if preferExt != "" {
+		criteria.Extensions = strings.Split(preferExt, ",")
+	}
+
+	if allowedKeys != "" {
+		manifestOpts.AllowedKeys = strings.Split(allowedKeys, ",")
+	}
 
-	releaseInfo, err := getReleaseInfo(releaseURL)
+	if manifestOpts.URL != "" && manifestOpts.KeyringPath == "" {
+		fmt.Println("-checksums-url requires -keyring.")
+		os.Exit(ExitErrUsage)
+	}
+
+	var notify *Notifier
+	if notifier.URL != "" {
+		if notifyTemplate != "" {
+			tmpl, err := template.New("notify").Parse(notifyTemplate)
+			if err != nil {
+				fmt.Printf("Invalid -notify-template: %v\n", err)
+				os.Exit(ExitErrUsage)
+			}
+			notifier.Template = tmpl
+		}
+		notify = &notifier
+	}
+
+	if flag.Arg(0) == "completion" {
+		os.Exit(runCompletion(flag.Args()[1:]))
+	}
+
+	if diff {
+		os.Exit(runDiff(flag.Args()))
+	}
+
+	if audit {
+		os.Exit(runAuditInstalled())
+	}
+
+	if compareFeeds != "" {
+		if releaseFeedFile != "" {
+			fmt.Println("-feed-file cannot be used with -compare-feeds, which compares two network feeds.")
+			os.Exit(ExitErrUsage)
+		}
+
+		os.Exit(runCompareFeeds(compareFeeds))
+	}
+
+	if verifyOnly != "" {
+		os.Exit(runVerifyOnly(verifyOnly, expectedChecksum, expectedSize, hashAlgo))
+	}
+
+	if verifyAllDir != "" {
+		os.Exit(runVerifyAll(verifyAllDir, strict))
+	}
+
+	// The version to update from is normally the Go that built this program.
+	currentVersion := runtime.Version()
+	if installedGo != "" {
+		v, err := installedVersion(installedGo, runCommand)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+		currentVersion = v
+	}
+
+	if requireOfficial {
+		err := checkOfficialVersion(currentVersion)
+		if err != nil {
+			fmt.Printf("Refusing to continue: %v\n", err)
+			os.Exit(ExitErrUnofficial)
+		}
+	}
+
+	if channel != "" {
+		var err error
+		criteria, err = applyChannel(criteria, channel)
+		if err != nil {
+			fmt.Printf("Invalid -channel: %v\n", err)
+			os.Exit(ExitErrUsage)
+		}
+	}
+
+	// The default feed only lists current stable releases. Blocked versions, a minor
+	// cap, and a minor line channel need older releases to fall back to.
+	feedURL := releaseURL
+	if criteria.IncludeUnstable || criteria.Version != "" || byChecksum != "" ||
+		len(criteria.BlockedVersions) > 0 || criteria.MaxMinor != "" || criteria.Line != "" {
+		feedURL = allReleasesURL
+	}
+
+	if dumpFeedDest != "" {
+		os.Exit(runDumpFeed(dumpFeedDest, feedURL, releaseFeedFile, indent))
+	}
+
+	if listNewer {
+		os.Exit(runListNewer(currentVersion, criteria.OS, criteria.Arch, stableOnly))
+	}
+
+	if compareFile != "" {
+		platformSet := false
+		flag.Visit(func(f *flag.Flag) {
+			platformSet = platformSet || f.Name == "os" || f.Name == "arch"
+		})
+
+		os.Exit(runCompare(compareFile, feedURL, criteria, platformSet))
+	}
+
+	if selectAllPlatforms {
+		os.Exit(runSelectAll(feedURL, criteria))
+	}
+
+	if supportedWindow {
+		os.Exit(runSupportedWindow(feedURL, currentVersion))
+	}
+
+	if printInstallCommand {
+		os.Exit(runPrintInstallCommand(criteria, feedURL, downloadOpts.OutputDir))
+	}
+
+	if printCurl {
+		os.Exit(runPrintCurl(criteria, feedURL, downloadOpts.BaseURL, downloadOpts.OutputDir))
+	}
+
+	if serveAddr != "" {
+		os.Exit(runServe(serveAddr, newCheckServer(feedURL, criteria, currentVersion, serveTTL)))
+	}
+
+	if watch {
+		if interval <= 0 {
+			fmt.Println("-interval must be positive.")
+			os.Exit(ExitErrUsage)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		w := &watcher{
+			feed:            &conditionalFeed{url: feedURL, file: releaseFeedFile},
+			criteria:        criteria,
+			current:         currentVersion,
+			download:        watchDownload,
+			allowPrerelease: allowPrerelease,
+			downloadOpts:    downloadOpts,
+			manifest:        manifestOpts,
+			events:          slog.New(slog.NewTextHandler(os.Stdout, nil)),
+			notifier:        notify,
+		}
+		w.run(ctx, interval)
+		return
+	}
+
+	// With -json, stdout is reserved for the result, so send everything else to stderr.
+	var jsonOut io.Writer
+	if jsonOutput {
+		jsonOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	result := newResult()
+	result.CurrentVersion = currentVersion
+	checkTime := time.Now()
+	timer := newPhaseTimer()
+	var download *DownloadResult
+	writeResult := func() {
+		if timing {
+			timer.Stop()
+			timings := timer.Timings()
+			result.Timings = &timings
+			printTimings(os.Stdout, timings)
+		}
+
+		if metricsFile != "" {
+			err := writeMetricsFile(metricsFile, Metrics{
+				Success:         result.Error == "",
+				CheckTime:       checkTime,
+				CurrentVersion:  result.CurrentVersion,
+				LatestVersion:   result.LatestVersion,
+				UpdateAvailable: result.UpdateAvailable,
+				Download:        download,
+			})
+			if err != nil {
+				fmt.Printf("Error writing metrics: %v\n", err)
+			}
+		}
+
+		if summaryJSON != "" {
+			err := writeSummaryFile(summaryJSON, result)
+			if err != nil {
+				fmt.Printf("Error writing -summary-json: %v\n", err)
+			}
+		}
+
+		if jsonOut == nil {
+			return
+		}
+
+		_, err := result.WriteTo(jsonOut)
+		if err != nil {
+			fmt.Printf("Error writing JSON result: %v\n", err)
+		}
+	}
+
+	// fail prints msg and err, records err in the -json result, and exits with code.
+	fail := func(code int, msg string, err error) {
+		fmt.Printf("%s: %v\n", msg, err)
+		result.Error = err.Error()
+		writeResult()
+		os.Exit(code)
+	}
+
+	if installedGo != "" {
+		fmt.Printf("Installed %s at %q\n", currentVersion, installedGo)
+	} else {
+		fmt.Printf("Running %s on %s/%s\n",
+			runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	}
+
+	var releaseInfo ReleaseInfo
+	var err error
+	timer.Start(PhaseFeed)
+	switch {
+	case locked != nil:
+		releaseInfo = locked.releaseInfo()
+	default:
+		releaseInfo, err = loadReleaseInfo(feedURL)
+	}
 	if err != nil {
-		fmt.Printf("Error gettting release info: %v\n", err)
-		os.Exit(ExitErrReleaseInfo)
+		fail(ExitErrReleaseInfo, "Error gettting release info", err)
+	}
+
+	timer.Start(PhaseSelect)
+
+	// Warn up front if a download into the output directory would not be crash-safe, and
+	// fetch and verify the signed manifest that every file is checked against before download.
+	preflight := func() {
+		if !noAtomicityCheck {
+			checkAtomicRename(downloadOpts.OutputDir)
+		}
+
+		downloadOpts, err = manifestOpts.downloadOptions(downloadOpts)
+		if err != nil {
+			fail(ExitErrVerify, "Checksum manifest verification failed", err)
+		}
+	}
+
+	if allFiles {
+		if criteria.Version == "" {
+			fail(ExitErrUsage, "Invalid -all-files", errors.New("-version is required"))
+		}
+
+		_, err = findRelease(releaseInfo, criteria.Version)
+		if err != nil {
+			fail(ExitErrMatchFile, "Error finding release", err)
+		}
+
+		err = checkStableRelease(releaseInfo, criteria.Version, allowPrerelease)
+		if err != nil {
+			fail(ExitErrPrerelease, "Refusing to download", err)
+		}
+
+		preflight()
+		timer.Start(PhaseDownload)
+		results, err := downloadAllFiles(context.Background(), releaseInfo, criteria.Version, parallel, downloadOpts)
+		if err != nil {
+			fail(ExitErrMatchFile, "Error finding release", err)
+		}
+
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Printf("%s\tfailed\t%v\n", r.File.Filename, r.Err)
+				continue
+			}
+			fmt.Printf("%s\t%s\n", r.Path, r.Checksum)
+
+			if hookArgs != nil {
+				err := runPostDownloadHook(hookArgs, r.Path, r.File, os.Stdout, os.Stderr)
+				if err != nil {
+					fail(ExitErrHook, "Post-download command failed", err)
+				}
+			}
+		}
+
+		fmt.Printf("%d verified, %d failed\n", len(results)-results.Failed(), results.Failed())
+
+		if sha256sums != "" {
+			if err := writeSHA256Sums(sha256sums, checksumEntries(results)); err != nil {
+				fail(ExitErrDownload, "Error writing -sha256sums", err)
+			}
+		}
+
+		if sha512sums != "" {
+			entries, err := digestEntries(results, "sha512")
+			if err == nil {
+				err = writeSHA256Sums(sha512sums, entries)
+			}
+			if err != nil {
+				fail(ExitErrDownload, "Error writing -sha512sums", err)
+			}
+		}
+
+		if err := results.Err(); err != nil {
+			fail(ExitErrDownload, "Download failed", err)
+		}
+
+		writeResult()
+		return
 	}
 
-	file, err := findMatchingReleaseFile(releaseInfo)
+	if targets != "" {
+		platforms, err := parseTargets(targets)
+		if err != nil {
+			fail(ExitErrUsage, "Invalid -targets", err)
+		}
+
+		release, err := selectRelease(releaseInfo, targetCriteria)
+		if err == nil {
+			err = checkStableRelease(releaseInfo, release.Version, allowPrerelease)
+			if err != nil {
+				fail(ExitErrPrerelease, "Refusing to download", err)
+			}
+		}
+
+		preflight()
+		timer.Start(PhaseDownload)
+		results := downloadTargets(context.Background(), releaseInfo, targetCriteria, host, platforms, parallel, downloadOpts)
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Printf("%s\tfailed\t%v\n", r.Target, r.Err)
+				continue
+			}
+			fmt.Printf("%s\t%s\t%s\n", r.Target, r.Path, r.Checksum)
+
+			if hookArgs != nil {
+				err := runPostDownloadHook(hookArgs, r.Path, r.File, os.Stdout, os.Stderr)
+				if err != nil {
+					fail(ExitErrHook, "Post-download command failed", err)
+				}
+			}
+		}
+
+		if sha256sums != "" {
+			if err := writeSHA256Sums(sha256sums, checksumEntries(results)); err != nil {
+				fail(ExitErrDownload, "Error writing -sha256sums", err)
+			}
+		}
+
+		if sha512sums != "" {
+			entries, err := digestEntries(results, "sha512")
+			if err == nil {
+				err = writeSHA256Sums(sha512sums, entries)
+			}
+			if err != nil {
+				fail(ExitErrDownload, "Error writing -sha512sums", err)
+			}
+		}
+
+		if err := results.Err(); err != nil {
+			fail(ExitErrDownload, "Download failed", err)
+		}
+
+		writeResult()
+		return
+	}
+
+	var file ReleaseFile
+	switch {
+	case locked != nil:
+		file = locked.ReleaseFile
+	case byChecksum != "":
+		// The download is verified against the same checksum it was selected by.
+		file, err = findFileByChecksum(releaseInfo, byChecksum)
+	default:
+		file, err = SelectFile(releaseInfo, criteria)
+	}
+	if errors.Is(err, ErrNoMatchingFile) && byChecksum == "" && criteria.Version == "" {
+		// Look for an older release with a file for the target, which the default feed omits.
+		all := releaseInfo
+		if releaseFeedFile == "" && feedURL != allReleasesURL {
+			if more, moreErr := getReleaseInfo(allReleasesURL); moreErr == nil {
+				all = more
+			}
+		}
+
+		if release, findErr := newestReleaseWith(all, criteria); findErr == nil {
+			fmt.Printf("%s is the newest release with a file for %s/%s. Use -version %s to pin it.\n",
+				release.Version, criteria.OS, criteria.Arch, release.Version)
+		}
+	}
 	if err != nil {
-		fmt.Printf("Error finding matching release file: %v\n", err)
-		os.Exit(ExitErrMatchFile)
+		fail(ExitErrMatchFile, "Error finding matching release file", err)
 	}
 
-	fmt.Printf("Latest  %s on %s/%s\n",
+	label := "Latest"
+	if locked != nil {
+		label = "Locked"
+	}
+	fmt.Printf("%s  %s on %s/%s\n", label,
 		file.Version, file.OS, file.Arch)
 
-	// Check if the current version running and if forceDownload is not set.
-	if file.Version == runtime.Version() && !forceDownload {
+	if !allowEOL && isEOL(releaseInfo, file.Version) {
+		warn(WarnEOL, "%s is end-of-life and no longer receives security fixes.", file.Version)
+	}
+
+	if writeLockPath != "" {
+		lock, err := newLockfile(file, downloadOpts.BaseURL)
+		if err == nil {
+			err = writeLockfile(writeLockPath, lock)
+		}
+		if err != nil {
+			fail(ExitErrUsage, "Error writing -write-lock", err)
+		}
+		fmt.Printf("Locked %s in %s\n", file.Filename, writeLockPath)
+	}
+
+	outputs := GitHubOutputs{
+		LatestVersion:   file.Version,
+		CurrentVersion:  currentVersion,
+		UpdateAvailable: file.Version != currentVersion,
+	}
+	result.LatestVersion = file.Version
+	result.UpdateAvailable = outputs.UpdateAvailable
+	result.File = &file
+	emitOutputs := func() {
+		if !githubOutput {
+			return
+		}
+
+		err := emitGitHubOutputs(outputs, githubOutputStdout)
+		if err != nil {
+			fmt.Printf("Error writing GitHub outputs: %v\n", err)
+		}
+	}
+
+	// A running version of a minor line newer than -max-minor is not an update to downgrade from.
+	if criteria.MaxMinor != "" && criteria.Version == "" {
+		line, _ := parseMinorLine(criteria.MaxMinor)
+		if exceedsMinorLine(currentVersion, line) {
+			fmt.Printf("Running %s, which is newer than the -max-minor %s line. Not downgrading.\n",
+				currentVersion, criteria.MaxMinor)
+			outputs.UpdateAvailable = false
+			result.UpdateAvailable = false
+			emitOutputs()
+			writeResult()
+			return
+		}
+	}
+
+	if check {
+		if !outputs.UpdateAvailable {
+			fmt.Println("Running current version.")
+			emitOutputs()
+			writeResult()
+			return
+		}
+
+		note := newUpdateNotification(currentVersion, file.Version)
+		if note.Summary != "" {
+			fmt.Println(note.Summary)
+		} else {
+			fmt.Printf("Update available: %s -> %s\n", currentVersion, file.Version)
+		}
+
+		if notify != nil {
+			err = notify.Notify(context.Background(), note)
+			if err != nil {
+				fmt.Printf("Notification failed: %v\n", err)
+			}
+		}
+
+		emitOutputs()
+		writeResult()
+
+		if !allowEOL && isEOL(releaseInfo, currentVersion) {
+			fmt.Printf("%s is end-of-life.\n", currentVersion)
+			os.Exit(ExitCurrentEOL)
+		}
+
+		os.Exit(ExitUpdateAvailable)
+	}
+
+	// Check if the current version running and if forceDownload is not set. A file for another
+	// platform is not the Go that is running, so it is downloaded whatever its version.
+	crossTarget := isCrossTarget(Platform{OS: file.OS, Arch: file.Arch}, host)
+	if file.Version == currentVersion && !forceDownload && !crossTarget {
 		fmt.Println("Running current version. Use -force to override.")
+		emitOutputs()
+		writeResult()
 		return
 	}
 
-	err = downloadAndVerifyFile(file)
+	err = checkStableRelease(releaseInfo, file.Version, allowPrerelease)
 	if err != nil {
-		fmt.Printf("Download failed: %v\n", err)
-		os.Exit(ExitErrDownload)
+		fail(ExitErrPrerelease, "Refusing to download", err)
 	}
 
-	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
-		fmt.Println("Run the following command to install:")
-		fmt.Printf("sudo -- sh -c \"rm -rf /usr/local/go && tar -C /usr/local -xzf %s\"\n", file.Filename)
+	// Trust the feed's checksum only if a signed manifest agrees with it.
+	if manifestOpts.URL != "" {
+		timer.Start(PhaseVerify)
+	}
+
+	preflight()
+
+	err = downloadOpts.checkManifest(file)
+	if err != nil {
+		fail(ExitErrVerify, "Checksum manifest verification failed", err)
 	}
+
+	timer.Start(PhaseDownload)
+
+	r, err := downloadAndVerifyFile(file, skipIfValid && !forceDownload, downloadOpts)
+	if err != nil {
+		fail(ExitErrDownload, "Download failed", err)
+	}
+	path := r.Path
+	if r.Duration > 0 {
+		// Only record an actual download, not a verified file that was kept.
+		download = &r
+	}
+
+	timer.Start(PhaseVerify)
+
+	if deepVerify {
+		if !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tar.xz") {
+			fmt.Printf("Not deep verifying %s, it is not a tar archive.\n", path)
+		} else {
+			inspection, err := verifyArchive(path, file)
+			if err != nil {
+				fail(ExitErrVerify, "Deep verification failed", err)
+			}
+			fmt.Printf("%s verified: %s\n", path, inspection.CompressionSummary())
+		}
+	}
+
+	if verifyTransparency {
+		uuids, err := findTransparencyEntries(context.Background(), httpClient, transparencyLog, file.SHA256)
+		if err != nil {
+			fail(ExitErrVerify, "Transparency log verification failed", err)
+		}
+		fmt.Printf("%s is recorded in the transparency log, entry %s.\n", path, uuids[0])
+	}
+
+	if verifyCodesign {
+		err = verifyCodeSignature(runtime.GOOS, path, runCommand)
+		switch {
+		case errors.Is(err, ErrCodesignUnsupported):
+			warn(WarnCodesign, "not verifying the code signature: %v", err)
+		case err != nil:
+			fail(ExitErrVerify, "Code signature verification failed", err)
+		default:
+			fmt.Printf("Code signature of %s verified.\n", path)
+		}
+	}
+
+	// Only a verified file is saved to the destination.
+	downloadedFile := path
+	if destURL != "" {
+		timer.Start(PhaseDownload)
+		dst, err := OpenDestination(destURL, downloadOpts)
+		if err == nil {
+			err = saveToDestination(path, dst)
+		}
+		if err != nil {
+			fail(ExitErrDownload, "Error saving to -dest", err)
+		}
+		fmt.Printf("Saved %s to %s\n", path, destURL)
+		downloadedFile = destURL
+	}
+
+	timer.Stop()
+
+	if hookArgs != nil {
+		err = runPostDownloadHook(hookArgs, path, file, os.Stdout, os.Stderr)
+		if err != nil {
+			fail(ExitErrHook, "Post-download command failed", err)
+		}
+	}
+
+	if sha256sums != "" {
+		err = writeSHA256Sums(sha256sums, []checksumEntry{{Checksum: file.SHA256, Filename: file.Filename}})
+		if err != nil {
+			fail(ExitErrDownload, "Error writing -sha256sums", err)
+		}
+	}
+
+	if sha512sums != "" {
+		digest, err := resultDigest(r, "sha512")
+		if err == nil {
+			err = writeSHA256Sums(sha512sums, []checksumEntry{{Checksum: digest, Filename: file.Filename}})
+		}
+		if err != nil {
+			fail(ExitErrDownload, "Error writing -sha512sums", err)
+		}
+	}
+
+	outputs.DownloadedFile = downloadedFile
+	result.DownloadedFile = downloadedFile
+	emitOutputs()
+
+	if runInstaller {
+		if !yes {
+			fmt.Println("Run the following command to install, or use -yes:")
+			fmt.Println("msiexec " + strings.Join(msiexecArgs(path), " "))
+			writeResult()
+			return
+		}
+
+		code, err := installMSI(runtime.GOOS, path, runExitCode)
+		if err != nil {
+			fail(ExitErrInstall, "Install failed", err)
+		}
+
+		fmt.Printf("Installed %s: %s\n", file.Version, msiExitMessage(code))
+		writeResult()
+		return
+	}
+
+	if installDir != "" || installLayout != LayoutGOROOT {
+		base := installDir
+		if base == "" {
+			base, err = layoutBase(installLayout, os.Getenv)
+			if err != nil {
+				fail(ExitErrInstall, "Install failed", err)
+			}
+		}
+
+		dir, n, err := installTarget(installLayout, base, file.Version, strip)
+		if err == nil && installLayout != LayoutGOROOT {
+			err = prepareInstallTarget(base, dir)
+		}
+		if err != nil {
+			fail(ExitErrInstall, "Install failed", err)
+		}
+
+		// Only one install into dir may run at a time.
+		release, err := acquireInstallLock(dir, lockTimeout)
+		if err != nil {
+			fail(ExitErrInstall, "Install failed", err)
+		}
+
+		if root := goRootIn(dir, n); root != "" {
+			err = removeGoInstall(root, forceInstall)
+		}
+		if err == nil {
+			err = extractArchive(path, dir, n)
+		}
+		release()
+		if err != nil {
+			fail(ExitErrInstall, "Install failed", err)
+		}
+
+		fmt.Printf("Installed %s to %q\n", file.Version, dir)
+		writeResult()
+		return
+	}
+
+	printInstallInstructions(os.Stdout, file, path, host)
+	writeResult()
 }
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetReleaseInfoEmptyFeed(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "[]")
+	}))
+	defer server.Close()
+
+	_, err := getReleaseInfo(server.URL)
+	if !errors.Is(err, ErrNoReleases) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrNoReleases)
+	}
+}
+
+func TestGetReleaseInfoFallback(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	rt := newRetryTransport(http.DefaultTransport)
+	rt.policy = RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	prevTransport, prevFallbacks := httpClient.Transport, fallbackReleaseURLs
+	httpClient.Transport = rt
+	t.Cleanup(func() { httpClient.Transport, fallbackReleaseURLs = prevTransport, prevFallbacks })
+
+	var primaryRequests atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		http.Error(w, "outage", http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer down.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testFeedJSON)
+	}))
+	defer mirror.Close()
+
+	fallbackReleaseURLs = []string{down.URL, mirror.URL}
+
+	info, err := getReleaseInfo(primary.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(info) != 1 || info[0].Version != "go1.21.5" {
+		t.Errorf("Unexpected release info.\n Got: %+v\nWant: go1.21.5 from the mirror", info)
+	}
+
+	if got := primaryRequests.Load(); got != 2 {
+		t.Errorf("Unexpected requests to the primary feed.\n Got: %d\nWant: 2", got)
+	}
+
+	// If all fail, the error describes each of them.
+	fallbackReleaseURLs = []string{down.URL}
+
+	_, err = getReleaseInfo(primary.URL)
+	if err == nil || !strings.Contains(err.Error(), "Internal Server Error") || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: errors of both feeds", err)
+	}
+}
+
+func TestDecodeReleases(t *testing.T) {
+	testCases := []struct {
+		name          string
+		body          string
+		expectedError error
+		expectedText  string
+	}{
+		{name: "Valid feed", body: testFeedJSON},
+		{name: "Empty feed", body: "[]", expectedError: ErrNoReleases},
+		{name: "Empty body", body: "", expectedError: ErrEmptyFeed},
+		{name: "Whitespace body", body: " \n", expectedError: ErrEmptyFeed},
+		{name: "Truncated feed", body: `[{"version": "go1.21.5"`, expectedError: io.ErrUnexpectedEOF},
+		{name: "Truncated feed is malformed", body: `[{"version": "go1.21.5"`, expectedError: ErrMalformedFeed},
+		{name: "Truncated feed size", body: `[{"version": "go1.21.5"`, expectedText: "23 bytes received"},
+		{name: "Empty body size", body: "", expectedText: "empty response, 0 bytes received"},
+		{name: "Syntax error is malformed", body: `[{"version": go1.21.5}]`, expectedError: ErrMalformedFeed},
+		{name: "Syntax error", body: `[{"version": go1.21.5}]`, expectedText: "at offset 14"},
+		{name: "Type error", body: `[{"version": 1}]`, expectedText: "at offset 14"},
+		{name: "Trailing data", body: `[{"version": "go1.21.5"}] []`, expectedText: "unexpected data after feed"},
+		{name: "Not an array", body: `{"version": "go1.21.5"}`, expectedText: "at offset"},
+		{name: "Release without files", body: `[{"version": "go1.21.5", "stable": true}]`, expectedError: ErrInvalidRelease},
+		{name: "Release without version", body: `[{"files": [{"filename": "go.tar.gz"}]}]`, expectedError: ErrInvalidRelease},
+		{
+			name: "Duplicate release with conflicting checksum",
+			body: `[{"version": "go1.21.5", "files": [{"filename": "go.tar.gz", "sha256": "aaaa"}]},
+				{"version": "go1.21.5", "files": [{"filename": "go.tar.gz", "sha256": "bbbb"}]}]`,
+			expectedError: ErrConflictingFile,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeReleases(strings.NewReader(tc.body))
+
+			if tc.expectedText != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedText) {
+					t.Errorf("Unexpected error.\n Got: %v\nWant: containing %q", err, tc.expectedText)
+				}
+				return
+			}
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestDecodeReleasesDuplicateVersion(t *testing.T) {
+	body := `[
+		{"version": "go1.22.0", "stable": true, "files": [{"filename": "go1.22.0.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": "cccc", "kind": "archive"}]},
+		{"version": "go1.21.5", "stable": true, "files": [{"filename": "go1.21.5.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": "aaaa", "kind": "archive"}]},
+		{"version": "go1.22.0", "stable": false, "files": [
+			{"filename": "go1.22.0.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": "cccc", "kind": "archive"},
+			{"filename": "go1.22.0.linux-arm64.tar.gz", "os": "linux", "arch": "arm64", "sha256": "dddd", "kind": "archive"}
+		]}
+	]`
+
+	info, err := DecodeReleases(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var versions []string
+	for _, release := range info {
+		versions = append(versions, release.Version)
+	}
+	if strings.Join(versions, " ") != "go1.22.0 go1.21.5" {
+		t.Errorf("Unexpected versions.\n Got: %v\nWant: [go1.22.0 go1.21.5]", versions)
+	}
+
+	if !info[0].Stable || len(info[0].Files) != 2 {
+		t.Errorf("Unexpected merged release.\n Got: %+v\nWant: stable with 2 files", info[0])
+	}
+
+	file, err := SelectFile(info, SelectCriteria{OS: "linux", Arch: "arm64"})
+	if err != nil || file.SHA256 != "dddd" {
+		t.Errorf("Unexpected selection.\n Got: %+v, %v\nWant: go1.22.0.linux-arm64.tar.gz", file, err)
+	}
+}
+
+// setStrictFeed sets strictFeed for the duration of the test.
+func setStrictFeed(t *testing.T, strict bool) {
+	t.Helper()
+
+	prev := strictFeed
+	strictFeed = strict
+	t.Cleanup(func() { strictFeed = prev })
+}
+
+func TestDecodeReleasesUnknownField(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		strict   bool
+		expected string
+	}{
+		{
+			name: "Lenient release field",
+			body: `[{"version": "go1.21.5", "eol": true, "files": [{"filename": "go.tar.gz"}]}]`,
+		},
+		{
+			name: "Lenient file field",
+			body: `[{"version": "go1.21.5", "files": [{"filename": "go.tar.gz", "sha512": "ab"}]}]`,
+		},
+		{
+			name:     "Strict release field",
+			body:     `[{"version": "go1.21.5", "eol": true, "files": [{"filename": "go.tar.gz"}]}]`,
+			strict:   true,
+			expected: `unknown field "eol"`,
+		},
+		{
+			name:     "Strict file field",
+			body:     `[{"version": "go1.21.5", "files": [{"filename": "go.tar.gz", "sha512": "ab"}]}]`,
+			strict:   true,
+			expected: `unknown field "sha512"`,
+		},
+		{
+			name:   "Strict known fields",
+			body:   testFeedJSON,
+			strict: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setStrictFeed(t, tc.strict)
+
+			_, err := DecodeReleases(strings.NewReader(tc.body))
+
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: containing %q", err, tc.expected)
+			}
+		})
+	}
+}
+
+// benchmarkFeed returns a feed shaped like the full go.dev feed, with many releases of many files.
+func benchmarkFeed(b *testing.B) []byte {
+	b.Helper()
+
+	platforms := []string{"linux-amd64", "linux-arm64", "linux-386", "darwin-amd64", "darwin-arm64", "windows-amd64", "windows-386", "freebsd-amd64"}
+
+	var info ReleaseInfo
+	for minor := 22; minor > 0; minor-- {
+		for patch := 12; patch >= 0; patch-- {
+			version := fmt.Sprintf("go1.%d.%d", minor, patch)
+			release := Release{Version: version, Stable: true}
+
+			for _, platform := range platforms {
+				goos, goarch, _ := strings.Cut(platform, "-")
+				release.Files = append(release.Files, ReleaseFile{
+					Filename: version + "." + platform + ".tar.gz",
+					OS:       goos,
+					Arch:     goarch,
+					Version:  version,
+					SHA256:   strings.Repeat("a", 64),
+					Size:     67108864,
+					Kind:     "archive",
+				})
+			}
+
+			info = append(info, release)
+		}
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return data
+}
+
+func BenchmarkParseFeed(b *testing.B) {
+	feed := benchmarkFeed(b)
+
+	b.Run("Decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(feed)))
+
+		for i := 0; i < b.N; i++ {
+			_, err := DecodeReleases(bytes.NewReader(feed))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// ReadAllUnmarshal is the previous approach, for comparison.
+	b.Run("ReadAllUnmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(feed)))
+
+		for i := 0; i < b.N; i++ {
+			body, err := io.ReadAll(bytes.NewReader(feed))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var info ReleaseInfo
+			err = json.Unmarshal(body, &info)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestReadReleaseInfoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.json")
+	if err := os.WriteFile(path, []byte(testFeedJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := readReleaseInfoFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(info) != 1 || info[0].Version != "go1.21.5" {
+		t.Errorf("Unexpected release info: %+v", info)
+	}
+
+	_, err = readReleaseInfoFile(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, os.ErrNotExist)
+	}
+}
+
+func TestLoadReleaseInfoFeedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.json")
+	if err := os.WriteFile(path, []byte(testFeedJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := releaseFeedFile
+	releaseFeedFile = path
+	t.Cleanup(func() { releaseFeedFile = prev })
+
+	// The feed URL is never requested while a feed file is set.
+	info, err := loadReleaseInfo("https://invalid.example/dl/?mode=json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(info) != 1 || info[0].Version != "go1.21.5" {
+		t.Errorf("Unexpected release info: %+v", info)
+	}
+}
+
+func TestFindFileByChecksum(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	const sum1B = "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"
+	const sum1MB = "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e"
+
+	info := ReleaseInfo{
+		{
+			Version: "go1.21.5",
+			Stable:  true,
+			Files:   []ReleaseFile{{Filename: "testfile_1MB", OS: "linux", Arch: "amd64", Version: "go1.21.5", SHA256: sum1MB, Size: 1 << 20}},
+		},
+		{
+			Version: "go1.20.12",
+			Stable:  true,
+			Files:   []ReleaseFile{{Filename: "testfile_1B", OS: "darwin", Arch: "arm64", Version: "go1.20.12", SHA256: sum1B, Size: 1}},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		checksum         string
+		expectedFilename string
+		expectedError    error
+	}{
+		{name: "Older release", checksum: sum1B, expectedFilename: "testfile_1B"},
+		{name: "Upper case", checksum: strings.ToUpper(sum1MB), expectedFilename: "testfile_1MB"},
+		{name: "Unknown", checksum: strings.Repeat("0", 64), expectedError: ErrUnknownChecksum},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := findFileByChecksum(info, tc.checksum)
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Filename != tc.expectedFilename {
+				t.Errorf("Unexpected file.\n Got: %q\nWant: %q", file.Filename, tc.expectedFilename)
+			}
+
+			if err != nil {
+				return
+			}
+
+			opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone}
+			result, err := DownloadRelease(context.Background(), file, opts)
+			if err != nil {
+				t.Fatalf("Unexpected download error: %v", err)
+			}
+
+			if !strings.EqualFold(result.Checksum, tc.checksum) {
+				t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", result.Checksum, tc.checksum)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Metrics are the values of a run written by -metrics-file in the Prometheus text exposition
+// format, for the node-exporter textfile collector.
+//
+// The metric names are stable and are:
+//
+//	go_latest_check_success                1 if the run succeeded, otherwise 0
+//	go_latest_check_timestamp_seconds      Unix time of the check
+//	go_latest_version_update_available     1 if the latest version differs from the current one
+//	go_latest_current_version_info         always 1, with the current version as the version label
+//	go_latest_latest_version_info          always 1, with the latest version as the version label,
+//	                                       omitted if the feed could not be read
+//	go_latest_download_bytes               bytes downloaded, only after a download
+//	go_latest_download_duration_seconds    time taken by the download, only after a download
+type Metrics struct {
+	Success         bool
+	CheckTime       time.Time
+	CurrentVersion  string
+	LatestVersion   string
+	UpdateAvailable bool
+	Download        *DownloadResult // Nil if nothing was downloaded.
+}
+
+// labelEscaper escapes Prometheus label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteTo writes m to w in the Prometheus text format.
+func (m Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	gauge := func(name, help, labels string, value float64) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", name, help, name, name, labels, value)
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	versionLabel := func(version string) string {
+		return `{version="` + labelEscaper.Replace(version) + `"}`
+	}
+
+	gauge("go_latest_check_success", "Whether the last check succeeded.", "", boolValue(m.Success))
+	gauge("go_latest_check_timestamp_seconds", "Unix time of the last check.", "", float64(m.CheckTime.Unix()))
+	gauge("go_latest_version_update_available", "Whether a newer Go version is available.", "", boolValue(m.UpdateAvailable))
+	gauge("go_latest_current_version_info", "Current Go version.", versionLabel(m.CurrentVersion), 1)
+
+	if m.LatestVersion != "" {
+		gauge("go_latest_latest_version_info", "Latest Go version.", versionLabel(m.LatestVersion), 1)
+	}
+
+	if m.Download != nil {
+		gauge("go_latest_download_bytes", "Bytes downloaded by the last run.", "", float64(m.Download.Size))
+		gauge("go_latest_download_duration_seconds", "Duration of the last download.", "", m.Download.Duration.Seconds())
+	}
+
+	return buf.WriteTo(w)
+}
+
+// writeMetricsFile writes m atomically to path, so the textfile collector never reads a partial file.
+func writeMetricsFile(path string, m Metrics) error {
+	var buf bytes.Buffer
+
+	_, err := m.WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf.Bytes())
+}
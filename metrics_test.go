@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleLine matches a sample of the Prometheus text format, capturing the name and value.
+var sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\.)*"(?:,[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\.)*")*\})? (\S+)$`)
+
+// parseExposition checks that text is valid Prometheus text format, with a TYPE line before
+// the samples of each metric, and returns the sample values by name and labels.
+func parseExposition(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+
+	samples := make(map[string]float64)
+	typed := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "#" {
+			switch fields[1] {
+			case "TYPE":
+				if len(fields) != 4 || fields[3] != "gauge" {
+					t.Errorf("Invalid TYPE line: %q", line)
+				}
+				typed[fields[2]] = true
+			case "HELP":
+			default:
+				t.Errorf("Invalid comment line: %q", line)
+			}
+			continue
+		}
+
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("Invalid sample line: %q", line)
+			continue
+		}
+
+		if !typed[m[1]] {
+			t.Errorf("Sample before TYPE: %q", line)
+		}
+
+		value, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Errorf("Invalid value in %q: %v", line, err)
+		}
+
+		samples[m[1]+m[2]] = value
+	}
+
+	return samples
+}
+
+func TestMetricsWriteTo(t *testing.T) {
+	checkTime := time.Unix(1700000000, 0)
+
+	testCases := []struct {
+		name     string
+		metrics  Metrics
+		expected map[string]float64
+	}{
+		{
+			name: "Update available",
+			metrics: Metrics{
+				Success: true, CheckTime: checkTime, CurrentVersion: "go1.21.4", LatestVersion: "go1.21.5", UpdateAvailable: true,
+			},
+			expected: map[string]float64{
+				"go_latest_check_success":                            1,
+				"go_latest_check_timestamp_seconds":                  1700000000,
+				"go_latest_version_update_available":                 1,
+				`go_latest_current_version_info{version="go1.21.4"}`: 1,
+				`go_latest_latest_version_info{version="go1.21.5"}`:  1,
+			},
+		},
+		{
+			name: "Download",
+			metrics: Metrics{
+				Success: true, CheckTime: checkTime, CurrentVersion: "go1.21.4", LatestVersion: "go1.21.5", UpdateAvailable: true,
+				Download: &DownloadResult{Size: 66618285, Duration: 1500 * time.Millisecond},
+			},
+			expected: map[string]float64{
+				"go_latest_check_success":                            1,
+				"go_latest_check_timestamp_seconds":                  1700000000,
+				"go_latest_version_update_available":                 1,
+				`go_latest_current_version_info{version="go1.21.4"}`: 1,
+				`go_latest_latest_version_info{version="go1.21.5"}`:  1,
+				"go_latest_download_bytes":                           66618285,
+				"go_latest_download_duration_seconds":                1.5,
+			},
+		},
+		{
+			name:    "Failed with escaped version",
+			metrics: Metrics{CheckTime: checkTime, CurrentVersion: `devel "x"`},
+			expected: map[string]float64{
+				"go_latest_check_success":                               0,
+				"go_latest_check_timestamp_seconds":                     1700000000,
+				"go_latest_version_update_available":                    0,
+				`go_latest_current_version_info{version="devel \"x\""}`: 1,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			if _, err := tc.metrics.WriteTo(&sb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			got := parseExposition(t, sb.String())
+
+			if len(got) != len(tc.expected) {
+				t.Errorf("Unexpected samples.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+
+			for name, want := range tc.expected {
+				if value, ok := got[name]; !ok || value != want {
+					t.Errorf("Unexpected %s.\n Got: %v (present %v)\nWant: %v", name, value, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMetricsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "go_latest.prom")
+
+	err := writeMetricsFile(path, Metrics{Success: true, CheckTime: time.Now(), CurrentVersion: "go1.21.5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseExposition(t, string(data))
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Unexpected files left behind: %d entries", len(entries))
+	}
+}
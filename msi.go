@@ -0,0 +1,90 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrInstallerUnsupported = errors.New("unattended install is only supported for MSI installers on windows")
+	ErrInstallerFailed      = errors.New("installer failed")
+)
+
+// Exit codes of msiexec that mean the install succeeded.
+const (
+	msiSuccess               = 0
+	msiSuccessRebootRequired = 3010
+	msiSuccessRebootStarted  = 1641
+)
+
+// msiExitMessages describes common msiexec exit codes.
+var msiExitMessages = map[int]string{
+	msiSuccess:               "success",
+	msiSuccessRebootRequired: "success, a reboot is required to complete the install",
+	msiSuccessRebootStarted:  "success, a reboot was started to complete the install",
+	1602:                     "cancelled by the user",
+	1603:                     "fatal error during installation",
+	1618:                     "another installation is already in progress",
+	1619:                     "the installation package could not be opened",
+	1625:                     "the installation is forbidden by system policy",
+	1638:                     "another version of this product is already installed",
+}
+
+// msiExitMessage describes the msiexec exit code.
+func msiExitMessage(code int) string {
+	if msg, ok := msiExitMessages[code]; ok {
+		return msg
+	}
+
+	return "unknown error"
+}
+
+// msiexecArgs returns the msiexec arguments for a quiet install of the MSI at path
+// that does not restart the system.
+func msiexecArgs(path string) []string {
+	return []string{"/i", path, "/qn", "/norestart"}
+}
+
+// exitCodeRunner runs a command and returns its exit code. Replaced in tests.
+type exitCodeRunner func(name string, args ...string) (int, error)
+
+// runExitCode is the default exitCodeRunner.
+func runExitCode(name string, args ...string) (int, error) {
+	err := exec.Command(name, args...).Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
+
+// installMSI runs msiexec to install the MSI at path without user interaction on goos,
+// which must be windows. It returns the msiexec exit code, and ErrInstallerFailed unless
+// the code means success.
+func installMSI(goos, path string, run exitCodeRunner) (int, error) {
+	if goos != "windows" || !strings.HasSuffix(strings.ToLower(path), ".msi") {
+		return -1, fmt.Errorf("%w: %s on %s", ErrInstallerUnsupported, path, goos)
+	}
+
+	code, err := run("msiexec", msiexecArgs(path)...)
+	if err != nil {
+		return code, fmt.Errorf("%w: %w", ErrInstallerFailed, err)
+	}
+
+	switch code {
+	case msiSuccess, msiSuccessRebootRequired, msiSuccessRebootStarted:
+		return code, nil
+	default:
+		return code, fmt.Errorf("%w: msiexec exit code %d: %s", ErrInstallerFailed, code, msiExitMessage(code))
+	}
+}
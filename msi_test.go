@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInstallMSI(t *testing.T) {
+	testCases := []struct {
+		name          string
+		goos          string
+		path          string
+		exitCode      int
+		expectedError error
+		expectRun     bool
+	}{
+		{name: "Success", goos: "windows", path: `C:\dl\go1.21.5.windows-amd64.msi`, exitCode: 0, expectRun: true},
+		{name: "Reboot required", goos: "windows", path: `C:\dl\go1.21.5.windows-amd64.msi`, exitCode: 3010, expectRun: true},
+		{name: "Fatal error", goos: "windows", path: `C:\dl\go1.21.5.windows-amd64.msi`, exitCode: 1603, expectedError: ErrInstallerFailed, expectRun: true},
+		{name: "Not windows", goos: "linux", path: "go1.21.5.windows-amd64.msi", expectedError: ErrInstallerUnsupported},
+		{name: "Not an MSI", goos: "windows", path: `C:\dl\go1.21.5.windows-amd64.zip`, expectedError: ErrInstallerUnsupported},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ran bool
+
+			run := func(name string, args ...string) (int, error) {
+				ran = true
+
+				wantArgs := []string{"/i", tc.path, "/qn", "/norestart"}
+				if name != "msiexec" || !reflect.DeepEqual(args, wantArgs) {
+					t.Errorf("Unexpected command.\n Got: %s %q\nWant: msiexec %q", name, args, wantArgs)
+				}
+
+				return tc.exitCode, nil
+			}
+
+			code, err := installMSI(tc.goos, tc.path, run)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if ran != tc.expectRun {
+				t.Errorf("Unexpected run.\n Got: %v\nWant: %v", ran, tc.expectRun)
+			}
+
+			if tc.expectRun && code != tc.exitCode {
+				t.Errorf("Unexpected exit code.\n Got: %d\nWant: %d", code, tc.exitCode)
+			}
+		})
+	}
+}
+
+func TestMSIExitMessage(t *testing.T) {
+	if got := msiExitMessage(3010); got != "success, a reboot is required to complete the install" {
+		t.Errorf("Unexpected message for 3010: %q", got)
+	}
+
+	if got := msiExitMessage(42); got != "unknown error" {
+		t.Errorf("Unexpected message for 42: %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidTarget = errors.New("invalid target")
+
+// parseTargets parses a comma-separated list of GOOS/GOARCH targets, e.g. "linux/amd64,darwin/arm64".
+func parseTargets(s string) ([]Platform, error) {
+	var targets []Platform
+
+	for _, t := range strings.Split(s, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(t), "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("%w: %q is not GOOS/GOARCH", ErrInvalidTarget, t)
+		}
+
+		targets = append(targets, Platform{OS: goos, Arch: goarch})
+	}
+
+	return targets, nil
+}
+
+// TargetResult is the outcome of selecting and downloading the file for one target.
+// In -all-files mode, Target is not set and the result is that of File alone.
+type TargetResult struct {
+	Target Platform
+	File   ReleaseFile
+	DownloadResult
+	Err error
+}
+
+// Results are the outcomes of a multi-target download, in the order the targets were given.
+type Results []TargetResult
+
+// Err returns the errors of the failed targets joined together, or nil if all succeeded.
+func (r Results) Err() error {
+	var errs []error
+
+	for _, result := range r {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.label(), result.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Failed returns the number of results with an error.
+func (r Results) Failed() int {
+	var n int
+
+	for _, result := range r {
+		if result.Err != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// label identifies the result by its target, or by its filename if it has no target.
+func (r TargetResult) label() string {
+	if r.Target == (Platform{}) {
+		return r.File.Filename
+	}
+
+	return r.Target.String()
+}
+
+// downloadTargets selects the file for each target with criteria and downloads and verifies
+// the files, at most parallel at a time. As for a single file, only an archive is selected for
+// a cross target of host, see archiveForCrossTarget. Each download hashes its own bytes, so the
+// hashing is spread over the workers too. A target whose file cannot be selected is not downloaded.
+func downloadTargets(ctx context.Context, info ReleaseInfo, criteria SelectCriteria, host Platform,
+	targets []Platform, parallel int, opts DownloadOptions,
+) Results {
+	results := make(Results, len(targets))
+
+	for i, target := range targets {
+		c := criteria
+		c.OS, c.Arch = target.OS, target.Arch
+		c = archiveForCrossTarget(c, host)
+
+		file, err := SelectFile(info, c)
+		results[i] = TargetResult{Target: target, File: file, Err: err}
+	}
+
+	downloadResults(ctx, results, parallel, opts)
+
+	return results
+}
+
+// downloadAllFiles downloads and verifies every file of the release version in info, whatever
+// its platform or kind, at most parallel at a time. It returns ErrVersionNotFound if info has
+// no such release.
+func downloadAllFiles(ctx context.Context, info ReleaseInfo, version string, parallel int,
+	opts DownloadOptions,
+) (Results, error) {
+	release, err := findRelease(info, version)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(Results, len(release.Files))
+	for i, file := range release.Files {
+		results[i] = TargetResult{File: file}
+	}
+
+	downloadResults(ctx, results, parallel, opts)
+
+	return results, nil
+}
+
+// downloadResults downloads the file of each result without an error, at most parallel at a
+// time, and records the outcome in the result. With opts.Manifest, every file is checked
+// against the manifest before any download starts, and a file that fails is not downloaded.
+func downloadResults(ctx context.Context, results Results, parallel int, opts DownloadOptions) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = opts.checkManifest(results[i].File)
+		}
+	}
+
+	// Progress lines of concurrent downloads would overwrite each other.
+	if parallel > 1 && (opts.Progress == "" || opts.Progress == ProgressTerminal) {
+		opts.Progress = ProgressNone
+	}
+
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *TargetResult) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result.DownloadResult, result.Err = DownloadRelease(ctx, result.File, opts)
+		}(&results[i])
+	}
+
+	wg.Wait()
+}
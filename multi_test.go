@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestDownloadTargets(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	contents := map[string][]byte{}
+	var files []ReleaseFile
+
+	for _, p := range []Platform{{"linux", "amd64"}, {"linux", "arm64"}, {"darwin", "arm64"}, {"freebsd", "amd64"}} {
+		name := fmt.Sprintf("go1.21.5.%s-%s.tar.gz", p.OS, p.Arch)
+		contents[name] = []byte("contents of " + name)
+
+		files = append(files, ReleaseFile{
+			Filename: name,
+			OS:       p.OS,
+			Arch:     p.Arch,
+			Version:  "go1.21.5",
+			SHA256:   fmt.Sprintf("%x", sha256.Sum256(contents[name])),
+			Size:     int64(len(contents[name])),
+			Kind:     "archive",
+		})
+	}
+
+	// The freebsd file is corrupted on the server.
+	contents["go1.21.5.freebsd-amd64.tar.gz"] = []byte("corrupted")
+
+	info := ReleaseInfo{{Version: "go1.21.5", Stable: true, Files: files}}
+
+	var mu sync.Mutex
+	var active, maxActive int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write(contents[path.Base(r.URL.Path)])
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	targets := []Platform{{"linux", "amd64"}, {"linux", "arm64"}, {"darwin", "arm64"}, {"freebsd", "amd64"}, {"windows", "386"}}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir()}
+
+	results := downloadTargets(context.Background(), info, SelectCriteria{}, Platform{"linux", "amd64"}, targets, 2, opts)
+
+	if len(results) != len(targets) {
+		t.Fatalf("Unexpected results.\n Got: %d\nWant: %d", len(results), len(targets))
+	}
+
+	for i, r := range results[:3] {
+		if r.Err != nil {
+			t.Errorf("Unexpected error for %s: %v", r.Target, r.Err)
+		}
+
+		if r.Checksum != files[i].SHA256 {
+			t.Errorf("Unexpected checksum for %s.\n Got: %s\nWant: %s", r.Target, r.Checksum, files[i].SHA256)
+		}
+	}
+
+	if !errors.Is(results[3].Err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", results[3].Err, ErrChecksumMismatch)
+	}
+
+	if !errors.Is(results[4].Err, ErrNoMatchingFile) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", results[4].Err, ErrNoMatchingFile)
+	}
+
+	if err := results.Err(); !errors.Is(err, ErrChecksumMismatch) || !errors.Is(err, ErrNoMatchingFile) {
+		t.Errorf("Unexpected aggregate error: %v", err)
+	}
+
+	if maxActive > 2 {
+		t.Errorf("Unexpected concurrency.\n Got: %d\nWant: <= 2", maxActive)
+	}
+}
+
+func TestDownloadTargetsCrossTarget(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	contents := map[string][]byte{}
+	var files []ReleaseFile
+
+	for _, f := range []ReleaseFile{
+		{Filename: "go1.21.5.darwin-arm64.pkg", OS: "darwin", Arch: "arm64", Kind: "installer"},
+		{Filename: "go1.21.5.darwin-arm64.tar.gz", OS: "darwin", Arch: "arm64", Kind: "archive"},
+	} {
+		contents[f.Filename] = []byte("contents of " + f.Filename)
+
+		f.Version = "go1.21.5"
+		f.SHA256 = fmt.Sprintf("%x", sha256.Sum256(contents[f.Filename]))
+		f.Size = int64(len(contents[f.Filename]))
+		files = append(files, f)
+	}
+
+	info := ReleaseInfo{{Version: "go1.21.5", Stable: true, Files: files}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(contents[path.Base(r.URL.Path)])
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		host     Platform
+		criteria SelectCriteria
+		expected string
+	}{
+		{"cross target", Platform{"linux", "amd64"}, SelectCriteria{}, "go1.21.5.darwin-arm64.tar.gz"},
+		{"host target", Platform{"darwin", "arm64"}, SelectCriteria{}, "go1.21.5.darwin-arm64.pkg"},
+		{"kind preference", Platform{"linux", "amd64"}, SelectCriteria{Kinds: []string{"installer", "archive"}}, "go1.21.5.darwin-arm64.pkg"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone}
+
+			results := downloadTargets(context.Background(), info, tc.criteria, tc.host, []Platform{{"darwin", "arm64"}}, 1, opts)
+			if err := results.Err(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := results[0].File.Filename; got != tc.expected {
+				t.Errorf("Unexpected file.\n Got: %s\nWant: %s", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadAllFiles(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	contents := map[string][]byte{}
+	var files []ReleaseFile
+
+	for _, f := range []ReleaseFile{
+		{Filename: "go1.22.3.src.tar.gz", Kind: "source"},
+		{Filename: "go1.22.3.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Kind: "archive"},
+		{Filename: "go1.22.3.windows-amd64.zip", OS: "windows", Arch: "amd64", Kind: "archive"},
+		{Filename: "go1.22.3.windows-amd64.msi", OS: "windows", Arch: "amd64", Kind: "installer"},
+	} {
+		contents[f.Filename] = []byte("contents of " + f.Filename)
+
+		f.Version = "go1.22.3"
+		f.SHA256 = fmt.Sprintf("%x", sha256.Sum256(contents[f.Filename]))
+		f.Size = int64(len(contents[f.Filename]))
+		files = append(files, f)
+	}
+
+	// The msi is corrupted on the server.
+	contents["go1.22.3.windows-amd64.msi"] = []byte("corrupted")
+
+	info := ReleaseInfo{
+		{Version: "go1.22.3", Stable: true, Files: files},
+		{Version: "go1.21.10", Stable: true, Files: []ReleaseFile{{Filename: "go1.21.10.src.tar.gz", Version: "go1.21.10"}}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(contents[path.Base(r.URL.Path)])
+	}))
+	defer server.Close()
+
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir()}
+
+	results, err := downloadAllFiles(context.Background(), info, "go1.22.3", 3, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(results) != len(files) {
+		t.Fatalf("Unexpected results.\n Got: %d\nWant: %d", len(results), len(files))
+	}
+
+	for i, r := range results[:3] {
+		if r.Err != nil {
+			t.Errorf("Unexpected error for %s: %v", r.File.Filename, r.Err)
+		}
+
+		if r.Checksum != files[i].SHA256 {
+			t.Errorf("Unexpected checksum for %s.\n Got: %s\nWant: %s", r.File.Filename, r.Checksum, files[i].SHA256)
+		}
+	}
+
+	if !errors.Is(results[3].Err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", results[3].Err, ErrChecksumMismatch)
+	}
+
+	if got := results.Failed(); got != 1 {
+		t.Errorf("Unexpected failures.\n Got: %d\nWant: %d", got, 1)
+	}
+
+	if err := results.Err(); err == nil || !strings.Contains(err.Error(), "go1.22.3.windows-amd64.msi: ") {
+		t.Errorf("Unexpected aggregate error: %v", err)
+	}
+
+	_, err = downloadAllFiles(context.Background(), info, "go1.20", 1, opts)
+	if !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrVersionNotFound)
+	}
+}
+
+func TestDownloadAllFilesManifest(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	trusted := newTestEntity(t, "trusted")
+	other := newTestEntity(t, "other")
+
+	contents := map[string][]byte{}
+	var files []ReleaseFile
+
+	for _, f := range []ReleaseFile{
+		{Filename: "go1.22.3.src.tar.gz", Kind: "source"},
+		{Filename: "go1.22.3.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Kind: "archive"},
+		{Filename: "go1.22.3.darwin-arm64.tar.gz", OS: "darwin", Arch: "arm64", Kind: "archive"},
+	} {
+		contents[f.Filename] = []byte("contents of " + f.Filename)
+
+		f.Version = "go1.22.3"
+		f.SHA256 = fmt.Sprintf("%x", sha256.Sum256(contents[f.Filename]))
+		f.Size = int64(len(contents[f.Filename]))
+		files = append(files, f)
+	}
+	info := ReleaseInfo{{Version: "go1.22.3", Stable: true, Files: files}}
+
+	// The manifest agrees with the feed on the source, disagrees on linux, and omits darwin.
+	manifest := []byte(fmt.Sprintf("%s  %s\n%x  %s\n", files[0].SHA256, files[0].Filename,
+		sha256.Sum256([]byte("other")), files[1].Filename))
+
+	sign := func(signer *openpgp.Entity) []byte {
+		var sig bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(manifest), nil); err != nil {
+			t.Fatalf("cannot sign: %v", err)
+		}
+		return sig.Bytes()
+	}
+
+	var keyring bytes.Buffer
+	if err := trusted.Serialize(&keyring); err != nil {
+		t.Fatal(err)
+	}
+	keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+	if err := os.WriteFile(keyringPath, keyring.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var requested []string
+	var sig []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch name := path.Base(r.URL.Path); name {
+		case "SHA256SUMS":
+			w.Write(manifest)
+		case "SHA256SUMS.asc":
+			w.Write(sig)
+		default:
+			mu.Lock()
+			requested = append(requested, name)
+			mu.Unlock()
+			w.Write(contents[name])
+		}
+	}))
+	defer server.Close()
+
+	m := ManifestOptions{URL: server.URL + "/SHA256SUMS", KeyringPath: keyringPath}
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: t.TempDir(), Progress: ProgressNone}
+
+	// A manifest signed by a key that is not in the keyring stops the run before any download.
+	sig = sign(other)
+	if _, err := m.downloadOptions(opts); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrBadSignature)
+	}
+
+	sig = sign(trusted)
+	opts, err := m.downloadOptions(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	results, err := downloadAllFiles(context.Background(), info, "go1.22.3", 2, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedErrors := []error{nil, ErrManifestMismatch, ErrNotInManifest}
+	for i, r := range results {
+		if !errors.Is(r.Err, expectedErrors[i]) {
+			t.Errorf("Unexpected error for %s.\n Got: %v\nWant: %v", r.File.Filename, r.Err, expectedErrors[i])
+		}
+	}
+
+	// Only the file that the manifest agrees with is downloaded.
+	if fmt.Sprint(requested) != fmt.Sprint([]string{files[0].Filename}) {
+		t.Errorf("Unexpected requests.\n Got: %v\nWant: %v", requested, []string{files[0].Filename})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	got, err := parseTargets("linux/amd64, darwin/arm64")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []Platform{{"linux", "amd64"}, {"darwin", "arm64"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Unexpected targets.\n Got: %v\nWant: %v", got, want)
+	}
+
+	for _, s := range []string{"linux", "linux/", "/amd64", "linux/amd64,"} {
+		if _, err := parseTargets(s); !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("Unexpected error for %q.\n Got: %v\nWant: %v", s, err, ErrInvalidTarget)
+		}
+	}
+}
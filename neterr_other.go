@@ -0,0 +1,10 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+//go:build !unix && !windows
+
+package main
+
+// connectionErrnos is empty where connection errors have no portable codes, so only
+// timeouts are retried, see isTransient.
+var connectionErrnos []error
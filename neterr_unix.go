@@ -0,0 +1,12 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+//go:build unix
+
+package main
+
+import "syscall"
+
+// connectionErrnos are the errors of a connection that was refused, reset, or aborted,
+// which are worth retrying, see isTransient.
+var connectionErrnos = []error{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED}
@@ -0,0 +1,13 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import "syscall"
+
+// wsaeconnrefused is the Winsock error of a refused connection, which package syscall lacks.
+const wsaeconnrefused syscall.Errno = 10061
+
+// connectionErrnos are the errors of a connection that was refused, reset, or aborted,
+// which are worth retrying, see isTransient. Winsock reports them with its own codes.
+var connectionErrnos = []error{wsaeconnrefused, syscall.WSAECONNRESET, syscall.WSAECONNABORTED}
@@ -0,0 +1,151 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// netrcEntry is the login and password of a machine in a netrc file.
+// An empty Machine is the default entry, which matches any host.
+type netrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+}
+
+// parseNetrc parses the machine, default, login, and password tokens of a netrc file.
+// Other tokens are ignored, and macdef bodies are skipped.
+func parseNetrc(r io.Reader) ([]netrcEntry, error) {
+	var entries []netrcEntry
+	var entry *netrcEntry
+
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A macro definition ends at an empty line.
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{Machine: value})
+				entry = &entries[len(entries)-1]
+				i++
+			case "default":
+				entries = append(entries, netrcEntry{})
+				entry = &entries[len(entries)-1]
+			case "login":
+				if entry != nil {
+					entry.Login = value
+				}
+				i++
+			case "password":
+				if entry != nil {
+					entry.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// netrcPath returns the path of the netrc file: $NETRC if set, otherwise .netrc in the home
+// directory, or _netrc on Windows.
+func netrcPath(getenv func(string) string) (string, error) {
+	if path := getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name), nil
+}
+
+// readNetrc reads the netrc file at path. A missing file has no entries.
+func readNetrc(path string) ([]netrcEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseNetrc(f)
+}
+
+// lookupNetrc returns the first entry for host, falling back to the default entry.
+func lookupNetrc(entries []netrcEntry, host string) (netrcEntry, bool) {
+	for _, e := range entries {
+		if e.Machine == host {
+			return e, true
+		}
+	}
+
+	for _, e := range entries {
+		if e.Machine == "" {
+			return e, true
+		}
+	}
+
+	return netrcEntry{}, false
+}
+
+// netrcTransport is an http.RoundTripper that adds basic auth from netrc entries to requests
+// for a matching host, unless the request already has an Authorization header.
+// The credentials are never logged.
+type netrcTransport struct {
+	base    http.RoundTripper
+	entries []netrcEntry
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *netrcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		if e, ok := lookupNetrc(t.entries, req.URL.Hostname()); ok && e.Login != "" {
+			// A RoundTripper must not modify the caller's request.
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(e.Login, e.Password)
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `machine mirror.example.com login alice password s3cret
+macdef init
+cd /pub
+machine ignored.example.com
+
+machine other.example.com
+	login bob
+	account ops
+	password hunter2
+default login anonymous password guest
+`
+
+	entries, err := parseNetrc(strings.NewReader(netrc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		host     string
+		expected netrcEntry
+	}{
+		{host: "mirror.example.com", expected: netrcEntry{Machine: "mirror.example.com", Login: "alice", Password: "s3cret"}},
+		{host: "other.example.com", expected: netrcEntry{Machine: "other.example.com", Login: "bob", Password: "hunter2"}},
+		{host: "ignored.example.com", expected: netrcEntry{Login: "anonymous", Password: "guest"}},
+		{host: "go.dev", expected: netrcEntry{Login: "anonymous", Password: "guest"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			got, ok := lookupNetrc(entries, tc.host)
+			if !ok || got != tc.expected {
+				t.Errorf("Unexpected entry.\n Got: %+v, %v\nWant: %+v", got, ok, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNetrcTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "alice" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	host := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")[0]
+
+	testCases := []struct {
+		name           string
+		netrc          string
+		expectedStatus int
+	}{
+		{name: "Matching machine", netrc: "machine " + host + " login alice password s3cret\n", expectedStatus: http.StatusOK},
+		{name: "Wrong password", netrc: "machine " + host + " login alice password wrong\n", expectedStatus: http.StatusUnauthorized},
+		{name: "Other machine", netrc: "machine mirror.example.com login alice password s3cret\n", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			netrcFile := filepath.Join(t.TempDir(), "netrc")
+			if err := os.WriteFile(netrcFile, []byte(tc.netrc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := netrcPath(func(key string) string {
+				if key == "NETRC" {
+					return netrcFile
+				}
+				return ""
+			})
+			if err != nil || path != netrcFile {
+				t.Fatalf("Unexpected path.\n Got: %q, %v\nWant: %q", path, err, netrcFile)
+			}
+
+			entries, err := readNetrc(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			client := &http.Client{Transport: &netrcTransport{base: http.DefaultTransport, entries: entries}}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, tc.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestReadNetrcMissing(t *testing.T) {
+	entries, err := readNetrc(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || entries != nil {
+		t.Errorf("Unexpected result.\n Got: %v, %v\nWant: no entries", entries, err)
+	}
+}
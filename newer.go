@@ -0,0 +1,80 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// newerReleases returns the releases in info newer than current, oldest first.
+// Only releases with a file for goos and goarch are included, and only stable releases if stableOnly is set.
+func newerReleases(info ReleaseInfo, current goVersion, goos, goarch string, stableOnly bool) []Release {
+	type versioned struct {
+		release Release
+		version goVersion
+	}
+
+	var newer []versioned
+
+	for _, release := range info {
+		v, ok := parseGoVersion(release.Version)
+		if !ok || v.Compare(current) <= 0 || (stableOnly && !release.Stable) {
+			continue
+		}
+
+		if !hasPlatformFile(release, goos, goarch) {
+			continue
+		}
+
+		newer = append(newer, versioned{release, v})
+	}
+
+	sort.Slice(newer, func(i, j int) bool {
+		return newer[i].version.Compare(newer[j].version) < 0
+	})
+
+	releases := make([]Release, len(newer))
+	for i, n := range newer {
+		releases[i] = n.release
+	}
+
+	return releases
+}
+
+// hasPlatformFile reports whether release has a file for goos and goarch.
+func hasPlatformFile(release Release, goos, goarch string) bool {
+	for _, file := range release.Files {
+		if file.OS == goos && file.Arch == goarch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runListNewer implements the -list-newer mode and returns the exit code.
+func runListNewer(current, goos, goarch string, stableOnly bool) int {
+	v, ok := parseGoVersion(current)
+	if !ok {
+		fmt.Printf("Cannot compare %q with releases.\n", current)
+		return ExitErrUsage
+	}
+
+	releaseInfo, err := loadReleaseInfo(allReleasesURL)
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	for _, release := range newerReleases(releaseInfo, v, goos, goarch, stableOnly) {
+		if release.Stable {
+			fmt.Println(release.Version)
+		} else {
+			fmt.Println(release.Version + "\tunstable")
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewerReleases(t *testing.T) {
+	linux := []ReleaseFile{{OS: "linux", Arch: "amd64"}}
+
+	// Not in order, as in a feed assembled from several sources.
+	info := ReleaseInfo{
+		{Version: "go1.22rc1", Stable: false, Files: linux},
+		{Version: "go1.21.5", Stable: true, Files: linux},
+		{Version: "go1.21.3", Stable: true, Files: linux},
+		{Version: "go1.21.4", Stable: true, Files: []ReleaseFile{{OS: "darwin", Arch: "arm64"}}},
+		{Version: "go1.22.0", Stable: true, Files: linux},
+		{Version: "go1.20.12", Stable: true, Files: linux},
+		{Version: "go1.22beta1", Stable: false, Files: linux},
+	}
+
+	testCases := []struct {
+		name       string
+		current    string
+		stableOnly bool
+		expected   []string
+	}{
+		{
+			name:     "All newer",
+			current:  "go1.21.3",
+			expected: []string{"go1.21.5", "go1.22beta1", "go1.22rc1", "go1.22.0"},
+		},
+		{
+			name:       "Stable only",
+			current:    "go1.21.3",
+			stableOnly: true,
+			expected:   []string{"go1.21.5", "go1.22.0"},
+		},
+		{
+			name:     "From a release candidate",
+			current:  "go1.22beta1",
+			expected: []string{"go1.22rc1", "go1.22.0"},
+		},
+		{
+			name:     "Up to date",
+			current:  "go1.22.0",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current, ok := parseGoVersion(tc.current)
+			if !ok {
+				t.Fatalf("Invalid version %q", tc.current)
+			}
+
+			var got []string
+			for _, release := range newerReleases(info, current, "linux", "amd64", tc.stableOnly) {
+				got = append(got, release.Version)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Unexpected releases.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// UpdateNotification is the payload sent to a webhook when an update is detected.
+//
+// The default JSON body is stable; fields may be added but not renamed or removed:
+//
+//	{"current_version":"go1.21.4","latest_version":"go1.21.5","timestamp":"2023-12-05T18:00:00Z",
+//	 "summary":"Go update available: go1.21.4 → go1.21.5 (0 minor, 1 patch behind)"}
+//
+// A custom template can reference {{.CurrentVersion}}, {{.LatestVersion}}, {{.Timestamp}}, and {{.Summary}}.
+type UpdateNotification struct {
+	CurrentVersion string    `json:"current_version"`
+	LatestVersion  string    `json:"latest_version"`
+	Timestamp      time.Time `json:"timestamp"`
+	Summary        string    `json:"summary,omitempty"` // From SummarizeUpgrade, or empty if a version is unknown.
+}
+
+// newUpdateNotification returns the notification of an update from current to latest.
+func newUpdateNotification(current, latest string) UpdateNotification {
+	summary, _ := SummarizeUpgrade(current, latest)
+
+	return UpdateNotification{
+		CurrentVersion: current,
+		LatestVersion:  latest,
+		Timestamp:      time.Now().UTC(),
+		Summary:        summary,
+	}
+}
+
+// Notifier POSTs update notifications to a webhook.
+type Notifier struct {
+	URL        string             // Webhook URL.
+	Template   *template.Template // Optional template for the body. If nil, the JSON payload is sent.
+	Retries    int                // Additional attempts after a failed POST.
+	RetryDelay time.Duration      // Delay before the first retry, doubled for each subsequent retry.
+}
+
+// body renders the request body for note.
+func (n *Notifier) body(note UpdateNotification) ([]byte, error) {
+	if n.Template == nil {
+		return json.Marshal(note)
+	}
+
+	var buf bytes.Buffer
+
+	err := n.Template.Execute(&buf, note)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Notify POSTs note to the webhook, retrying failed attempts up to n.Retries times.
+func (n *Notifier) Notify(ctx context.Context, note UpdateNotification) error {
+	err := checkSecureURL(n.URL)
+	if err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+
+	body, err := n.body(note)
+	if err != nil {
+		return err
+	}
+
+	delay := n.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil || attempt >= n.Retries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to notify after %d attempts: %w", n.Retries+1, err)
+	}
+
+	return nil
+}
+
+// post sends a single notification request.
+func (n *Notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%q %s", n.URL, http.StatusText(resp.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestNotifierRetriesAndPayload(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	note := UpdateNotification{
+		CurrentVersion: "go1.21.4",
+		LatestVersion:  "go1.21.5",
+		Timestamp:      time.Date(2023, 12, 5, 18, 0, 0, 0, time.UTC),
+	}
+
+	n := &Notifier{URL: server.URL, Retries: 1}
+	if err := n.Notify(context.Background(), note); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Unexpected attempts.\n Got: %d\nWant: %d", len(bodies), 2)
+	}
+
+	var got UpdateNotification
+	if err := json.Unmarshal([]byte(bodies[1]), &got); err != nil {
+		t.Fatalf("Invalid payload %q: %v", bodies[1], err)
+	}
+	if got != note {
+		t.Errorf("Unexpected payload.\n Got: %+v\nWant: %+v", got, note)
+	}
+
+	// A template replaces the default payload.
+	bodies = []string{"skip first-attempt failure"}
+	n.Template = template.Must(template.New("").Parse(`{"text":"Go {{.LatestVersion}} is out"}`))
+	if err := n.Notify(context.Background(), note); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `{"text":"Go go1.21.5 is out"}`
+	if bodies[1] != want {
+		t.Errorf("Unexpected templated payload.\n Got: %q\nWant: %q", bodies[1], want)
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var attempts int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := &Notifier{URL: server.URL, Retries: 2}
+	if err := n.Notify(context.Background(), UpdateNotification{}); err == nil {
+		t.Error("Expected error")
+	}
+
+	if attempts != 3 {
+		t.Errorf("Unexpected attempts.\n Got: %d\nWant: %d", attempts, 3)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// envOutputDir overrides the default of -output-dir.
+const envOutputDir = "GO_DL_OUTPUT_DIR"
+
+// defaultOutputDir returns the directory to save downloads in when -output-dir is not set.
+//
+// GO_DL_OUTPUT_DIR is used if set. Otherwise it is the downloads directory of the user on
+// goos: $XDG_DOWNLOAD_DIR on Linux, ~/Downloads on macOS, and %USERPROFILE%\Downloads on
+// Windows, if it is an existing directory. Otherwise it is the current directory, ".".
+func defaultOutputDir(goos string, getenv func(string) string, isDir func(string) bool) string {
+	if dir := getenv(envOutputDir); dir != "" {
+		return dir
+	}
+
+	var dir string
+
+	switch goos {
+	case "linux":
+		// user-dirs.dirs writes the directory as "$HOME/Downloads".
+		if xdg := getenv("XDG_DOWNLOAD_DIR"); xdg != "" {
+			dir = os.Expand(xdg, getenv)
+		}
+	case "darwin":
+		if home := getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Downloads")
+		}
+	case "windows":
+		if profile := getenv("USERPROFILE"); profile != "" {
+			dir = filepath.Join(profile, "Downloads")
+		}
+	}
+
+	if dir == "" || !isDir(dir) {
+		return "."
+	}
+
+	return dir
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
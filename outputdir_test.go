@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultOutputDir(t *testing.T) {
+	dirs := map[string]bool{
+		"/home/gopher/Downloads":                      true,
+		"/Users/gopher/Downloads":                     true,
+		filepath.Join(`C:\Users\gopher`, "Downloads"): true,
+	}
+	isDir := func(path string) bool { return dirs[path] }
+
+	testCases := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want string
+	}{
+		{"linux xdg", "linux", map[string]string{"XDG_DOWNLOAD_DIR": "/home/gopher/Downloads"}, "/home/gopher/Downloads"},
+		{"linux xdg home", "linux", map[string]string{"HOME": "/home/gopher", "XDG_DOWNLOAD_DIR": "$HOME/Downloads"}, "/home/gopher/Downloads"},
+		{"linux xdg missing", "linux", map[string]string{"XDG_DOWNLOAD_DIR": "/home/gopher/Missing"}, "."},
+		{"linux no xdg", "linux", map[string]string{"HOME": "/home/gopher"}, "."},
+		{"darwin", "darwin", map[string]string{"HOME": "/Users/gopher"}, "/Users/gopher/Downloads"},
+		{"darwin no downloads", "darwin", map[string]string{"HOME": "/Users/other"}, "."},
+		{"windows", "windows", map[string]string{"USERPROFILE": `C:\Users\gopher`}, filepath.Join(`C:\Users\gopher`, "Downloads")},
+		{"windows no profile", "windows", nil, "."},
+		{"other", "freebsd", map[string]string{"XDG_DOWNLOAD_DIR": "/home/gopher/Downloads"}, "."},
+		{"override", "linux", map[string]string{envOutputDir: "/srv/go", "XDG_DOWNLOAD_DIR": "/home/gopher/Downloads"}, "/srv/go"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(key string) string { return tc.env[key] }
+
+			got := defaultOutputDir(tc.goos, getenv, isDir)
+			if got != tc.want {
+				t.Errorf("Unexpected dir.\n Got: %q\nWant: %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidPin  = errors.New("invalid pin")
+	ErrPinMismatch = errors.New("no certificate matches a pinned public key")
+)
+
+// spkiPin returns the base64 SHA256 hash of the SubjectPublicKeyInfo of cert, the format
+// used by HTTP public key pinning, e.g. as printed by
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// officialDownloadHosts serve downloads from the default base URL, go.dev, which redirects
+// to dl.google.com.
+var officialDownloadHosts = []string{"go.dev", "dl.google.com"}
+
+// downloadHosts returns the hosts that files are downloaded from with baseURLs, such as
+// DownloadOptions.sources, using officialDownloadHosts for an empty base URL.
+func downloadHosts(baseURLs ...string) ([]string, error) {
+	var hosts []string
+
+	for _, baseURL := range baseURLs {
+		if baseURL == "" {
+			hosts = append(hosts, officialDownloadHosts...)
+			continue
+		}
+
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, strings.ToLower(u.Hostname()))
+	}
+
+	return hosts, nil
+}
+
+// hostPins maps host names, in lower case and without ports, to the pins of their certificates.
+type hostPins map[string][]string
+
+// parsePins parses pins, each a base64 SHA256 hash of a SubjectPublicKeyInfo. A pin given as
+// host=pin only applies to host, such as a host downloads are redirected to, while a bare pin
+// applies to each of defaultHosts, normally the download hosts.
+func parsePins(pins, defaultHosts []string) (hostPins, error) {
+	p := make(hostPins)
+
+	for _, entry := range pins {
+		hosts := defaultHosts
+		pin := strings.TrimSpace(entry)
+
+		// A pin only has "=" as padding at its end, so an earlier one ends the host.
+		if i := strings.Index(pin, "="); i >= 0 && i < len(pin)-1 {
+			hosts, pin = []string{strings.ToLower(pin[:i])}, pin[i+1:]
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%w: %q is not a base64 SHA256 hash", ErrInvalidPin, pin)
+		}
+
+		for _, host := range hosts {
+			p[host] = append(p[host], pin)
+		}
+	}
+
+	return p, nil
+}
+
+// verifyPins returns a tls.Config.VerifyConnection function that accepts a connection only
+// if the presented or verified chain contains a certificate whose public key matches one of pins.
+// It runs after the usual certificate verification, so a pin does not replace trusting the CA,
+// and unlike VerifyPeerCertificate it also runs for resumed sessions.
+func verifyPins(host string, pins []string) func(cs tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		certs := slices.Clone(cs.PeerCertificates)
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+
+		for _, cert := range certs {
+			if slices.Contains(pins, spkiPin(cert)) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w for %s", ErrPinMismatch, host)
+	}
+}
+
+// pinnedTransport is an http.RoundTripper that sends requests to each pinned host through
+// its own copy of base, which rejects TLS connections unless a certificate in the chain matches
+// one of the pins of the host. Requests to other hosts, such as a webhook, use base unchanged.
+type pinnedTransport struct {
+	base   *http.Transport
+	pinned map[string]*http.Transport
+}
+
+// pinTransport returns a pinnedTransport for pins using base.
+func pinTransport(base *http.Transport, pins hostPins) *pinnedTransport {
+	t := &pinnedTransport{base: base, pinned: make(map[string]*http.Transport)}
+
+	for host, hostPins := range pins {
+		pinned := base.Clone()
+		if pinned.TLSClientConfig == nil {
+			pinned.TLSClientConfig = &tls.Config{}
+		}
+		pinned.TLSClientConfig.VerifyConnection = verifyPins(host, hostPins)
+		t.pinned[host] = pinned
+	}
+
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *pinnedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if pinned, ok := t.pinned[strings.ToLower(req.URL.Hostname())]; ok {
+		return pinned.RoundTrip(req)
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPinTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := u.Hostname()
+
+	goodPin := spkiPin(server.Certificate())
+	badPin := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	testCases := []struct {
+		name          string
+		pins          hostPins
+		expectedError error
+	}{
+		{name: "Matching pin", pins: hostPins{host: {goodPin}}},
+		{name: "One of several pins matches", pins: hostPins{host: {badPin, goodPin}}},
+		{name: "No matching pin", pins: hostPins{host: {badPin}}, expectedError: ErrPinMismatch},
+		{name: "Other host pinned", pins: hostPins{"dl.google.com": {badPin}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{Transport: pinTransport(server.Client().Transport.(*http.Transport), tc.pins)}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	const pin = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	const other = "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="
+
+	hosts, err := downloadHosts("", "https://Mirror.example:8443/go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parsePins([]string{pin, " Redirect.example=" + other}, hosts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := hostPins{
+		"go.dev":           {pin},
+		"dl.google.com":    {pin},
+		"mirror.example":   {pin},
+		"redirect.example": {other},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected pins.\n Got: %v\nWant: %v", got, want)
+	}
+}
+
+func TestParsePinsInvalid(t *testing.T) {
+	for _, pin := range []string{"not base64!", "c2hvcnQ=", strings.Repeat("A", 64), "go.dev=c2hvcnQ="} {
+		_, err := parsePins([]string{pin}, officialDownloadHosts)
+		if !errors.Is(err, ErrInvalidPin) {
+			t.Errorf("Unexpected error for %q.\n Got: %v\nWant: %v", pin, err, ErrInvalidPin)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Platform identifies a target operating system and architecture.
+type Platform struct {
+	OS   string
+	Arch string
+}
+
+// String returns the platform in GOOS/GOARCH form.
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// specialPlatforms are targets that no system runs Go natively on, such as js/wasm, which runs
+// in a browser or Node.js, and wasip1/wasm, which runs in a WASI runtime.
+var specialPlatforms = map[Platform]bool{
+	{OS: "js", Arch: "wasm"}:     true,
+	{OS: "wasip1", Arch: "wasm"}: true,
+}
+
+// isCrossTarget reports whether files for p cannot be installed as the Go of host,
+// because p is another platform or a special platform.
+func isCrossTarget(p, host Platform) bool {
+	return p != host || specialPlatforms[p]
+}
+
+// archiveForCrossTarget returns criteria selecting only an archive if its target is a cross
+// target of host and no kind preference is set. An installer installs Go on the machine that
+// runs it, so for another platform the archive is the useful download.
+func archiveForCrossTarget(criteria SelectCriteria, host Platform) SelectCriteria {
+	if len(criteria.Kinds) == 0 && isCrossTarget(Platform{OS: criteria.OS, Arch: criteria.Arch}, host) {
+		criteria.Kinds = []string{"archive"}
+	}
+
+	return criteria
+}
+
+// AvailablePlatforms returns the distinct platforms with files in the release, sorted by OS and Arch.
+// Source files, which have no OS or Arch, are not included.
+func AvailablePlatforms(release Release) []Platform {
+	seen := make(map[Platform]bool)
+	var platforms []Platform
+
+	for _, file := range release.Files {
+		p := Platform{OS: file.OS, Arch: file.Arch}
+		if p.OS == "" || seen[p] {
+			continue
+		}
+
+		seen[p] = true
+		platforms = append(platforms, p)
+	}
+
+	sort.Slice(platforms, func(i, j int) bool {
+		if platforms[i].OS != platforms[j].OS {
+			return platforms[i].OS < platforms[j].OS
+		}
+		return platforms[i].Arch < platforms[j].Arch
+	})
+
+	return platforms
+}
+
+// fileDescriptor describes a release file by its OS, architecture, and kind, independent of version.
+func fileDescriptor(file ReleaseFile) string {
+	if file.OS == "" {
+		return file.Kind
+	}
+
+	return file.OS + "/" + file.Arch + " " + file.Kind
+}
+
+// PlatformSelection is the file selected for a platform, or why none could be selected.
+type PlatformSelection struct {
+	Platform Platform
+	File     ReleaseFile
+	Err      error
+}
+
+// selectAll runs SelectFile with criteria for each of the AvailablePlatforms of the release
+// that criteria selects. The OS and Arch of criteria are ignored.
+func selectAll(info ReleaseInfo, criteria SelectCriteria) ([]PlatformSelection, error) {
+	release, err := selectRelease(info, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	var selections []PlatformSelection
+
+	for _, p := range AvailablePlatforms(release) {
+		c := criteria
+		c.OS, c.Arch = p.OS, p.Arch
+
+		// Select from the chosen release only, as SelectFile would.
+		c.Version = release.Version
+
+		file, err := SelectFile(info, c)
+		selections = append(selections, PlatformSelection{Platform: p, File: file, Err: err})
+	}
+
+	return selections, nil
+}
+
+// printSelections writes one tab-separated line per selection, in order:
+// platform, filename, size, and SHA256, or platform and "error" with the reason.
+func printSelections(w io.Writer, selections []PlatformSelection) {
+	for _, s := range selections {
+		if s.Err != nil {
+			fmt.Fprintf(w, "%s\terror\t%v\n", s.Platform, s.Err)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Platform, s.File.Filename, s.File.Size, s.File.SHA256)
+	}
+}
+
+// runSelectAll implements the -select-all mode and returns the exit code.
+// It returns ExitErrMatchFile if no file could be selected for some platform.
+func runSelectAll(feedURL string, criteria SelectCriteria) int {
+	releaseInfo, err := loadReleaseInfo(feedURL)
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	selections, err := selectAll(releaseInfo, criteria)
+	if err != nil {
+		fmt.Printf("Error finding matching release: %v\n", err)
+		return ExitErrMatchFile
+	}
+
+	printSelections(os.Stdout, selections)
+
+	for _, s := range selections {
+		if s.Err != nil {
+			return ExitErrMatchFile
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSelectAll(t *testing.T) {
+	testCases := []struct {
+		name     string
+		criteria SelectCriteria
+		expected string
+	}{
+		{
+			name:     "Default kinds",
+			criteria: SelectCriteria{},
+			expected: "darwin/arm64\tgo1.21.5.darwin-arm64.pkg\t0\t\n" +
+				"linux/amd64\tgo1.21.5.linux-amd64.tar.gz\t0\t\n" +
+				"plan9/arm\terror\tambiguous match: 2 archive files for plan9/arm in go1.21.5\n" +
+				"windows/amd64\tgo1.21.5.windows-amd64.msi\t0\t\n",
+		},
+		{
+			name:     "Prefer archive",
+			criteria: SelectCriteria{Kinds: []string{"archive", "installer"}, Extensions: []string{".tar.gz"}},
+			expected: "darwin/arm64\tgo1.21.5.darwin-arm64.tar.gz\t0\t\n" +
+				"linux/amd64\tgo1.21.5.linux-amd64.tar.gz\t0\t\n" +
+				"plan9/arm\tgo1.21.5.plan9-arm.tar.gz\t0\t\n" +
+				"windows/amd64\tgo1.21.5.windows-amd64.zip\t0\t\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selections, err := selectAll(testReleaseInfo, tc.criteria)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			printSelections(&buf, selections)
+
+			if buf.String() != tc.expected {
+				t.Errorf("Unexpected output.\n Got: %q\nWant: %q", buf.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestArchiveForCrossTarget(t *testing.T) {
+	info := ReleaseInfo{{
+		Version: "go1.21.5",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{Filename: "go1.21.5.windows-amd64.msi", OS: "windows", Arch: "amd64", Version: "go1.21.5", Kind: "installer"},
+			{Filename: "go1.21.5.windows-amd64.zip", OS: "windows", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+		},
+	}}
+
+	testCases := []struct {
+		name     string
+		host     Platform
+		kinds    []string
+		expected string
+	}{
+		{name: "Host", host: Platform{OS: "windows", Arch: "amd64"}, expected: "go1.21.5.windows-amd64.msi"},
+		{name: "Cross target", host: Platform{OS: "linux", Arch: "amd64"}, expected: "go1.21.5.windows-amd64.zip"},
+		{name: "Cross target prefers installer", host: Platform{OS: "linux", Arch: "amd64"},
+			kinds: []string{"installer", "archive"}, expected: "go1.21.5.windows-amd64.msi"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			criteria := archiveForCrossTarget(SelectCriteria{OS: "windows", Arch: "amd64", Kinds: tc.kinds}, tc.host)
+
+			file, err := SelectFile(info, criteria)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if file.Filename != tc.expected {
+				t.Errorf("Unexpected file.\n Got: %s\nWant: %s", file.Filename, tc.expected)
+			}
+		})
+	}
+}
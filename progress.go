@@ -0,0 +1,226 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Progress display modes for DownloadOptions.Progress.
+const (
+	ProgressTerminal = "terminal" // A single line rewritten in place. The default.
+	ProgressJSONL    = "jsonl"    // One JSON object per line, throttled, for consumption by other programs.
+	ProgressNone     = "none"     // No progress display.
+)
+
+// jsonlProgressInterval is the minimum time between JSON Lines progress events.
+const jsonlProgressInterval = 250 * time.Millisecond
+
+// percent returns the percentage of Expected written, or 0 if Expected is unknown.
+// It is clamped at 100 if more than Expected was written.
+func (tw *ProgressHashWriter) percent() float64 {
+	if tw.Expected <= 0 {
+		return 0
+	}
+
+	if tw.Written >= tw.Expected {
+		return 100
+	}
+
+	return 100.0 * float64(tw.Written) / float64(tw.Expected)
+}
+
+// terminalProgressInterval is the minimum time between terminal progress updates.
+// Formatting and printing a line on every Write would dominate the CPU time of a fast
+// download made of small writes.
+const terminalProgressInterval = 200 * time.Millisecond
+
+// renderTerminal rewrites the progress line in place. It shows the first update, the update
+// that reaches Expected bytes, and at most one update per terminalProgressInterval otherwise.
+func renderTerminal(tw *ProgressHashWriter) {
+	now := tw.now()
+	done := tw.Expected > 0 && tw.Written >= tw.Expected
+
+	if !tw.lastRender.IsZero() && now.Sub(tw.lastRender) < terminalProgressInterval && (!done || tw.shownDone) {
+		tw.stale = true
+		return
+	}
+
+	tw.lastRender = now
+	tw.shownDone = tw.shownDone || done
+	tw.stale = false
+
+	// Widen the count if more than Expected was written, so the line stays aligned.
+	width := tw.expectedLen
+	if tw.Written > tw.Expected {
+		width = len(strconv.FormatInt(tw.Written, 10))
+	}
+
+	line := fmt.Sprintf("%3.0f%% (%*d of %d) complete",
+		tw.percent(),
+		width, tw.Written,
+		tw.Expected)
+
+	// The rate is not known until some time has passed since the first write.
+	if rate := tw.Rate(); rate > 0 {
+		line += fmt.Sprintf(", %s/s", formatBytes(int64(rate)))
+		if eta := tw.ETA(); eta > 0 {
+			line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+		}
+	}
+
+	// Pad with spaces to overwrite any residual characters of a longer previous line.
+	pad := tw.lastLen - len(line)
+	if pad < 0 {
+		pad = 0
+	}
+
+	fmt.Fprintf(tw.out, "\r%s%*s", line, pad, "")
+	tw.lastLen = len(line)
+}
+
+// renderNone displays nothing.
+func renderNone(*ProgressHashWriter) {}
+
+// ProgressEvent is a single JSON Lines progress update.
+type ProgressEvent struct {
+	Written  int64   `json:"written"`  // Bytes written so far.
+	Expected int64   `json:"expected"` // Total bytes expected.
+	Percent  float64 `json:"percent"`  // Percentage complete, or 0 if Expected is unknown.
+	Rate     float64 `json:"rate"`     // Average bytes per second.
+}
+
+// newJSONLRenderer returns a renderer that writes a ProgressEvent line to w at most once
+// per interval, and always once the expected bytes have been written.
+func newJSONLRenderer(w io.Writer, interval time.Duration) func(*ProgressHashWriter) {
+	var last time.Time
+	var done bool
+
+	enc := json.NewEncoder(w)
+
+	return func(tw *ProgressHashWriter) {
+		now := tw.now()
+		complete := tw.Expected > 0 && tw.Written >= tw.Expected
+
+		if done || (!complete && !last.IsZero() && now.Sub(last) < interval) {
+			return
+		}
+
+		last = now
+		done = complete
+
+		enc.Encode(ProgressEvent{
+			Written:  tw.Written,
+			Expected: tw.Expected,
+			Percent:  tw.percent(),
+			Rate:     tw.Rate(),
+		})
+	}
+}
+
+// progressMilestone is the percentage between progress log lines when no interval is set.
+const progressMilestone = 10
+
+// newIntervalLogRenderer returns a renderer that logs a single progress line to l each time
+// interval has passed since the previous line, coalescing the writes in between, and once
+// the expected bytes have been written.
+func newIntervalLogRenderer(l *slog.Logger, interval time.Duration) func(*ProgressHashWriter) {
+	var last time.Time
+	var done bool
+
+	return func(tw *ProgressHashWriter) {
+		now := tw.now()
+		if last.IsZero() {
+			last = tw.start
+		}
+
+		complete := tw.Expected > 0 && tw.Written >= tw.Expected
+
+		if done || (!complete && now.Sub(last) < interval) {
+			return
+		}
+
+		last = now
+		done = complete
+
+		logProgress(l, tw)
+	}
+}
+
+// newMilestoneLogRenderer returns a renderer that logs a progress line to l each time
+// another progressMilestone percent of the expected bytes has been written.
+func newMilestoneLogRenderer(l *slog.Logger) func(*ProgressHashWriter) {
+	next := float64(progressMilestone)
+
+	return func(tw *ProgressHashWriter) {
+		percent := tw.percent()
+		if percent < next {
+			return
+		}
+
+		// Skip milestones passed by a single large write.
+		for next <= percent {
+			next += progressMilestone
+		}
+
+		logProgress(l, tw)
+	}
+}
+
+// logProgress logs the progress of tw as a single structured line.
+func logProgress(l *slog.Logger, tw *ProgressHashWriter) {
+	l.Info("download progress",
+		"percent", fmt.Sprintf("%.0f", tw.percent()),
+		"written", tw.Written,
+		"expected", tw.Expected,
+		"rate", fmt.Sprintf("%.0f", tw.Rate()))
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// setProgressMode configures how tw displays progress. In terminal mode, if tw.out is not a
+// terminal, progress is logged to stderr instead: once per interval if it is positive, and at
+// every progressMilestone percent otherwise.
+func (tw *ProgressHashWriter) setProgressMode(mode string, interval time.Duration, stderr io.Writer) error {
+	switch mode {
+	case "", ProgressTerminal:
+		tw.newRender = func() func(*ProgressHashWriter) { return renderTerminal }
+
+		if !isTerminal(tw.out) {
+			l := slog.New(slog.NewTextHandler(stderr, nil))
+
+			if interval > 0 {
+				tw.newRender = func() func(*ProgressHashWriter) { return newIntervalLogRenderer(l, interval) }
+			} else {
+				tw.newRender = func() func(*ProgressHashWriter) { return newMilestoneLogRenderer(l) }
+			}
+		}
+	case ProgressJSONL:
+		tw.newRender = func() func(*ProgressHashWriter) { return newJSONLRenderer(stderr, jsonlProgressInterval) }
+	case ProgressNone:
+		tw.newRender = func() func(*ProgressHashWriter) { return renderNone }
+	default:
+		return fmt.Errorf("unknown progress mode %q", mode)
+	}
+
+	tw.render = tw.newRender()
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLProgress(t *testing.T) {
+	clock := newFakeClock()
+
+	var out bytes.Buffer
+
+	w := NewProgressHashWriter(100, sha256.New())
+	w.now = clock.Now
+	w.start = clock.Now()
+	w.render = newJSONLRenderer(&out, 250*time.Millisecond)
+
+	// Ten writes 100ms apart produce events at 0ms, 300ms, 600ms, and 900ms when complete.
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			clock.Advance(100 * time.Millisecond)
+		}
+		w.Write(make([]byte, 10))
+	}
+
+	var events []ProgressEvent
+
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var event ProgressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("Unexpected events.\n Got: %d\nWant: %d\n%s", len(events), 4, out.String())
+	}
+
+	last := events[len(events)-1]
+	want := ProgressEvent{Written: 100, Expected: 100, Percent: 100, Rate: 100 / 0.9}
+	if last != want {
+		t.Errorf("Unexpected final event.\n Got: %+v\nWant: %+v", last, want)
+	}
+}
+
+func TestIntervalLogProgress(t *testing.T) {
+	clock := newFakeClock()
+
+	var out bytes.Buffer
+
+	w := NewProgressHashWriter(1000, sha256.New())
+	w.now = clock.Now
+	w.start = clock.Now()
+	w.render = newIntervalLogRenderer(slog.New(slog.NewTextHandler(&out, nil)), 10*time.Second)
+
+	// 100 writes one second apart span 99 seconds, giving lines at 10s, 20s, ..., 90s, and one when complete.
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			clock.Advance(time.Second)
+		}
+		w.Write(make([]byte, 10))
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("Unexpected lines.\n Got: %d\nWant: %d\n%s", len(lines), 10, out.String())
+	}
+
+	if !strings.Contains(lines[len(lines)-1], "percent=100 written=1000 expected=1000") {
+		t.Errorf("Unexpected final line %q", lines[len(lines)-1])
+	}
+}
+
+func TestMilestoneLogProgress(t *testing.T) {
+	var out bytes.Buffer
+
+	w := NewProgressHashWriter(100, sha256.New())
+	w.render = newMilestoneLogRenderer(slog.New(slog.NewTextHandler(&out, nil)))
+
+	// Single-byte writes log at every 10%, and a large write logs once for all milestones it passes.
+	for i := 0; i < 50; i++ {
+		w.Write([]byte{0})
+	}
+	w.Write(make([]byte, 50))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Errorf("Unexpected lines.\n Got: %d\nWant: %d\n%s", len(lines), 6, out.String())
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidProxy = errors.New("invalid proxy")
+
+// proxyFunc is the type of http.Transport.Proxy.
+type proxyFunc func(*http.Request) (*url.URL, error)
+
+// newProxyFunc returns the proxy selection of the shared transport.
+//
+// If proxy is empty, the proxy is taken from the environment by http.ProxyFromEnvironment,
+// which honors HTTPS_PROXY, HTTP_PROXY, and NO_PROXY. Otherwise all requests go through proxy,
+// overriding the environment. Either way, hosts matching noProxy, a list in the format of
+// NO_PROXY, are requested directly. If set, trace is called with the URL of each request and
+// the proxy selected for it, or nil for a direct request.
+func newProxyFunc(proxy, noProxy string, trace func(*url.URL, *url.URL)) (proxyFunc, error) {
+	selectProxy := proxyFunc(http.ProxyFromEnvironment)
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxy)
+		}
+		selectProxy = http.ProxyURL(u)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		var u *url.URL
+		var err error
+		if !matchNoProxy(req.URL, noProxy) {
+			u, err = selectProxy(req)
+		}
+
+		if trace != nil && err == nil {
+			trace(req.URL, u)
+		}
+
+		return u, err
+	}, nil
+}
+
+// matchNoProxy reports whether the host of u is in noProxy, a comma-separated list in the
+// format of NO_PROXY. An entry is "*" for all hosts, an IP address or CIDR block, or a domain
+// name that also matches its subdomains, or only its subdomains with a leading ".". An entry
+// with a port only matches that port.
+func matchNoProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		}
+
+		if _, block, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && block.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		entry = strings.Trim(entry, "[]")
+
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		if strings.HasPrefix(entry, ".") {
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+			continue
+		}
+
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logProxy logs the proxy selected for a request, see newProxyFunc.
+func logProxy(req, proxy *url.URL) {
+	if proxy == nil {
+		logger.Info("proxy", "url", req.Redacted(), "proxy", "direct")
+		return
+	}
+
+	logger.Info("proxy", "url", req.Redacted(), "proxy", proxy.Redacted())
+}
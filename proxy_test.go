@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestMatchNoProxy(t *testing.T) {
+	const noProxy = "mirror.internal, .corp.example, 10.0.0.0/8, cache.example:8443, 192.168.1.5"
+
+	testCases := []struct {
+		url  string
+		want bool
+	}{
+		{"https://mirror.internal/go1.21.5.linux-amd64.tar.gz", true},
+		{"https://eu.mirror.internal/go1.21.5.linux-amd64.tar.gz", true},
+		{"https://MIRROR.internal:8443/dl/", true},
+		{"https://build.corp.example/dl/", true},
+		{"https://corp.example/dl/", false},
+		{"http://10.1.2.3/dl/", true},
+		{"http://192.168.1.5/dl/", true},
+		{"http://192.168.1.6/dl/", false},
+		{"https://cache.example:8443/dl/", true},
+		{"https://cache.example/dl/", false},
+		{"https://go.dev/dl/", false},
+		{"https://notmirror.internal/dl/", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := matchNoProxy(u, noProxy); got != tc.want {
+				t.Errorf("Unexpected match.\n Got: %v\nWant: %v", got, tc.want)
+			}
+		})
+	}
+
+	u, _ := url.Parse("https://go.dev/dl/")
+	if !matchNoProxy(u, "*") {
+		t.Errorf("Expected * to match %s", u)
+	}
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	type selection struct {
+		url, proxy string
+	}
+	var traced []selection
+	trace := func(req, proxy *url.URL) {
+		s := selection{url: req.Host}
+		if proxy != nil {
+			s.proxy = proxy.Host
+		}
+		traced = append(traced, s)
+	}
+
+	proxyFor, err := newProxyFunc("http://proxy.example:3128", "mirror.internal", trace)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		url  string
+		want string // Proxy host, or empty for a direct request.
+	}{
+		{"https://mirror.internal/go1.21.5.linux-amd64.tar.gz", ""},
+		{"https://go.dev/dl/?mode=json", "proxy.example:3128"},
+	}
+
+	for _, tc := range testCases {
+		req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := proxyFor(req)
+		got := ""
+		if u != nil {
+			got = u.Host
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("Unexpected proxy for %s.\n Got: %q, %v\nWant: %q", tc.url, got, err, tc.want)
+		}
+	}
+
+	want := []selection{{"mirror.internal", ""}, {"go.dev", "proxy.example:3128"}}
+	if len(traced) != len(want) || traced[0] != want[0] || traced[1] != want[1] {
+		t.Errorf("Unexpected trace.\n Got: %v\nWant: %v", traced, want)
+	}
+
+	_, err = newProxyFunc("not a proxy", "", nil)
+	if !errors.Is(err, ErrInvalidProxy) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidProxy)
+	}
+}
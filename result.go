@@ -0,0 +1,81 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"runtime"
+)
+
+// version is the version of this tool, set at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+var version = "devel"
+
+// ResultSchemaVersion is the schema version of Result.
+//
+// Adding a field is not a breaking change and does not bump the schema version, so consumers
+// should ignore fields they do not know. Removing or renaming a field, or changing its type or
+// meaning, bumps the schema version.
+const ResultSchemaVersion = 1
+
+// Result is the outcome of a run, printed as a single JSON object in -json mode.
+//
+// The fields are:
+//
+//	schema_version    ResultSchemaVersion of this object
+//	tool_version      version of go-latest-version, or "devel"
+//	current_version   version of the running Go, e.g. go1.21.4
+//	latest_version    version selected from the feed, omitted if the feed could not be read
+//	update_available  true if latest_version differs from current_version
+//	file              release file selected from the feed, omitted if none was selected
+//	downloaded_file   path of the downloaded file, omitted if nothing was downloaded
+//	timings           time spent in each phase of the run, only with -timing, see Timings
+//	error             reason the run failed, omitted on success
+type Result struct {
+	SchemaVersion   int          `json:"schema_version"`
+	ToolVersion     string       `json:"tool_version"`
+	CurrentVersion  string       `json:"current_version"`
+	LatestVersion   string       `json:"latest_version,omitempty"`
+	UpdateAvailable bool         `json:"update_available"`
+	File            *ReleaseFile `json:"file,omitempty"`
+	DownloadedFile  string       `json:"downloaded_file,omitempty"`
+	Timings         *Timings     `json:"timings,omitempty"`
+	Error           string       `json:"error,omitempty"`
+}
+
+// newResult returns a Result for the running Go with the schema and tool versions set.
+func newResult() Result {
+	return Result{
+		SchemaVersion:  ResultSchemaVersion,
+		ToolVersion:    version,
+		CurrentVersion: runtime.Version(),
+	}
+}
+
+// writeSummaryFile writes r as JSON to path atomically, for -summary-json.
+func writeSummaryFile(path string, r Result) error {
+	var buf bytes.Buffer
+
+	_, err := r.WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// WriteTo writes r to w as a single line of JSON.
+func (r Result) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(append(data, '\n'))
+
+	return int64(n), err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResultWriteTo(t *testing.T) {
+	file := testReleaseInfo[1].Files[1]
+
+	want := newResult()
+	want.LatestVersion = file.Version
+	want.UpdateAvailable = true
+	want.File = &file
+	want.DownloadedFile = file.Filename
+
+	var buf bytes.Buffer
+
+	_, err := want.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if fields["schema_version"] != float64(ResultSchemaVersion) {
+		t.Errorf("Unexpected schema_version.\n Got: %v\nWant: %v", fields["schema_version"], ResultSchemaVersion)
+	}
+
+	if fields["tool_version"] != version {
+		t.Errorf("Unexpected tool_version.\n Got: %v\nWant: %v", fields["tool_version"], version)
+	}
+
+	var got Result
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected round trip.\n Got: %+v\nWant: %+v", got, want)
+	}
+}
+
+func TestWriteSummaryFile(t *testing.T) {
+	file := testReleaseInfo[1].Files[1]
+
+	want := newResult()
+	want.LatestVersion = file.Version
+	want.File = &file
+
+	// Capture stdout, where the human output stays.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	t.Cleanup(func() { os.Stdout = stdout })
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "summary.json")
+
+	fmt.Printf("Latest  %s on %s/%s\n", file.Version, file.OS, file.Arch)
+	err = writeSummaryFile(path, want)
+
+	w.Close()
+	os.Stdout = stdout
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	human, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHuman := fmt.Sprintf("Latest  %s on %s/%s\n", file.Version, file.OS, file.Arch)
+	if string(human) != wantHuman {
+		t.Errorf("Unexpected stdout.\n Got: %q\nWant: %q", human, wantHuman)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Result
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Invalid JSON in summary: %v\n%s", err, data)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected summary.\n Got: %+v\nWant: %+v", got, want)
+	}
+
+	// Only the summary is left, not a temporary file.
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Unexpected files: %v", entries)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// stateSuffix is appended to the partial file of a resumable download to name its state file.
+const stateSuffix = ".state"
+
+// resumeState is stored next to the partial file of a resumable download before its first byte
+// is written, so a later attempt can check that it continues the same download, even if the
+// process was killed. The bytes are written to the partial file in order, so its size is how
+// far the download got. The hash state cannot be saved, so the kept bytes are hashed again on
+// resume, and a damaged partial file is caught by the checksum verification.
+type resumeState struct {
+	URL          string `json:"url"`
+	ExpectedSize int64  `json:"expected_size"`
+	Checksum     string `json:"checksum,omitempty"` // Expected hex SHA256, if known.
+}
+
+// writeResumeState saves st to path.
+func writeResumeState(path string, st resumeState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// resumeOffset returns how many bytes of the partial file f can be kept when continuing st,
+// which is all of them. It is zero, restarting the download, if the state file is missing
+// or unreadable, or was saved for a different download.
+func resumeOffset(f *os.File, st resumeState) int64 {
+	data, err := os.ReadFile(f.Name() + stateSuffix)
+	if err != nil {
+		return 0
+	}
+
+	var saved resumeState
+	if json.Unmarshal(data, &saved) != nil || saved != st {
+		return 0
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+
+	return info.Size()
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	content, err := os.ReadFile("testdata/testfile_1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantChecksum = "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e"
+
+	size := int64(len(content))
+
+	honorRange := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "testfile", time.Time{}, bytes.NewReader(content))
+	}
+
+	testCases := []struct {
+		name      string
+		partial   []byte
+		state     *resumeState // URL is filled in with the server URL if empty.
+		handler   http.HandlerFunc
+		wantRange string
+	}{
+		{
+			name:      "Server honors range",
+			partial:   content[:1000],
+			state:     &resumeState{ExpectedSize: size},
+			handler:   honorRange,
+			wantRange: "bytes=1000-",
+		},
+		{
+			name:    "Server ignores range",
+			partial: []byte("stale bytes that do not match the file"),
+			state:   &resumeState{ExpectedSize: size},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(content)
+			},
+			wantRange: "bytes=38-",
+		},
+		{
+			name:    "No state file",
+			partial: content[:1000],
+			handler: honorRange,
+		},
+		{
+			name:    "State for another URL",
+			partial: []byte("bytes of another file"),
+			state:   &resumeState{URL: "https://go.dev/dl/other.tar.gz", ExpectedSize: size},
+			handler: honorRange,
+		},
+		{
+			name:    "State for another size",
+			partial: content[:1000],
+			state:   &resumeState{ExpectedSize: size + 1},
+			handler: honorRange,
+		},
+		{
+			name:    "State for another checksum",
+			partial: content[:1000],
+			state:   &resumeState{ExpectedSize: size, Checksum: wantChecksum},
+			handler: honorRange,
+		},
+		{
+			name:    "No partial file",
+			handler: honorRange,
+		},
+		{
+			name:    "Complete partial file",
+			partial: content,
+			state:   &resumeState{ExpectedSize: size},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "unexpected request", http.StatusRequestedRangeNotSatisfiable)
+			},
+		},
+		{
+			name:    "Partial file longer than the file",
+			partial: append(bytes.Clone(content), "extra"...),
+			state:   &resumeState{ExpectedSize: size},
+			handler: honorRange,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotRange string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange = r.Header.Get("Range")
+				tc.handler(w, r)
+			}))
+			defer server.Close()
+
+			filePath := filepath.Join(t.TempDir(), "testfile")
+			partPath := filePath + partSuffix
+
+			if tc.partial != nil {
+				if err := os.WriteFile(partPath, tc.partial, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tc.state != nil {
+				state := *tc.state
+				if state.URL == "" {
+					state.URL = server.URL
+				}
+				if err := writeResumeState(partPath+stateSuffix, state); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gotSize, checksum, err := DownloadFileWithProgressAndChecksum(server.URL, filePath,
+				size, sha256.New(), DownloadOptions{Resume: true, Progress: ProgressNone})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if gotRange != tc.wantRange {
+				t.Errorf("Unexpected Range header.\n Got: %q\nWant: %q", gotRange, tc.wantRange)
+			}
+
+			if gotSize != size {
+				t.Errorf("Unexpected size.\n Got: %d\nWant: %d", gotSize, size)
+			}
+
+			if checksum != wantChecksum {
+				t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", checksum, wantChecksum)
+			}
+
+			got, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, content) {
+				t.Error("Unexpected file content")
+			}
+
+			for _, path := range []string{partPath, partPath + stateSuffix} {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Expected %s to be gone, got %v", filepath.Base(path), err)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadResumeSavesState(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	// The server promises more bytes than it sends, so the download fails part way.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write(make([]byte, 10))
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+
+	_, _, err := DownloadFileWithProgressAndChecksum(server.URL, filePath, 100, sha256.New(),
+		DownloadOptions{Resume: true, Progress: ProgressNone})
+	if !errors.Is(err, ErrDownloadFailed) {
+		t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, ErrDownloadFailed)
+	}
+
+	data, err := os.ReadFile(filePath + partSuffix + stateSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got resumeState
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := resumeState{URL: server.URL, ExpectedSize: 100}
+	if got != want {
+		t.Errorf("Unexpected state.\n Got: %+v\nWant: %+v", got, want)
+	}
+}
+
+// TestResumeHelperProcess is not a real test. It is run as a subprocess by
+// TestDownloadResumeAfterKill to download a file until it is killed.
+func TestResumeHelperProcess(t *testing.T) {
+	url := os.Getenv("GO_LATEST_RESUME_URL")
+	if url == "" {
+		t.Skip("helper process")
+	}
+
+	allowInsecure = true
+
+	DownloadFileWithProgressAndChecksum(url, os.Getenv("GO_LATEST_RESUME_PATH"), 1024*1024, sha256.New(),
+		DownloadOptions{Resume: true, Progress: ProgressNone})
+}
+
+func TestDownloadResumeAfterKill(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	content, err := os.ReadFile("testdata/testfile_1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantChecksum = "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e"
+
+	half := len(content) / 2
+
+	// The first request sends half the file and then hangs until the client is killed.
+	hang := make(chan struct{})
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+
+		if first {
+			w.Header().Set("Content-Length", "1048576")
+			w.Write(content[:half])
+			w.(http.Flusher).Flush()
+			<-hang
+			return
+		}
+		http.ServeContent(w, r, "testfile", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+	partPath := filePath + partSuffix
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestResumeHelperProcess$")
+	cmd.Env = append(os.Environ(), "GO_LATEST_RESUME_URL="+server.URL, "GO_LATEST_RESUME_PATH="+filePath)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kill the download once the bytes sent so far are in the partial file.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if info, err := os.Stat(partPath); err == nil && info.Size() == int64(half) {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			cmd.Wait()
+			t.Fatal("timed out waiting for the partial file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if _, err := os.Stat(partPath + stateSuffix); err != nil {
+		t.Fatalf("Expected state file after kill: %v", err)
+	}
+
+	gotSize, checksum, err := DownloadFileWithProgressAndChecksum(server.URL, filePath, int64(len(content)),
+		sha256.New(), DownloadOptions{Resume: true, Progress: ProgressNone})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	wantRanges := []string{"", "bytes=524288-"}
+	if len(ranges) != len(wantRanges) || ranges[0] != wantRanges[0] || ranges[1] != wantRanges[1] {
+		t.Errorf("Unexpected Range headers.\n Got: %q\nWant: %q", ranges, wantRanges)
+	}
+
+	if gotSize != int64(len(content)) || checksum != wantChecksum {
+		t.Errorf("Unexpected download.\n Got: %d, %s\nWant: %d, %s", gotSize, checksum, len(content), wantChecksum)
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrNoMatchingFile = errors.New("no matching file found")
+	ErrAmbiguousMatch = errors.New("ambiguous match")
+	ErrNoAllowedKind  = errors.New("no allowed file kind matches")
+	ErrAllBlocked     = errors.New("all candidate versions are blocked")
+	ErrInvalidKind    = errors.New("invalid kind")
+)
+
+// fileKinds are the kinds of file in the feed.
+var fileKinds = []string{"archive", "installer", "source"}
+
+// checkKind returns ErrInvalidKind unless kind is one of fileKinds.
+func checkKind(kind string) error {
+	if !slices.Contains(fileKinds, kind) {
+		return fmt.Errorf("%w: %q, want one of %s", ErrInvalidKind, kind, strings.Join(fileKinds, ", "))
+	}
+
+	return nil
+}
+
+// SelectCriteria describes which release file to select.
+type SelectCriteria struct {
+	OS   string // Target operating system, e.g. "linux". Required.
+	Arch string // Target architecture, e.g. "amd64". Required.
+
+	// Kinds lists acceptable file kinds in order of preference, e.g. "installer", "archive".
+	// If empty, defaultKinds(OS) is used.
+	Kinds []string
+
+	// AllowedKinds, if not empty, restricts selection to files of these kinds, regardless of Kinds.
+	AllowedKinds []string
+
+	// Kind, if set, is the only kind of file selected, overriding Kinds and AllowedKinds.
+	// Source files are not built for a platform, so for "source" OS and Arch are ignored.
+	Kind string
+
+	// Extensions lists filename extensions in order of preference, e.g. ".tar.xz", ".tar.gz".
+	// It breaks ties between files of the winning kind, such as a mirror offering both
+	// gzip and xz archives. If empty, such ties are an error.
+	Extensions []string
+
+	// IncludeUnstable considers unstable (beta and rc) releases.
+	IncludeUnstable bool
+
+	// Version selects an exact release, e.g. "go1.21.5", regardless of stability.
+	Version string
+
+	// BlockedVersions lists exact versions, e.g. "go1.21.5", that are never selected,
+	// such as releases with a known regression. The next eligible release is chosen instead.
+	BlockedVersions []string
+
+	// MaxMinor, if set, is the newest minor line, e.g. "go1.21", that is selected. Releases of
+	// newer minor lines are skipped, so patches of the capped line are taken but never a new minor.
+	MaxMinor string
+
+	// Line, if set, is the only minor line, e.g. "go1.21", that releases are selected from.
+	Line string
+
+	// Compare orders versions, returning a negative number if a is older than b, a positive
+	// number if a is newer, and zero if they rank the same. If nil, CompareGoVersions is used.
+	// It only ranks releases that are already eligible, so it cannot select an unstable or
+	// blocked release, or one outside MaxMinor or Line.
+	Compare func(a, b string) int
+}
+
+// defaultKinds returns the kind preference for goos.
+// Installers are preferred over archives on windows and darwin.
+func defaultKinds(goos string) []string {
+	if goos == "windows" || goos == "darwin" {
+		return []string{"installer", "archive"}
+	}
+
+	return []string{"archive"}
+}
+
+// SelectFile returns the release file in info that best matches criteria.
+//
+// The rules are applied in this order:
+//
+//  1. If criteria.Version is set, only the release with that exact version is eligible,
+//     stable or not. Otherwise, unstable releases are skipped unless criteria.IncludeUnstable is set.
+//  2. Releases in criteria.BlockedVersions, of minor lines newer than criteria.MaxMinor, and of
+//     minor lines other than criteria.Line, are skipped.
+//     The newest eligible release by criteria.Compare is chosen, and of releases that rank the
+//     same, the first in feed order. Older releases are never considered, even if the chosen
+//     release has no file for the target.
+//  3. Within the chosen release, only files for criteria.OS and criteria.Arch are considered,
+//     or all source files if criteria.Kind is "source".
+//  4. Files whose kind is not criteria.Kind or in criteria.AllowedKinds, when set, are discarded.
+//  5. The first kind in criteria.Kinds with a remaining file wins.
+//  6. If several files have the winning kind, the one with the first extension in
+//     criteria.Extensions wins.
+//
+// It returns ErrNoReleases if info is empty, ErrVersionNotFound if criteria.Version is not in info,
+// ErrAllBlocked if every eligible release is blocked,
+// ErrNoAllowedKind if files for the target exist but none are an allowed kind,
+// ErrNoMatchingFile if no file matches, and ErrAmbiguousMatch if more than one file matches
+// the winning kind and criteria.Extensions does not pick one.
+func SelectFile(info ReleaseInfo, criteria SelectCriteria) (ReleaseFile, error) {
+	kinds := criteria.Kinds
+	if len(kinds) == 0 {
+		kinds = defaultKinds(criteria.OS)
+	}
+
+	allowed := criteria.AllowedKinds
+	if criteria.Kind != "" {
+		kinds = []string{criteria.Kind}
+		allowed = []string{criteria.Kind}
+	}
+
+	release, err := selectRelease(info, criteria)
+	if err != nil {
+		return ReleaseFile{}, err
+	}
+
+	var candidates []ReleaseFile
+	var disallowed int
+
+	for _, file := range release.Files {
+		if criteria.Kind != "source" && (file.OS != criteria.OS || file.Arch != criteria.Arch) {
+			continue
+		}
+
+		if len(allowed) > 0 && !slices.Contains(allowed, file.Kind) {
+			disallowed++
+			continue
+		}
+
+		candidates = append(candidates, file)
+	}
+
+	if len(candidates) == 0 && disallowed > 0 {
+		return ReleaseFile{}, fmt.Errorf("%w for OS: %s, Arch: %s, Version: %s, allowed: %v",
+			ErrNoAllowedKind, criteria.OS, criteria.Arch, release.Version, allowed)
+	}
+
+	for _, kind := range kinds {
+		var matches []ReleaseFile
+
+		for _, file := range candidates {
+			if file.Kind == kind {
+				matches = append(matches, file)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			if file, ok := preferExtension(matches, criteria.Extensions); ok {
+				return file, nil
+			}
+
+			return ReleaseFile{}, fmt.Errorf("%w: %d %s files for %s/%s in %s",
+				ErrAmbiguousMatch, len(matches), kind, criteria.OS, criteria.Arch, release.Version)
+		}
+	}
+
+	return ReleaseFile{}, fmt.Errorf("%w for OS: %s, Arch: %s, Version: %s",
+		ErrNoMatchingFile, criteria.OS, criteria.Arch, release.Version)
+}
+
+// selectRelease returns the release eligible under criteria, as described by SelectFile.
+func selectRelease(info ReleaseInfo, criteria SelectCriteria) (Release, error) {
+	if len(info) == 0 {
+		return Release{}, ErrNoReleases
+	}
+
+	if criteria.Version != "" {
+		if slices.Contains(criteria.BlockedVersions, criteria.Version) {
+			return Release{}, fmt.Errorf("%w: %s", ErrAllBlocked, criteria.Version)
+		}
+		return findRelease(info, criteria.Version)
+	}
+
+	var maxMinor goVersion
+	if criteria.MaxMinor != "" {
+		var err error
+		maxMinor, err = parseMinorLine(criteria.MaxMinor)
+		if err != nil {
+			return Release{}, err
+		}
+	}
+
+	compare := criteria.Compare
+	if compare == nil {
+		compare = CompareGoVersions
+	}
+
+	var (
+		chosen  Release
+		found   bool
+		blocked []string
+	)
+	for _, release := range info {
+		if !release.Stable && !criteria.IncludeUnstable {
+			continue
+		}
+
+		if criteria.MaxMinor != "" {
+			if _, ok := parseGoVersion(release.Version); !ok || exceedsMinorLine(release.Version, maxMinor) {
+				continue
+			}
+		}
+
+		if criteria.Line != "" {
+			if v, ok := parseGoVersion(release.Version); !ok || v.MinorLine() != criteria.Line {
+				continue
+			}
+		}
+
+		if slices.Contains(criteria.BlockedVersions, release.Version) {
+			blocked = append(blocked, release.Version)
+			continue
+		}
+
+		if !found || compare(release.Version, chosen.Version) > 0 {
+			chosen, found = release, true
+		}
+	}
+
+	if found {
+		return chosen, nil
+	}
+
+	if len(blocked) > 0 {
+		return Release{}, fmt.Errorf("%w: %s", ErrAllBlocked, strings.Join(blocked, ", "))
+	}
+
+	return Release{}, fmt.Errorf("%w: no eligible release", ErrNoMatchingFile)
+}
+
+// newestReleaseWith returns the newest release in info, by criteria.Compare, that SelectFile
+// would select a file from if it were the only release, ignoring criteria.Version.
+// Use it when the latest release has no file for the target, such as a new GOARCH that is
+// not built yet, to suggest a release to pin to. It returns ErrNoMatchingFile if there is none.
+func newestReleaseWith(info ReleaseInfo, criteria SelectCriteria) (Release, error) {
+	compare := criteria.Compare
+	if compare == nil {
+		compare = CompareGoVersions
+	}
+	criteria.Version = ""
+
+	var (
+		newest Release
+		found  bool
+	)
+	for _, release := range info {
+		if _, err := SelectFile(ReleaseInfo{release}, criteria); err != nil {
+			continue
+		}
+
+		if !found || compare(release.Version, newest.Version) > 0 {
+			newest, found = release, true
+		}
+	}
+
+	if !found {
+		return Release{}, fmt.Errorf("%w: no release has a file for %s/%s", ErrNoMatchingFile, criteria.OS, criteria.Arch)
+	}
+
+	return newest, nil
+}
+
+// preferExtension returns the only file in files with the earliest extension in exts.
+// It reports false if no file has a listed extension or several share the earliest one.
+func preferExtension(files []ReleaseFile, exts []string) (ReleaseFile, bool) {
+	for _, ext := range exts {
+		var found []ReleaseFile
+
+		for _, file := range files {
+			if strings.HasSuffix(file.Filename, ext) {
+				found = append(found, file)
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], true
+		default:
+			return ReleaseFile{}, false
+		}
+	}
+
+	return ReleaseFile{}, false
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// testReleaseInfo is a small feed, newest first, used by selection tests.
+var testReleaseInfo = ReleaseInfo{
+	{
+		Version: "go1.22rc1",
+		Stable:  false,
+		Files: []ReleaseFile{
+			{Filename: "go1.22rc1.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.22rc1", Kind: "archive"},
+		},
+	},
+	{
+		Version: "go1.21.5",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{Filename: "go1.21.5.src.tar.gz", Version: "go1.21.5", Kind: "source"},
+			{Filename: "go1.21.5.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.darwin-arm64.tar.gz", OS: "darwin", Arch: "arm64", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.darwin-arm64.pkg", OS: "darwin", Arch: "arm64", Version: "go1.21.5", Kind: "installer"},
+			{Filename: "go1.21.5.windows-amd64.zip", OS: "windows", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.windows-amd64.msi", OS: "windows", Arch: "amd64", Version: "go1.21.5", Kind: "installer"},
+			{Filename: "go1.21.5.plan9-arm.tar.gz", OS: "plan9", Arch: "arm", Version: "go1.21.5", Kind: "archive"},
+			{Filename: "go1.21.5.plan9-arm.tgz", OS: "plan9", Arch: "arm", Version: "go1.21.5", Kind: "archive"},
+		},
+	},
+	{
+		Version: "go1.20.12",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{Filename: "go1.20.12.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.20.12", Kind: "archive"},
+			{Filename: "go1.20.12.linux-s390x.tar.gz", OS: "linux", Arch: "s390x", Version: "go1.20.12", Kind: "archive"},
+		},
+	},
+}
+
+func TestSelectFile(t *testing.T) {
+	testCases := []struct {
+		name             string
+		criteria         SelectCriteria
+		expectedFilename string
+		expectedError    error
+	}{
+		{
+			name:             "Linux default kind",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64"},
+			expectedFilename: "go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Darwin prefers installer",
+			criteria:         SelectCriteria{OS: "darwin", Arch: "arm64"},
+			expectedFilename: "go1.21.5.darwin-arm64.pkg",
+		},
+		{
+			name:             "Windows prefers installer",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64"},
+			expectedFilename: "go1.21.5.windows-amd64.msi",
+		},
+		{
+			name:             "Explicit archive preference",
+			criteria:         SelectCriteria{OS: "darwin", Arch: "arm64", Kinds: []string{"archive", "installer"}},
+			expectedFilename: "go1.21.5.darwin-arm64.tar.gz",
+		},
+		{
+			name:             "Preference falls through to available kind",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Kinds: []string{"installer", "archive"}},
+			expectedFilename: "go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Include unstable",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", IncludeUnstable: true},
+			expectedFilename: "go1.22rc1.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Exact version",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Version: "go1.20.12"},
+			expectedFilename: "go1.20.12.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Exact unstable version without include unstable",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Version: "go1.22rc1"},
+			expectedFilename: "go1.22rc1.linux-amd64.tar.gz",
+		},
+		{
+			name:          "Unknown version",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", Version: "go1.19"},
+			expectedError: ErrVersionNotFound,
+		},
+		{
+			name:             "Newest blocked",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", BlockedVersions: []string{"go1.21.5"}},
+			expectedFilename: "go1.20.12.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Newest unstable blocked",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", IncludeUnstable: true, BlockedVersions: []string{"go1.22rc1"}},
+			expectedFilename: "go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:          "All blocked",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", BlockedVersions: []string{"go1.21.5", "go1.20.12"}},
+			expectedError: ErrAllBlocked,
+		},
+		{
+			name:          "Exact version blocked",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", Version: "go1.21.5", BlockedVersions: []string{"go1.21.5"}},
+			expectedError: ErrAllBlocked,
+		},
+		{
+			name:          "No match in newest release",
+			criteria:      SelectCriteria{OS: "linux", Arch: "s390x"},
+			expectedError: ErrNoMatchingFile,
+		},
+		{
+			name:          "No match for kind",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", Kinds: []string{"installer"}},
+			expectedError: ErrNoMatchingFile,
+		},
+		{
+			name:             "Allowed kinds forces archive",
+			criteria:         SelectCriteria{OS: "darwin", Arch: "arm64", AllowedKinds: []string{"archive"}},
+			expectedFilename: "go1.21.5.darwin-arm64.tar.gz",
+		},
+		{
+			name:             "Allowed kinds permits preferred kind",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64", AllowedKinds: []string{"archive", "installer"}},
+			expectedFilename: "go1.21.5.windows-amd64.msi",
+		},
+		{
+			name:          "Allowed kinds blocks all",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", AllowedKinds: []string{"installer"}},
+			expectedError: ErrNoAllowedKind,
+		},
+		{
+			name:          "Ambiguous match",
+			criteria:      SelectCriteria{OS: "plan9", Arch: "arm"},
+			expectedError: ErrAmbiguousMatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := SelectFile(testReleaseInfo, tc.criteria)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Filename != tc.expectedFilename {
+				t.Errorf("Unexpected file.\n Got: %q\nWant: %q", file.Filename, tc.expectedFilename)
+			}
+		})
+	}
+}
+
+func TestSelectFileMaxMinor(t *testing.T) {
+	var info ReleaseInfo
+	for _, v := range []string{"go1.22.3", "go1.22.2", "go1.21.9", "go1.21.8", "go1.20.14"} {
+		info = append(info, Release{
+			Version: v,
+			Stable:  true,
+			Files:   []ReleaseFile{{Filename: v + ".linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: v, Kind: "archive"}},
+		})
+	}
+
+	testCases := []struct {
+		name            string
+		maxMinor        string
+		blocked         []string
+		expectedVersion string
+		expectedError   error
+	}{
+		{name: "No cap", expectedVersion: "go1.22.3"},
+		{name: "Cap at newest", maxMinor: "go1.22", expectedVersion: "go1.22.3"},
+		{name: "Cap below newest", maxMinor: "go1.21", expectedVersion: "go1.21.9"},
+		{name: "Cap without prefix", maxMinor: "1.20", expectedVersion: "go1.20.14"},
+		{name: "Cap with blocked patch", maxMinor: "go1.21", blocked: []string{"go1.21.9"}, expectedVersion: "go1.21.8"},
+		{name: "Cap above newest", maxMinor: "go1.30", expectedVersion: "go1.22.3"},
+		{name: "Cap below oldest", maxMinor: "go1.19", expectedError: ErrNoMatchingFile},
+		{name: "Invalid cap", maxMinor: "go1.21.5", expectedError: ErrInvalidMinorLine},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			criteria := SelectCriteria{OS: "linux", Arch: "amd64", MaxMinor: tc.maxMinor, BlockedVersions: tc.blocked}
+
+			file, err := SelectFile(info, criteria)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Version != tc.expectedVersion {
+				t.Errorf("Unexpected version.\n Got: %q\nWant: %q", file.Version, tc.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSelectFileCompare(t *testing.T) {
+	var info ReleaseInfo
+	for _, v := range []string{"go1.21.9", "go1.22.3", "go1.20.14", "go1.23rc1"} {
+		info = append(info, Release{
+			Version: v,
+			Stable:  v != "go1.23rc1",
+			Files:   []ReleaseFile{{Filename: v + ".linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: v, Kind: "archive"}},
+		})
+	}
+
+	reverse := func(a, b string) int { return CompareGoVersions(b, a) }
+
+	testCases := []struct {
+		name            string
+		compare         func(a, b string) int
+		blocked         []string
+		expectedVersion string
+	}{
+		{name: "Default, newest stable regardless of feed order", expectedVersion: "go1.22.3"},
+		{name: "Reversed", compare: reverse, expectedVersion: "go1.20.14"},
+		{name: "Reversed with oldest blocked", compare: reverse, blocked: []string{"go1.20.14"}, expectedVersion: "go1.21.9"},
+		{name: "Ties keep feed order", compare: func(a, b string) int { return 0 }, expectedVersion: "go1.21.9"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			criteria := SelectCriteria{OS: "linux", Arch: "amd64", Compare: tc.compare, BlockedVersions: tc.blocked}
+
+			file, err := SelectFile(info, criteria)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if file.Version != tc.expectedVersion {
+				t.Errorf("Unexpected version.\n Got: %q\nWant: %q", file.Version, tc.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSelectFileKind(t *testing.T) {
+	info := ReleaseInfo{{
+		Version: "go1.22.3",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{Filename: "go1.22.3.src.tar.gz", Version: "go1.22.3", Kind: "source"},
+			{Filename: "go1.22.3.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.22.3", Kind: "archive"},
+			{Filename: "go1.22.3.windows-amd64.zip", OS: "windows", Arch: "amd64", Version: "go1.22.3", Kind: "archive"},
+			{Filename: "go1.22.3.windows-amd64.msi", OS: "windows", Arch: "amd64", Version: "go1.22.3", Kind: "installer"},
+		},
+	}}
+
+	testCases := []struct {
+		name             string
+		criteria         SelectCriteria
+		expectedFilename string
+		expectedError    error
+	}{
+		{
+			name:             "Archive on windows",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64", Kind: "archive"},
+			expectedFilename: "go1.22.3.windows-amd64.zip",
+		},
+		{
+			name:             "Installer on windows",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64", Kind: "installer"},
+			expectedFilename: "go1.22.3.windows-amd64.msi",
+		},
+		{
+			name:             "Overrides preference",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64", Kinds: []string{"installer", "archive"}, Kind: "archive"},
+			expectedFilename: "go1.22.3.windows-amd64.zip",
+		},
+		{
+			name:             "Overrides allowed kinds",
+			criteria:         SelectCriteria{OS: "windows", Arch: "amd64", AllowedKinds: []string{"archive"}, Kind: "installer"},
+			expectedFilename: "go1.22.3.windows-amd64.msi",
+		},
+		{
+			name:             "Source on linux",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Kind: "source"},
+			expectedFilename: "go1.22.3.src.tar.gz",
+		},
+		{
+			name:             "Source ignores platform",
+			criteria:         SelectCriteria{OS: "plan9", Arch: "386", Kind: "source"},
+			expectedFilename: "go1.22.3.src.tar.gz",
+		},
+		{
+			name:          "No installer on linux",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", Kind: "installer"},
+			expectedError: ErrNoAllowedKind,
+		},
+		{
+			name:          "No archive for platform",
+			criteria:      SelectCriteria{OS: "plan9", Arch: "386", Kind: "archive"},
+			expectedError: ErrNoMatchingFile,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := SelectFile(info, tc.criteria)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Filename != tc.expectedFilename {
+				t.Errorf("Unexpected file.\n Got: %q\nWant: %q", file.Filename, tc.expectedFilename)
+			}
+		})
+	}
+}
+
+func TestCheckKind(t *testing.T) {
+	for _, kind := range fileKinds {
+		if err := checkKind(kind); err != nil {
+			t.Errorf("Unexpected error for %q: %v", kind, err)
+		}
+	}
+
+	if err := checkKind("binary"); !errors.Is(err, ErrInvalidKind) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrInvalidKind)
+	}
+}
+
+func TestNewestReleaseWith(t *testing.T) {
+	release := func(version string, stable bool, arches ...string) Release {
+		r := Release{Version: version, Stable: stable}
+		for _, arch := range arches {
+			r.Files = append(r.Files, ReleaseFile{
+				Filename: version + ".linux-" + arch + ".tar.gz", OS: "linux", Arch: arch, Version: version, Kind: "archive",
+			})
+		}
+		return r
+	}
+
+	info := ReleaseInfo{
+		release("go1.23rc1", false, "amd64", "loong64"),
+		release("go1.22.3", true, "amd64"),
+		release("go1.21.9", true, "amd64", "loong64"),
+		release("go1.21.10", true, "amd64", "loong64"),
+		release("go1.20.14", true, "amd64", "loong64"),
+	}
+
+	criteria := SelectCriteria{OS: "linux", Arch: "loong64"}
+
+	_, err := SelectFile(info, criteria)
+	if !errors.Is(err, ErrNoMatchingFile) {
+		t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, ErrNoMatchingFile)
+	}
+
+	testCases := []struct {
+		name            string
+		criteria        SelectCriteria
+		expectedVersion string
+		expectedError   error
+	}{
+		{name: "Newest stable", criteria: criteria, expectedVersion: "go1.21.10"},
+		{name: "Ignores version", criteria: SelectCriteria{OS: "linux", Arch: "loong64", Version: "go1.22.3"}, expectedVersion: "go1.21.10"},
+		{name: "Unstable", criteria: SelectCriteria{OS: "linux", Arch: "loong64", IncludeUnstable: true}, expectedVersion: "go1.23rc1"},
+		{name: "Blocked", criteria: SelectCriteria{OS: "linux", Arch: "loong64", BlockedVersions: []string{"go1.21.10"}}, expectedVersion: "go1.21.9"},
+		{name: "No release", criteria: SelectCriteria{OS: "linux", Arch: "riscv64"}, expectedError: ErrNoMatchingFile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newestReleaseWith(info, tc.criteria)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got.Version != tc.expectedVersion {
+				t.Errorf("Unexpected version.\n Got: %q\nWant: %q", got.Version, tc.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSelectFileNoReleases(t *testing.T) {
+	_, err := SelectFile(ReleaseInfo{}, SelectCriteria{OS: "linux", Arch: "amd64"})
+	if !errors.Is(err, ErrNoReleases) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrNoReleases)
+	}
+}
+
+func TestSelectFileExtensions(t *testing.T) {
+	mirror := ReleaseInfo{
+		{
+			Version: "go1.21.5",
+			Stable:  true,
+			Files: []ReleaseFile{
+				{Filename: "go1.21.5.linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+				{Filename: "go1.21.5.linux-amd64.tar.xz", OS: "linux", Arch: "amd64", Version: "go1.21.5", Kind: "archive"},
+				{Filename: "go1.21.5.linux-arm64.tar.gz", OS: "linux", Arch: "arm64", Version: "go1.21.5", Kind: "archive"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		criteria         SelectCriteria
+		expectedFilename string
+		expectedError    error
+	}{
+		{
+			name:             "Prefer xz",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Extensions: []string{".tar.xz", ".tar.gz"}},
+			expectedFilename: "go1.21.5.linux-amd64.tar.xz",
+		},
+		{
+			name:             "Prefer gzip",
+			criteria:         SelectCriteria{OS: "linux", Arch: "amd64", Extensions: []string{".tar.gz", ".tar.xz"}},
+			expectedFilename: "go1.21.5.linux-amd64.tar.gz",
+		},
+		{
+			name:             "Fall back to gzip",
+			criteria:         SelectCriteria{OS: "linux", Arch: "arm64", Extensions: []string{".tar.xz", ".tar.gz"}},
+			expectedFilename: "go1.21.5.linux-arm64.tar.gz",
+		},
+		{
+			name:          "No preference",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64"},
+			expectedError: ErrAmbiguousMatch,
+		},
+		{
+			name:          "No listed extension",
+			criteria:      SelectCriteria{OS: "linux", Arch: "amd64", Extensions: []string{".zip"}},
+			expectedError: ErrAmbiguousMatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := SelectFile(mirror, tc.criteria)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if file.Filename != tc.expectedFilename {
+				t.Errorf("Unexpected file.\n Got: %q\nWant: %q", file.Filename, tc.expectedFilename)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultServeTTL is how long -serve reuses a check before fetching the feed again.
+const DefaultServeTTL = 5 * time.Minute
+
+// checkServer answers HTTP requests with the result of checking the feed, so several
+// consumers can share one instance. It serves:
+//
+//	GET /latest   the Result of the check as JSON, with status 502 if the check failed
+//	GET /healthz  200 and "ok" while the server is running
+//
+// A result is reused for ttl. Concurrent requests for an expired result wait for a single
+// check, which refreshes the feed with a conditional request.
+type checkServer struct {
+	feed     *conditionalFeed
+	criteria SelectCriteria
+	current  string // Version to compare against, normally runtime.Version().
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex // Guards feed and the cached result.
+	result  Result
+	expires time.Time
+}
+
+// newCheckServer returns a checkServer for the feed at feedURL.
+func newCheckServer(feedURL string, criteria SelectCriteria, current string, ttl time.Duration) *checkServer {
+	return &checkServer{
+		feed:     &conditionalFeed{url: feedURL, file: releaseFeedFile},
+		criteria: criteria,
+		current:  current,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// latest returns the cached result, checking the feed again if it has expired.
+// A failed check is returned but not cached, so the next request tries again.
+func (s *checkServer) latest(ctx context.Context) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.now().Before(s.expires) {
+		return s.result
+	}
+
+	result := s.check(ctx)
+	if result.Error == "" {
+		s.result = result
+		s.expires = s.now().Add(s.ttl)
+	}
+
+	return result
+}
+
+// check fetches the feed and selects the latest file for s.criteria.
+func (s *checkServer) check(ctx context.Context) Result {
+	result := newResult()
+	result.CurrentVersion = s.current
+
+	releaseInfo, _, err := s.feed.Get(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	file, err := SelectFile(releaseInfo, s.criteria)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.LatestVersion = file.Version
+	result.UpdateAvailable = file.Version != s.current
+	result.File = &file
+
+	return result
+}
+
+// Handler returns the HTTP handler of s.
+func (s *checkServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /latest", func(w http.ResponseWriter, r *http.Request) {
+		result := s.latest(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		if result.Error != "" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+
+		result.WriteTo(w)
+	})
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+
+	return mux
+}
+
+// runServe implements the -serve mode, serving s on addr until interrupted, and returns the exit code.
+func runServe(addr string, s *checkServer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving on %s\n", addr)
+
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error serving: %v\n", err)
+		return ExitErrUsage
+	}
+
+	return 0
+}
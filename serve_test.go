@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckServer(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var feedRequests atomic.Int32
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feedRequests.Add(1)
+		io.WriteString(w, testFeedJSON)
+	}))
+	defer feed.Close()
+
+	clock := newFakeClock()
+
+	s := newCheckServer(feed.URL, SelectCriteria{OS: "linux", Arch: "amd64"}, "go1.21.4", time.Minute)
+	s.now = clock.Now
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	get := func() Result {
+		t.Helper()
+
+		resp, err := http.Get(server.URL + "/latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var result Result
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	// Concurrent requests share a single check of the feed.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := get()
+			if result.LatestVersion != "go1.21.5" || !result.UpdateAvailable {
+				t.Errorf("Unexpected result.\n Got: %+v\nWant: latest go1.21.5 with an update available", result)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := feedRequests.Load(); got != 1 {
+		t.Errorf("Unexpected feed requests within the TTL.\n Got: %d\nWant: 1", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	get()
+
+	if got := feedRequests.Load(); got != 2 {
+		t.Errorf("Unexpected feed requests after the TTL.\n Got: %d\nWant: 2", got)
+	}
+}
+
+func TestCheckServerFeedError(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusNotFound)
+	}))
+	defer feed.Close()
+
+	s := newCheckServer(feed.URL, SelectCriteria{OS: "linux", Arch: "amd64"}, "go1.21.4", time.Minute)
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, http.StatusBadGateway)
+	}
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Error == "" {
+		t.Errorf("Unexpected result.\n Got: %+v, %v\nWant: an error", result, err)
+	}
+}
+
+func TestCheckServerHealthz(t *testing.T) {
+	s := newCheckServer("https://example.invalid/", SelectCriteria{}, "go1.21.4", time.Minute)
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("Unexpected response.\n Got: %d %q\nWant: 200 \"ok\\n\"", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+var (
+	ErrBadSignature     = errors.New("bad signature")
+	ErrSignerNotAllowed = errors.New("signer not allowed")
+	ErrNotInManifest    = errors.New("file not in checksum manifest")
+	ErrManifestMismatch = errors.New("checksum manifest disagrees with feed")
+)
+
+// ChecksumManifest maps filenames to hex SHA256 checksums.
+type ChecksumManifest map[string]string
+
+// ParseChecksumManifest parses a manifest in sha256sum format, one "<hex>  <filename>" per line.
+// A "*" before the filename, marking binary mode, is ignored. Blank lines are skipped.
+func ParseChecksumManifest(data []byte) (ChecksumManifest, error) {
+	manifest := make(ChecksumManifest)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid manifest line %d: %q", line, text)
+		}
+
+		sum := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+			return nil, fmt.Errorf("invalid checksum on manifest line %d: %q", line, fields[0])
+		}
+
+		manifest[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+
+	return manifest, scanner.Err()
+}
+
+// readKeyring reads an armored or binary OpenPGP public keyring from path.
+func readKeyring(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// normalizeKeyID returns id in upper case with spaces and any 0x prefix removed.
+func normalizeKeyID(id string) string {
+	id = strings.ToUpper(strings.ReplaceAll(id, " ", ""))
+	return strings.TrimPrefix(id, "0X")
+}
+
+// verifyDetachedSignature checks that sig, armored or binary, is a valid signature over data
+// by a key in keyring. If allowedKeys is not empty, the signing key's fingerprint or 16 digit
+// key ID must also be listed in allowedKeys.
+func verifyDetachedSignature(keyring openpgp.EntityList, data, sig []byte, allowedKeys []string) error {
+	check := openpgp.CheckDetachedSignature
+	if bytes.Contains(sig, []byte("-----BEGIN PGP")) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+
+	signer, err := check(keyring, bytes.NewReader(data), bytes.NewReader(sig), nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+
+	if len(allowedKeys) == 0 {
+		return nil
+	}
+
+	fingerprint := strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint))
+	keyID := signer.PrimaryKey.KeyIdString()
+
+	for _, allowed := range allowedKeys {
+		allowed = normalizeKeyID(allowed)
+		if allowed == fingerprint || allowed == keyID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrSignerNotAllowed, fingerprint)
+}
+
+// ManifestOptions configures verification of a signed checksum manifest.
+type ManifestOptions struct {
+	URL          string   // URL of the sha256sum format manifest.
+	SignatureURL string   // URL of the detached signature. Defaults to URL + ".asc".
+	KeyringPath  string   // Path of the OpenPGP public keyring used to verify the signature.
+	AllowedKeys  []string // Fingerprints or key IDs allowed to sign. If empty, any key in the keyring is allowed.
+}
+
+// fetchVerifiedManifest downloads the manifest and its signature, verifies the signature,
+// and only then parses the manifest.
+func fetchVerifiedManifest(opts ManifestOptions) (ChecksumManifest, error) {
+	keyring, err := readKeyring(opts.KeyringPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sigURL := opts.SignatureURL
+	if sigURL == "" {
+		sigURL = opts.URL + ".asc"
+	}
+
+	data, err := fetchBytes(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checksum manifest: %w", err)
+	}
+
+	sig, err := fetchBytes(sigURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest signature: %w", err)
+	}
+
+	err = verifyDetachedSignature(keyring, data, sig, opts.AllowedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseChecksumManifest(data)
+}
+
+// downloadOptions returns opts with the manifest of m, fetched and verified by
+// fetchVerifiedManifest, so every file is checked against it before it is downloaded.
+// If m has no URL, opts is returned unchanged.
+func (m ManifestOptions) downloadOptions(opts DownloadOptions) (DownloadOptions, error) {
+	if m.URL == "" {
+		return opts, nil
+	}
+
+	manifest, err := fetchVerifiedManifest(m)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Manifest = manifest
+
+	return opts, nil
+}
+
+// checkManifest confirms the signed manifest lists file with the checksum given by the feed.
+func checkManifest(manifest ChecksumManifest, file ReleaseFile) error {
+	sum, ok := manifest[file.Filename]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotInManifest, file.Filename)
+	}
+
+	if sum != strings.ToLower(file.SHA256) {
+		return fmt.Errorf("%w: %s manifest %s feed %s", ErrManifestMismatch, file.Filename, sum, file.SHA256)
+	}
+
+	return nil
+}
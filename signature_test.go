@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// newTestEntity generates a fast EdDSA key for signing tests.
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com",
+		&packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatalf("cannot create key: %v", err)
+	}
+
+	return entity
+}
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	other := newTestEntity(t, "other")
+
+	manifest := []byte("85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a  testfile_1B\n")
+
+	sign := func(signer *openpgp.Entity, data []byte) []byte {
+		var sig bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(data), nil); err != nil {
+			t.Fatalf("cannot sign: %v", err)
+		}
+		return sig.Bytes()
+	}
+
+	testCases := []struct {
+		name          string
+		keyring       openpgp.EntityList
+		data          []byte
+		sig           []byte
+		allowedKeys   []string
+		expectedError error
+	}{
+		{
+			name:    "Good signature",
+			keyring: openpgp.EntityList{trusted},
+			data:    manifest,
+			sig:     sign(trusted, manifest),
+		},
+		{
+			name:        "Good signature by allowed fingerprint",
+			keyring:     openpgp.EntityList{trusted, other},
+			data:        manifest,
+			sig:         sign(trusted, manifest),
+			allowedKeys: []string{"0x" + trusted.PrimaryKey.KeyIdString()},
+		},
+		{
+			name:          "Tampered manifest",
+			keyring:       openpgp.EntityList{trusted},
+			data:          append([]byte("0"), manifest[1:]...),
+			sig:           sign(trusted, manifest),
+			expectedError: ErrBadSignature,
+		},
+		{
+			name:          "Signer not in keyring",
+			keyring:       openpgp.EntityList{trusted},
+			data:          manifest,
+			sig:           sign(other, manifest),
+			expectedError: ErrBadSignature,
+		},
+		{
+			name:          "Signer not allowed",
+			keyring:       openpgp.EntityList{trusted, other},
+			data:          manifest,
+			sig:           sign(other, manifest),
+			allowedKeys:   []string{trusted.PrimaryKey.KeyIdString()},
+			expectedError: ErrSignerNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyDetachedSignature(tc.keyring, tc.data, tc.sig, tc.allowedKeys)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	manifest, err := ParseChecksumManifest([]byte(
+		"85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a *testfile_1B\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	file := ReleaseFile{Filename: "testfile_1B", SHA256: "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"}
+	if err := checkManifest(manifest, file); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	file.SHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if err := checkManifest(manifest, file); !errors.Is(err, ErrManifestMismatch) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrManifestMismatch)
+	}
+
+	file.Filename = "other"
+	if err := checkManifest(manifest, file); !errors.Is(err, ErrNotInManifest) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrNotInManifest)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// checksumEntry is a line of a SHA256SUMS file.
+type checksumEntry struct {
+	Checksum string // Hex SHA256 checksum, or digest of another algorithm for other manifests.
+	Filename string // Base name of the file.
+}
+
+// checksumEntries returns the entries of the verified files in results.
+func checksumEntries(results Results) []checksumEntry {
+	var entries []checksumEntry
+
+	for _, r := range results {
+		if r.Err == nil {
+			entries = append(entries, checksumEntry{Checksum: r.Checksum, Filename: filepath.Base(r.Path)})
+		}
+	}
+
+	return entries
+}
+
+// resultDigest returns the hex algo digest of the file downloaded as r, from r.Digests if it
+// was computed during the download, or else by hashing the file, such as one found in the cache.
+func resultDigest(r DownloadResult, algo string) (string, error) {
+	if digest, ok := r.Digests[algo]; ok {
+		return digest, nil
+	}
+
+	digests, err := fileDigests(r.Path, algo)
+	if err != nil {
+		return "", err
+	}
+
+	return digests[algo], nil
+}
+
+// digestEntries returns the entries of the verified files in results with their algo digests.
+func digestEntries(results Results, algo string) ([]checksumEntry, error) {
+	var entries []checksumEntry
+
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		digest, err := resultDigest(r.DownloadResult, algo)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, checksumEntry{Checksum: digest, Filename: filepath.Base(r.Path)})
+	}
+
+	return entries, nil
+}
+
+// writeSHA256Sums writes entries to path in the format of sha256sum, one "<hex>  <filename>" line
+// per file, so the files can be checked with sha256sum -c. The lines are sorted by filename so the
+// same downloads always produce the same file. The file is written atomically.
+// Entries of another algorithm give the format of its tool, e.g. sha512sum.
+func writeSHA256Sums(path string, entries []checksumEntry) error {
+	entries = slices.Clone(entries)
+	slices.SortFunc(entries, func(a, b checksumEntry) int {
+		return strings.Compare(a.Filename, b.Filename)
+	})
+
+	var sb strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s  %s\n", e.Checksum, e.Filename)
+	}
+
+	return writeFileAtomic(path, []byte(sb.String()))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteSHA256Sums(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	info := ReleaseInfo{{
+		Version: "go1.21.5",
+		Stable:  true,
+		Files: []ReleaseFile{
+			{
+				Filename: "testfile_1MB", OS: "linux", Arch: "amd64", Kind: "archive", Size: 1 << 20,
+				SHA256: "a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e",
+			},
+			{
+				Filename: "testfile_1B", OS: "darwin", Arch: "arm64", Kind: "archive", Size: 1,
+				SHA256: "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a",
+			},
+		},
+	}}
+
+	outDir := t.TempDir()
+	opts := DownloadOptions{BaseURL: server.URL, OutputDir: outDir, Progress: ProgressNone}
+	targets := []Platform{{"linux", "amd64"}, {"darwin", "arm64"}, {"windows", "386"}}
+
+	results := downloadTargets(context.Background(), info, SelectCriteria{}, Platform{"linux", "amd64"}, targets, 2, opts)
+
+	path := filepath.Join(outDir, "SHA256SUMS")
+	if err := writeSHA256Sums(path, checksumEntries(results)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []string{
+		"85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a  testfile_1B",
+		"a7d95f3a178d5133ca7f918e98e880b00217b51a43c47f558568606d6dd7727e  testfile_1MB",
+	}
+
+	if len(lines) != len(want) {
+		t.Fatalf("Unexpected lines.\n Got: %q\nWant: %q", lines, want)
+	}
+
+	format := regexp.MustCompile(`^[0-9a-f]{64}  [^/\\]+$`)
+	for i, line := range lines {
+		if !format.MatchString(line) {
+			t.Errorf("Unexpected line format: %q", line)
+		}
+
+		if line != want[i] {
+			t.Errorf("Unexpected line.\n Got: %q\nWant: %q", line, want[i])
+		}
+	}
+
+	tmps, _ := filepath.Glob(filepath.Join(outDir, "SHA256SUMS.*.tmp"))
+	if len(tmps) != 0 {
+		t.Errorf("Unexpected temporary files: %v", tmps)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// supportedMinorLines is the number of minor release lines the Go team supports, the newest and the one before it.
+const supportedMinorLines = 2
+
+// SupportWindow describes whether a version is in the supported window of minor release lines.
+type SupportWindow struct {
+	Lines     []string // Supported minor lines, newest first, e.g. go1.22 and go1.21.
+	Version   string   // Version checked.
+	Supported bool     // Whether Version belongs to one of Lines.
+}
+
+// supportWindow determines the newest supported minor lines from the stable releases in info
+// and reports whether current is in one of them. Versions that are not releases, such as
+// development builds, are never supported.
+func supportWindow(info ReleaseInfo, current string) (SupportWindow, error) {
+	lines := minorLines(info)
+	if len(lines) == 0 {
+		return SupportWindow{}, fmt.Errorf("%w: no stable releases", ErrNoReleases)
+	}
+
+	if len(lines) > supportedMinorLines {
+		lines = lines[:supportedMinorLines]
+	}
+
+	window := SupportWindow{Version: current}
+
+	v, ok := parseGoVersion(current)
+
+	for _, line := range lines {
+		window.Lines = append(window.Lines, line.MinorLine())
+
+		if ok && v.MinorLine() == line.MinorLine() {
+			window.Supported = true
+		}
+	}
+
+	return window, nil
+}
+
+// isEOL reports whether version is a release of a minor line older than the supported
+// minor lines of the stable releases in info, meaning it no longer receives security fixes.
+// Versions that are not releases, such as development builds, are not reported as end-of-life.
+func isEOL(info ReleaseInfo, version string) bool {
+	v, ok := parseGoVersion(version)
+	if !ok {
+		return false
+	}
+
+	lines := minorLines(info)
+	if len(lines) == 0 {
+		return false
+	}
+
+	oldest := lines[len(lines)-1]
+	if len(lines) > supportedMinorLines {
+		oldest = lines[supportedMinorLines-1]
+	}
+
+	return v.Major < oldest.Major || (v.Major == oldest.Major && v.Minor < oldest.Minor)
+}
+
+// runSupportedWindow reports whether the current version is in the supported window of the feed at feedURL.
+// It returns ExitOutOfSupport if it is not.
+func runSupportedWindow(feedURL, current string) int {
+	releaseInfo, err := loadReleaseInfo(feedURL)
+	if err != nil {
+		fmt.Printf("Error gettting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	window, err := supportWindow(releaseInfo, current)
+	if err != nil {
+		fmt.Printf("Error finding supported versions: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	fmt.Printf("Supported minor versions: %s\n", strings.Join(window.Lines, ", "))
+
+	if !window.Supported {
+		fmt.Printf("%s is out of support.\n", window.Version)
+		return ExitOutOfSupport
+	}
+
+	fmt.Printf("%s is supported.\n", window.Version)
+
+	return 0
+}
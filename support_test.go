@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSupportWindow(t *testing.T) {
+	// Several minor lines, out of order, with an unstable release of an upcoming line.
+	info := ReleaseInfo{
+		{Version: "go1.23rc1", Stable: false},
+		{Version: "go1.22.0", Stable: true},
+		{Version: "go1.21.7", Stable: true},
+		{Version: "go1.21.6", Stable: true},
+		{Version: "go1.19.13", Stable: true},
+		{Version: "go1.20.14", Stable: true},
+	}
+
+	wantLines := []string{"go1.22", "go1.21"}
+
+	testCases := []struct {
+		current   string
+		supported bool
+	}{
+		{current: "go1.22.0", supported: true},
+		{current: "go1.21.1", supported: true},
+		{current: "go1.21", supported: true},
+		{current: "go1.20.14", supported: false},
+		{current: "go1.19.2", supported: false},
+		{current: "go1.23rc1", supported: false},
+		{current: "devel go1.23-a1b2c3d Tue Nov 7 18:34:56 2023 +0000", supported: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.current, func(t *testing.T) {
+			window, err := supportWindow(info, tc.current)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(window.Lines, wantLines) {
+				t.Errorf("Unexpected lines.\n Got: %v\nWant: %v", window.Lines, wantLines)
+			}
+
+			if window.Supported != tc.supported {
+				t.Errorf("Unexpected supported.\n Got: %v\nWant: %v", window.Supported, tc.supported)
+			}
+		})
+	}
+}
+
+func TestSupportWindowNoStableReleases(t *testing.T) {
+	_, err := supportWindow(ReleaseInfo{{Version: "go1.23rc1"}}, "go1.22.0")
+	if !errors.Is(err, ErrNoReleases) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrNoReleases)
+	}
+}
+
+func TestIsEOL(t *testing.T) {
+	info := ReleaseInfo{
+		{Version: "go1.23rc1", Stable: false},
+		{Version: "go1.22.1", Stable: true},
+		{Version: "go1.21.8", Stable: true},
+		{Version: "go1.20.14", Stable: true},
+	}
+
+	testCases := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "go1.22.1", expected: false},
+		{version: "go1.21.0", expected: false},
+		{version: "go1.20.14", expected: true},
+		{version: "go1.17", expected: true},
+		{version: "go1.23rc1", expected: false},
+		{version: "go1.24.0", expected: false},
+		{version: "devel go1.23-a1b2c3d", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			if got := isEOL(info, tc.version); got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsEOLSingleLine(t *testing.T) {
+	info := ReleaseInfo{{Version: "go1.22.1", Stable: true}}
+
+	if isEOL(info, "go1.22.0") {
+		t.Error("Expected go1.22.0 to be supported")
+	}
+
+	if !isEOL(info, "go1.21.8") {
+		t.Error("Expected go1.21.8 to be end-of-life")
+	}
+}
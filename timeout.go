@@ -0,0 +1,98 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStalled = errors.New("download stalled")
+
+// Default timeouts of the -connect-timeout, -header-timeout, and -stall-timeout flags.
+// There is no overall timeout, so a slow but steady download of a large file is never killed.
+const (
+	DefaultConnectTimeout = 30 * time.Second // Long enough for a slow DNS lookup and TLS handshake.
+	DefaultHeaderTimeout  = 30 * time.Second // The download servers answer in well under a second.
+	DefaultStallTimeout   = time.Minute      // Any progress at all restarts the stall timer.
+)
+
+// Timeouts bound the phases of a request. A zero value disables the timeout of that phase.
+type Timeouts struct {
+	Connect time.Duration // Establishing the connection, including DNS.
+	Header  time.Duration // Waiting for the response headers once the request is sent.
+}
+
+// timeoutTransport returns a copy of base that applies timeouts to each request.
+func timeoutTransport(base *http.Transport, timeouts Timeouts) *http.Transport {
+	t := base.Clone()
+
+	dialer := &net.Dialer{Timeout: timeouts.Connect, KeepAlive: 30 * time.Second}
+	t.DialContext = dialer.DialContext
+	t.ResponseHeaderTimeout = timeouts.Header
+
+	return t
+}
+
+// stallTimer is the part of *time.Timer used by stallWatchdog.
+type stallTimer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// timeAfterFunc is time.AfterFunc returning a stallTimer, the default timer of a stallWatchdog.
+func timeAfterFunc(d time.Duration, f func()) stallTimer {
+	return time.AfterFunc(d, f)
+}
+
+// stallWatchdog wraps a response body and calls cancel if no bytes are read for the
+// timeout, which aborts a download from a connection that has gone quiet.
+type stallWatchdog struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   stallTimer
+	stalled atomic.Bool
+}
+
+// newStallWatchdog starts watching r. cancel should cancel the context of the request.
+// afterFunc starts the timer, like time.AfterFunc, and defaults to it if nil.
+// Stop must be called when the body is no longer read.
+func newStallWatchdog(r io.Reader, timeout time.Duration, cancel func(), afterFunc func(time.Duration, func()) stallTimer) *stallWatchdog {
+	if afterFunc == nil {
+		afterFunc = timeAfterFunc
+	}
+
+	w := &stallWatchdog{r: r, timeout: timeout}
+
+	w.timer = afterFunc(timeout, func() {
+		w.stalled.Store(true)
+		cancel()
+	})
+
+	return w
+}
+
+// Read implements io.Reader and restarts the timer whenever bytes arrive.
+func (w *stallWatchdog) Read(p []byte) (int, error) {
+	n, err := w.r.Read(p)
+	if n > 0 {
+		w.timer.Reset(w.timeout)
+	}
+
+	if err != nil && err != io.EOF && w.stalled.Load() {
+		err = fmt.Errorf("%w: no data received for %s", ErrStalled, w.timeout)
+	}
+
+	return n, err
+}
+
+// Stop stops the timer.
+func (w *stallWatchdog) Stop() {
+	w.timer.Stop()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestTimeoutTransportHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		timeout   time.Duration
+		expectErr bool
+	}{
+		{name: "Headers too late", timeout: 50 * time.Millisecond, expectErr: true},
+		{name: "Headers in time", timeout: 5 * time.Second},
+		{name: "No limit", timeout: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			base := server.Client().Transport.(*http.Transport)
+			client := &http.Client{Transport: timeoutTransport(base, Timeouts{Connect: time.Second, Header: tc.timeout})}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStallWatchdog(t *testing.T) {
+	clock := newFakeClock()
+	var canceled bool
+
+	r := iotest.OneByteReader(io.MultiReader(strings.NewReader("ab"), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	w := newStallWatchdog(r, 10*time.Second, func() { canceled = true }, clock.AfterFunc)
+	defer w.Stop()
+
+	clock.Advance(9 * time.Second)
+	if canceled {
+		t.Fatal("Canceled before the timeout")
+	}
+
+	// A read restarts the timer, so the first deadline passes.
+	if _, err := w.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock.Advance(9 * time.Second)
+	if canceled {
+		t.Fatal("Canceled although bytes arrived")
+	}
+
+	clock.Advance(time.Second)
+	if !canceled {
+		t.Fatal("Not canceled after the timeout")
+	}
+
+	// Bytes already read are still returned, then the error says the download stalled.
+	if _, err := w.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := w.Read(make([]byte, 1))
+	if !errors.Is(err, ErrStalled) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrStalled)
+	}
+}
+
+func TestStallWatchdogStop(t *testing.T) {
+	clock := newFakeClock()
+	var canceled bool
+
+	w := newStallWatchdog(strings.NewReader(""), time.Second, func() { canceled = true }, clock.AfterFunc)
+	w.Stop()
+
+	clock.Advance(time.Hour)
+	if canceled {
+		t.Error("Canceled after Stop")
+	}
+}
+
+func TestDownloadStallTimeout(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	clock := newFakeClock()
+	watching := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+
+		// Stop sending without closing the connection, and let the timeout pass.
+		<-watching
+		clock.Advance(time.Minute)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "testfile")
+	opts := DownloadOptions{Progress: ProgressNone, StallTimeout: time.Minute}
+	opts.afterFunc = func(d time.Duration, f func()) stallTimer {
+		timer := clock.AfterFunc(d, f)
+		watching <- struct{}{}
+
+		return timer
+	}
+
+	_, _, err := DownloadFileWithProgressAndChecksum(server.URL, filePath, 100, sha256.New(), opts)
+	if !errors.Is(err, ErrStalled) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, ErrStalled)
+	}
+
+	if _, err := os.Stat(filePath); err == nil {
+		t.Error("Expected no file after a stalled download")
+	}
+}
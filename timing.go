@@ -0,0 +1,110 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Phases of a run timed by -timing.
+const (
+	PhaseFeed     = "feed"     // Fetching or reading the release feed.
+	PhaseSelect   = "select"   // Selecting the release file.
+	PhaseDownload = "download" // Downloading, including the streamed checksum verification.
+	PhaseVerify   = "verify"   // Further checks of the downloaded file, such as -deep-verify.
+)
+
+// PhaseTiming is the time spent in one phase of a run.
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// Timings are the phases of a run in the order they started, as included in the -json result.
+// Phases follow each other without gaps, so TotalSeconds is the sum of the phases.
+type Timings struct {
+	Phases       []PhaseTiming `json:"phases"`
+	TotalSeconds float64       `json:"total_seconds"`
+}
+
+// phaseTimer records how long each phase of a run takes.
+// Only one phase runs at a time, and starting a phase ends the current one.
+type phaseTimer struct {
+	now     func() time.Time // Clock used for the phases. Defaults to time.Now; replace in tests.
+	phases  []PhaseTiming
+	current int // Index in phases of the running phase, or -1 if none is running.
+	started time.Time
+}
+
+// newPhaseTimer returns a phaseTimer with no phase running.
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{now: time.Now, current: -1}
+}
+
+// Start ends the running phase, if any, and starts phase. The time of a phase that is started
+// more than once is added to its earlier time.
+func (t *phaseTimer) Start(phase string) {
+	t.Stop()
+
+	t.current = len(t.phases)
+	for i, p := range t.phases {
+		if p.Phase == phase {
+			t.current = i
+		}
+	}
+
+	if t.current == len(t.phases) {
+		t.phases = append(t.phases, PhaseTiming{Phase: phase})
+	}
+
+	t.started = t.now()
+}
+
+// Stop ends the running phase, if any.
+func (t *phaseTimer) Stop() {
+	if t.current < 0 {
+		return
+	}
+
+	t.phases[t.current].Duration += t.now().Sub(t.started)
+	t.current = -1
+}
+
+// Timings returns the time of the phases so far, not counting the running phase.
+func (t *phaseTimer) Timings() Timings {
+	timings := Timings{Phases: make([]PhaseTiming, len(t.phases))}
+
+	var total time.Duration
+	for i, p := range t.phases {
+		p.Seconds = p.Duration.Seconds()
+		timings.Phases[i] = p
+		total += p.Duration
+	}
+	timings.TotalSeconds = total.Seconds()
+
+	return timings
+}
+
+// printTimings writes timings to w as an aligned table of phase, duration, and percent of total.
+func printTimings(w io.Writer, timings Timings) {
+	var total time.Duration
+	for _, p := range timings.Phases {
+		total += p.Duration
+	}
+
+	fmt.Fprintf(w, "%-10s %12s %7s\n", "PHASE", "DURATION", "PERCENT")
+
+	for _, p := range timings.Phases {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(p.Duration) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%-10s %12s %6.1f%%\n", p.Phase, p.Duration.Round(time.Millisecond), percent)
+	}
+
+	fmt.Fprintf(w, "%-10s %12s %6.1f%%\n", "total", total.Round(time.Millisecond), 100.0)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	clock := newFakeClock()
+
+	timer := newPhaseTimer()
+	timer.now = clock.Now
+
+	timer.Start(PhaseFeed)
+	clock.Advance(time.Second)
+	timer.Start(PhaseSelect)
+	clock.Advance(500 * time.Millisecond)
+	timer.Start(PhaseDownload)
+	clock.Advance(6 * time.Second)
+	timer.Start(PhaseVerify)
+	clock.Advance(time.Second)
+	timer.Start(PhaseDownload)
+	clock.Advance(1500 * time.Millisecond)
+	timer.Stop()
+
+	// Time after Stop is not counted.
+	clock.Advance(time.Hour)
+
+	result := newResult()
+	timings := timer.Timings()
+	result.Timings = &timings
+
+	var buf bytes.Buffer
+	if _, err := result.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Timings *struct {
+			Phases []struct {
+				Phase   string  `json:"phase"`
+				Seconds float64 `json:"seconds"`
+			} `json:"phases"`
+			TotalSeconds float64 `json:"total_seconds"`
+		} `json:"timings"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Timings == nil {
+		t.Fatalf("Missing timings in %s", buf.String())
+	}
+
+	got := map[string]float64{}
+	var order []string
+	var sum float64
+	for _, p := range decoded.Timings.Phases {
+		got[p.Phase] = p.Seconds
+		order = append(order, p.Phase)
+		sum += p.Seconds
+	}
+
+	want := map[string]float64{PhaseFeed: 1, PhaseSelect: 0.5, PhaseDownload: 7.5, PhaseVerify: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected phases.\n Got: %v\nWant: %v", got, want)
+	}
+
+	wantOrder := []string{PhaseFeed, PhaseSelect, PhaseDownload, PhaseVerify}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("Unexpected order.\n Got: %v\nWant: %v", order, wantOrder)
+	}
+
+	if decoded.Timings.TotalSeconds != 10 || sum != decoded.Timings.TotalSeconds {
+		t.Errorf("Unexpected total.\n Got: %v, sum of phases %v\nWant: %v", decoded.Timings.TotalSeconds, sum, 10)
+	}
+}
+
+func TestPrintTimings(t *testing.T) {
+	timings := Timings{Phases: []PhaseTiming{
+		{Phase: PhaseFeed, Duration: 250 * time.Millisecond},
+		{Phase: PhaseDownload, Duration: 750 * time.Millisecond},
+	}}
+
+	var buf bytes.Buffer
+	printTimings(&buf, timings)
+
+	want := strings.Join([]string{
+		"PHASE          DURATION PERCENT",
+		"feed              250ms   25.0%",
+		"download          750ms   75.0%",
+		"total                1s  100.0%",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("Unexpected table.\n Got:\n%s\nWant:\n%s", buf.String(), want)
+	}
+}
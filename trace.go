@@ -0,0 +1,107 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// logger is the structured logger for diagnostics, written to stderr to keep stdout for progress.
+// It discards everything unless enabled by a flag.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// traceTransport is an http.RoundTripper that logs DNS, connect, TLS handshake,
+// time-to-first-byte, and total timings for each request once its body is closed.
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+// requestTimings collects the timings of a single request. The httptrace hooks may be
+// called from other goroutines, so access is guarded by mu.
+type requestTimings struct {
+	mu                            sync.Mutex
+	start                         time.Time
+	dnsStart, connStart, tlsStart time.Time
+	dns, connect, tls, ttfb       time.Duration
+}
+
+// clientTrace returns hooks that record timings into rt.
+func (rt *requestTimings) clientTrace() *httptrace.ClientTrace {
+	since := func(d *time.Duration, start *time.Time) {
+		rt.mu.Lock()
+		*d = time.Since(*start)
+		rt.mu.Unlock()
+	}
+	mark := func(start *time.Time) {
+		rt.mu.Lock()
+		*start = time.Now()
+		rt.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&rt.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { since(&rt.dns, &rt.dnsStart) },
+		ConnectStart:         func(_, _ string) { mark(&rt.connStart) },
+		ConnectDone:          func(_, _ string, _ error) { since(&rt.connect, &rt.connStart) },
+		TLSHandshakeStart:    func() { mark(&rt.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { since(&rt.tls, &rt.tlsStart) },
+		GotFirstResponseByte: func() { since(&rt.ttfb, &rt.start) },
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timings := &requestTimings{start: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace()))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		logger.Info("http request failed", "url", req.URL.Redacted(), "error", err)
+		return resp, err
+	}
+
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		done: func() {
+			timings.mu.Lock()
+			defer timings.mu.Unlock()
+
+			logger.Info("http request",
+				"url", req.URL.Redacted(),
+				"status", resp.StatusCode,
+				"dns", timings.dns,
+				"connect", timings.connect,
+				"tls", timings.tls,
+				"ttfb", timings.ttfb,
+				"total", time.Since(timings.start))
+		},
+	}
+
+	return resp, nil
+}
+
+// tracedBody calls done once when the body is closed.
+type tracedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+// Close closes the body and logs the request timings.
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// enableTrace logs request timings for all requests made with httpClient.
+func enableTrace() {
+	httpClient.Transport = &traceTransport{base: httpClient.Transport}
+}
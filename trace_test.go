@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceTransportLogsTimings(t *testing.T) {
+	var logs bytes.Buffer
+
+	old := logger
+	logger = slog.New(slog.NewTextHandler(&logs, nil))
+	t.Cleanup(func() { logger = old })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &traceTransport{base: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+
+	got := logs.String()
+	if strings.Count(got, "msg=\"http request\"") != 1 {
+		t.Errorf("Expected exactly one log line, got:\n%s", got)
+	}
+
+	for _, field := range []string{"status=200", "connect=", "ttfb=", "total="} {
+		if !strings.Contains(got, field) {
+			t.Errorf("Log missing %q:\n%s", field, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrNotInTransparencyLog = errors.New("not found in transparency log")
+	ErrTransparencyLog      = errors.New("transparency log query failed")
+)
+
+// DefaultTransparencyLogURL is the public Sigstore Rekor instance.
+const DefaultTransparencyLogURL = "https://rekor.sigstore.dev"
+
+// transparencyIndexPath is the Rekor search endpoint, relative to the log URL.
+//
+// The log is queried with a POST of {"hash":"sha256:<hex>"} and is expected to answer 200 OK
+// with a JSON array of the UUIDs of the entries whose artifact has that hash, which is empty
+// if there are none. This is the index API of Rekor, see
+// https://github.com/sigstore/rekor/blob/main/openapi.yaml.
+const transparencyIndexPath = "/api/v1/index/retrieve"
+
+// findTransparencyEntries returns the UUIDs of the entries in the transparency log at logURL
+// for the artifact with the hex SHA256 checksum sum. It returns ErrNotInTransparencyLog if there
+// are none, and ErrTransparencyLog if the log cannot be queried or its answer is not understood.
+func findTransparencyEntries(ctx context.Context, client *http.Client, logURL, sum string) ([]string, error) {
+	endpoint := strings.TrimSuffix(logURL, "/") + transparencyIndexPath
+
+	err := checkSecureURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(struct {
+		Hash string `json:"hash"`
+	}{Hash: "sha256:" + strings.ToLower(sum)})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTransparencyLog, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTransparencyLog, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %q %s", ErrTransparencyLog, endpoint, http.StatusText(resp.StatusCode))
+	}
+
+	var uuids []string
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&uuids)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTransparencyLog, err)
+	}
+
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("%w: sha256:%s", ErrNotInTransparencyLog, sum)
+	}
+
+	return uuids, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFindTransparencyEntries(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	const present = "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != transparencyIndexPath {
+			http.NotFound(w, r)
+			return
+		}
+
+		var query struct {
+			Hash string `json:"hash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch query.Hash {
+		case "sha256:" + present:
+			w.Write([]byte(`["24296fb24b8ad77a1"]`))
+		case "sha256:" + "00":
+			w.Write([]byte(`{"unexpected":true}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		logURL        string
+		sum           string
+		expectedUUID  string
+		expectedError error
+	}{
+		{name: "Present", logURL: server.URL, sum: present, expectedUUID: "24296fb24b8ad77a1"},
+		{name: "Present, trailing slash", logURL: server.URL + "/", sum: present, expectedUUID: "24296fb24b8ad77a1"},
+		{name: "Absent", logURL: server.URL, sum: "a7d95f3a", expectedError: ErrNotInTransparencyLog},
+		{name: "Unexpected answer", logURL: server.URL, sum: "00", expectedError: ErrTransparencyLog},
+		{name: "Wrong endpoint", logURL: server.URL + "/other", sum: present, expectedError: ErrTransparencyLog},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uuids, err := findTransparencyEntries(context.Background(), server.Client(), tc.logURL, tc.sum)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if tc.expectedUUID != "" && (len(uuids) != 1 || uuids[0] != tc.expectedUUID) {
+				t.Errorf("Unexpected entries.\n Got: %v\nWant: [%s]", uuids, tc.expectedUUID)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpClient is the shared client used for all feed and download requests.
+var httpClient = &http.Client{
+	Transport:     newRetryTransport(http.DefaultTransport),
+	CheckRedirect: checkSecureRedirect,
+}
+
+// RetryPolicy limits how often and for how long a request is retried.
+//
+// MaxAttempts and MaxElapsed are independent limits, and retries stop as soon as either is
+// reached. A zero MaxAttempts or MaxElapsed means no limit of that kind, so a policy with only
+// MaxElapsed keeps retrying until the time budget runs out, which suits a background service.
+// If both are zero, requests are not retried.
+//
+// MaxElapsed is measured from the start of the first attempt. A retry is not started if the wait
+// before it would end past the budget, so a long Retry-After gives up early rather than overrun.
+// Without a budget, a Retry-After longer than maxRetryAfter gives up early in the same way.
+//
+// Only a request up to its response headers is retried. An error while the body is read, such
+// as a reset connection or ErrStalled, fails the download; with DownloadOptions.Resume, the
+// bytes written so far are kept and the next run continues from them.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts, including the first.
+	MaxElapsed  time.Duration // Wall-clock budget for all attempts and waits.
+	BaseDelay   time.Duration // Backoff before the first retry.
+	MaxDelay    time.Duration // Upper bound on any single backoff.
+}
+
+// DefaultRetryPolicy is the policy of the shared client unless -retry-attempts or -retry-max-elapsed is set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// maxRetryAfter is the longest Retry-After that is waited for when the RetryPolicy has no
+// MaxElapsed budget, so a server asking for hours or days cannot stall a run.
+const maxRetryAfter = 5 * time.Minute
+
+// allows reports whether a retry may start after attempts attempts at elapsed since the first one.
+func (p RetryPolicy) allows(attempts int, elapsed time.Duration) bool {
+	if p.MaxAttempts <= 0 && p.MaxElapsed <= 0 {
+		return false
+	}
+
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return false
+	}
+
+	return p.MaxElapsed <= 0 || elapsed <= p.MaxElapsed
+}
+
+// retryTransport is an http.RoundTripper that retries idempotent requests on transient
+// failures using exponential backoff with jitter, within the limits of its RetryPolicy.
+// A Retry-After header on a 429 or 503 response takes precedence over the computed backoff,
+// and is honored in full or not at all: if it would overrun the MaxElapsed budget, or exceed
+// maxRetryAfter without one, the response is returned instead of retrying early. Retries stop early if the request context is done.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	now    func() time.Time
+	wait   func(ctx context.Context, d time.Duration) error
+}
+
+// newRetryTransport returns a retryTransport wrapping base with DefaultRetryPolicy.
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		base:   base,
+		policy: DefaultRetryPolicy,
+		now:    time.Now,
+		wait:   sleepContext,
+	}
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	start := t.now()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+
+		if !isTransient(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+
+		if resp != nil && honorsRetryAfter(resp.StatusCode) {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.now()); ok {
+				if t.policy.MaxElapsed <= 0 && retryAfter > maxRetryAfter {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+		}
+
+		if !t.policy.allows(attempt+1, t.now().Sub(start)+delay) {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain and close the body so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		err = t.wait(req.Context(), delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the delay before retry number attempt+1, chosen at random between
+// half and all of BaseDelay*2^attempt, and capped at MaxDelay.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	base, maxDelay := t.policy.BaseDelay, t.policy.MaxDelay
+
+	// Compare against maxDelay shifted right, as base shifted left can overflow.
+	delay := maxDelay
+	if attempt < 32 && base < maxDelay>>attempt {
+		delay = base << attempt
+	}
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// isIdempotent reports whether req can be safely retried.
+func isIdempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == "") &&
+		req.Body == nil
+}
+
+// isTransient reports whether a response or error is worth retrying: a 429 or 5xx gateway
+// status, a timeout, or a connection that was refused, reset, or closed early. Any other error,
+// such as a failed certificate verification, a pin mismatch, or a host that is not allowed,
+// is permanent and returned at once.
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return isTransientError(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// isTransientError reports whether err of a request is worth retrying, see isTransient.
+func isTransientError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// The server closed the connection before a complete response.
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	for _, errno := range connectionErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// honorsRetryAfter reports whether the Retry-After header of a response with status is used
+// as the delay before a retry. Servers use it with 429 to rate limit and with 503 for
+// maintenance, while on other statuses it is not meaningful.
+func honorsRetryAfter(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter parses a Retry-After header value given as either delay-seconds or an HTTP-date.
+// It returns false if the value is absent or invalid. Dates in the past yield a zero delay.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := date.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay, true
+}
+
+// fetchBytes gets rawURL with the shared client and returns the response body.
+func fetchBytes(rawURL string) ([]byte, error) {
+	err := checkSecureURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%q %s", rawURL, http.StatusText(resp.StatusCode))
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 11, 26, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		value         string
+		expectedDelay time.Duration
+		expectedOK    bool
+	}{
+		{name: "Empty", value: "", expectedOK: false},
+		{name: "Seconds", value: "120", expectedDelay: 2 * time.Minute, expectedOK: true},
+		{name: "Zero seconds", value: "0", expectedDelay: 0, expectedOK: true},
+		{name: "Negative seconds", value: "-1", expectedOK: false},
+		{name: "HTTP date", value: "Sun, 26 Nov 2023 00:00:30 GMT", expectedDelay: 30 * time.Second, expectedOK: true},
+		{name: "HTTP date in past", value: "Sat, 25 Nov 2023 00:00:00 GMT", expectedDelay: 0, expectedOK: true},
+		{name: "Garbage", value: "soon", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, ok := parseRetryAfter(tc.value, now)
+
+			if ok != tc.expectedOK {
+				t.Errorf("Unexpected ok.\n Got: %v\nWant: %v", ok, tc.expectedOK)
+			}
+
+			if delay != tc.expectedDelay {
+				t.Errorf("Unexpected delay.\n Got: %v\nWant: %v", delay, tc.expectedDelay)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBackoffBounds(t *testing.T) {
+	rt := newRetryTransport(http.DefaultTransport)
+	rt.policy.BaseDelay = 100 * time.Millisecond
+	rt.policy.MaxDelay = time.Second
+
+	for attempt := 0; attempt < 40; attempt++ {
+		want := rt.policy.MaxDelay
+		if attempt < 4 {
+			want = rt.policy.BaseDelay << attempt
+		}
+
+		for i := 0; i < 100; i++ {
+			delay := rt.backoff(attempt)
+			if delay < want/2 || delay > want {
+				t.Fatalf("Backoff out of bounds for attempt %d.\n Got: %v\nWant: [%v, %v]",
+					attempt, delay, want/2, want)
+			}
+		}
+	}
+}
+
+func TestRetryTransportRetriesTransient(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRetryTransport(http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if requests != 3 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: %d", requests, 3)
+	}
+}
+
+func TestRetryPolicyAllows(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   RetryPolicy
+		attempts int
+		elapsed  time.Duration
+		expected bool
+	}{
+		{name: "Attempts left", policy: RetryPolicy{MaxAttempts: 3}, attempts: 2, elapsed: time.Hour, expected: true},
+		{name: "Attempts used", policy: RetryPolicy{MaxAttempts: 3}, attempts: 3},
+		{name: "Within budget", policy: RetryPolicy{MaxElapsed: time.Minute}, attempts: 100, elapsed: time.Minute, expected: true},
+		{name: "Over budget", policy: RetryPolicy{MaxElapsed: time.Minute}, attempts: 1, elapsed: time.Minute + 1},
+		{name: "Both, attempts used", policy: RetryPolicy{MaxAttempts: 2, MaxElapsed: time.Minute}, attempts: 2},
+		{name: "Both, over budget", policy: RetryPolicy{MaxAttempts: 5, MaxElapsed: time.Minute}, attempts: 1, elapsed: 2 * time.Minute},
+		{name: "No limits", policy: RetryPolicy{}, attempts: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.allows(tc.attempts, tc.elapsed); got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRetryTransportMaxElapsed(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clock := newFakeClock()
+
+	rt := newRetryTransport(http.DefaultTransport)
+	rt.policy = RetryPolicy{MaxElapsed: 5 * time.Minute, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second}
+	rt.now = clock.Now
+	rt.wait = func(ctx context.Context, d time.Duration) error {
+		clock.Advance(d)
+		return nil
+	}
+
+	start := clock.Now()
+
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	// Each wait is between 5 and 10 seconds, so the budget allows between 31 and 61 attempts.
+	if requests < 31 || requests > 61 {
+		t.Errorf("Unexpected requests.\n Got: %d\nWant: between 31 and 61", requests)
+	}
+
+	if elapsed := clock.Now().Sub(start); elapsed > 5*time.Minute {
+		t.Errorf("Exceeded time budget.\n Got: %v\nWant: <= %v", elapsed, 5*time.Minute)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		retryAfter     string
+		policy         RetryPolicy
+		expectedStatus int
+		expectedWaits  []time.Duration
+	}{
+		{
+			name:           "429 seconds",
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "7",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{7 * time.Second},
+		},
+		{
+			name:           "429 date",
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "Sun, 26 Nov 2023 00:01:30 GMT",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{90 * time.Second},
+		},
+		{
+			name:           "503 seconds",
+			status:         http.StatusServiceUnavailable,
+			retryAfter:     "12",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{12 * time.Second},
+		},
+		{
+			name:           "500 ignores Retry-After",
+			status:         http.StatusInternalServerError,
+			retryAfter:     "60",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{time.Nanosecond},
+		},
+		{
+			name:           "Over budget",
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "120",
+			policy:         RetryPolicy{MaxElapsed: time.Minute, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "Over maximum without budget",
+			status:         http.StatusServiceUnavailable,
+			retryAfter:     "86400",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "Maximum without budget",
+			status:         http.StatusServiceUnavailable,
+			retryAfter:     "300",
+			policy:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{maxRetryAfter},
+		},
+		{
+			name:           "Long within budget",
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "3600",
+			policy:         RetryPolicy{MaxElapsed: 2 * time.Hour, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+			expectedStatus: http.StatusOK,
+			expectedWaits:  []time.Duration{time.Hour},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					w.Header().Set("Retry-After", tc.retryAfter)
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			clock := newFakeClock()
+
+			var waits []time.Duration
+
+			rt := newRetryTransport(http.DefaultTransport)
+			rt.policy = tc.policy
+			rt.now = clock.Now
+			rt.wait = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				clock.Advance(d)
+				return nil
+			}
+
+			resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Unexpected status.\n Got: %d\nWant: %d", resp.StatusCode, tc.expectedStatus)
+			}
+
+			if !slices.Equal(waits, tc.expectedWaits) {
+				t.Errorf("Unexpected waits.\n Got: %v\nWant: %v", waits, tc.expectedWaits)
+			}
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	// A request to a closed port is refused.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	_, refused := http.DefaultTransport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://"+ln.Addr().String(), nil))
+	if refused == nil {
+		t.Fatal("Expected connection to closed port to fail")
+	}
+
+	// A certificate that is not trusted fails verification.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, untrusted := http.DefaultTransport.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+	if untrusted == nil {
+		t.Fatal("Expected untrusted certificate to fail")
+	}
+
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", refused, true},
+		{"timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{"closed early", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"dns temporary", &net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+		{"dns not found", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"untrusted certificate", untrusted, false},
+		{"pin mismatch", &net.OpError{Op: "remote error", Err: fmt.Errorf("tls: %w", ErrPinMismatch)}, false},
+		{"host not allowed", fmt.Errorf("%w: example.com", ErrHostNotAllowed), false},
+		{"insecure url", fmt.Errorf("%w: http://example.com", ErrInsecureURL), false},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("other"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransient(nil, tc.err); got != tc.want {
+				t.Errorf("Unexpected result for %v.\n Got: %v\nWant: %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
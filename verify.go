@@ -0,0 +1,430 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum incorrect")
+	ErrSizeMismatch     = errors.New("file size incorrect")
+)
+
+// Size check modes for sizeCheck.
+const (
+	SizeCheckError = "error" // A size mismatch is an error. The default.
+	SizeCheckWarn  = "warn"  // A size mismatch is reported as a warning if the checksum matches.
+	SizeCheckOff   = "off"   // The size is ignored if the checksum matches.
+)
+
+// sizeCheck controls how checkChecksumAndSize treats a size mismatch. Set by the -size-check flag.
+var sizeCheck = SizeCheckError
+
+// checkChecksumAndSize compares a computed checksum and size against the values expected for file.
+// A checksum mismatch is always an error; a size mismatch is handled as set by sizeCheck.
+func checkChecksumAndSize(file ReleaseFile, size int64, checksum string) error {
+	_, err := compareChecksumAndSize(file, size, checksum, sizeCheck)
+	return err
+}
+
+// VerifyOptions controls VerifyFile.
+type VerifyOptions struct {
+	// SizeCheck is how a size mismatch is treated when the checksum matches: SizeCheckError,
+	// SizeCheckWarn, or SizeCheckOff. If empty, the -size-check setting is used.
+	SizeCheck string
+}
+
+// VerifyResult describes how a local file compares with a ReleaseFile.
+type VerifyResult struct {
+	OK               bool   // Whether the file verified, allowing for the size check mode.
+	Checksum         string // Hex SHA256 checksum of the file.
+	Size             int64  // Size of the file in bytes.
+	ChecksumMismatch bool   // Whether Checksum differs from the expected SHA256.
+	SizeMismatch     bool   // Whether Size differs from the expected size, even if only warned about.
+}
+
+// VerifyFile streams the file at localPath through SHA256 and compares the checksum and size
+// with those of file. A checksum mismatch returns ErrChecksumMismatch, and a size mismatch
+// returns ErrSizeMismatch unless opts.SizeCheck allows it. The result is filled in even
+// when an error is returned, except if the file cannot be read.
+func VerifyFile(file ReleaseFile, localPath string, opts VerifyOptions) (VerifyResult, error) {
+	size, checksum, err := hashFile(localPath, sha256.New())
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	mode := opts.SizeCheck
+	if mode == "" {
+		mode = sizeCheck
+	}
+
+	return compareChecksumAndSize(file, size, checksum, mode)
+}
+
+// compareChecksumAndSize compares checksum and size with those of file, treating a size
+// mismatch as set by mode.
+func compareChecksumAndSize(file ReleaseFile, size int64, checksum, mode string) (VerifyResult, error) {
+	result := VerifyResult{
+		Checksum:         checksum,
+		Size:             size,
+		ChecksumMismatch: file.SHA256 != checksum,
+		SizeMismatch:     file.Size != size,
+	}
+
+	if result.ChecksumMismatch {
+		return result, fmt.Errorf("%w: got %v want %v",
+			ErrChecksumMismatch, checksum, file.SHA256)
+	}
+
+	if result.SizeMismatch {
+		switch mode {
+		case SizeCheckOff:
+		case SizeCheckWarn:
+			warn(WarnSizeMismatch, "%s size is %d, not %d, but the checksum matches",
+				file.Filename, size, file.Size)
+		default:
+			return result, fmt.Errorf("%w: got %v want %v",
+				ErrSizeMismatch, size, file.Size)
+		}
+	}
+
+	result.OK = true
+
+	return result, nil
+}
+
+// VerifyLocalFile streams the file at path through SHA256 and verifies the checksum and size against file.
+func VerifyLocalFile(path string, file ReleaseFile) error {
+	_, err := VerifyFile(file, path, VerifyOptions{})
+	return err
+}
+
+// hashFile returns the size and hex checksum of the file at path using h.
+func hashFile(path string, h hash.Hash) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return size, fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Environment variables consulted when -expected-checksum or -expected-size is not set.
+const (
+	envExpectedSHA256 = "GO_DL_EXPECTED_SHA256"
+	envExpectedSize   = "GO_DL_EXPECTED_SIZE"
+)
+
+var (
+	ErrInvalidExpected       = errors.New("invalid expected value")
+	ErrUnknownHashAlgo       = errors.New("unknown hash algorithm")
+	ErrUnknownChecksumLength = errors.New("unrecognized checksum length")
+	ErrHashAlgoMismatch      = errors.New("hash algorithm does not match checksum length")
+)
+
+// hashAlgorithms maps the supported algorithm names to their implementations.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// multiHash is a hash.Hash that computes several hashes in one pass over the data.
+// Its Sum, Size, and BlockSize are those of the first hash.
+type multiHash struct {
+	io.Writer
+	names  []string
+	hashes []hash.Hash
+}
+
+// newMultiHash returns a multiHash of the algorithms in hashAlgorithms named by names,
+// with duplicates removed. It returns ErrUnknownHashAlgo for an unsupported name.
+func newMultiHash(names ...string) (*multiHash, error) {
+	m := &multiHash{}
+	var writers []io.Writer
+
+	for _, name := range names {
+		if slices.Contains(m.names, name) {
+			continue
+		}
+
+		newHash, ok := hashAlgorithms[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownHashAlgo, name)
+		}
+
+		h := newHash()
+		m.names = append(m.names, name)
+		m.hashes = append(m.hashes, h)
+		writers = append(writers, h)
+	}
+
+	if len(m.hashes) == 0 {
+		return nil, fmt.Errorf("%w: none given", ErrUnknownHashAlgo)
+	}
+
+	m.Writer = io.MultiWriter(writers...)
+
+	return m, nil
+}
+
+// Sum implements hash.Hash.
+func (m *multiHash) Sum(b []byte) []byte { return m.hashes[0].Sum(b) }
+
+// Reset implements hash.Hash.
+func (m *multiHash) Reset() {
+	for _, h := range m.hashes {
+		h.Reset()
+	}
+}
+
+// Size implements hash.Hash.
+func (m *multiHash) Size() int { return m.hashes[0].Size() }
+
+// BlockSize implements hash.Hash.
+func (m *multiHash) BlockSize() int { return m.hashes[0].BlockSize() }
+
+// Digests returns the hex digest of each algorithm, keyed by name.
+func (m *multiHash) Digests() map[string]string {
+	digests := make(map[string]string, len(m.hashes))
+
+	for i, h := range m.hashes {
+		digests[m.names[i]] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	return digests
+}
+
+// fileDigests returns the hex digests of the file at path for the algorithms named by names,
+// reading the file once.
+func fileDigests(path string, names ...string) (map[string]string, error) {
+	m, err := newMultiHash(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = hashFile(path, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Digests(), nil
+}
+
+// detectHashAlgo returns the hash algorithm implied by the length of a hex checksum:
+// 40 digits for sha1, 64 for sha256, and 128 for sha512. If algo is not empty, it must
+// name a supported algorithm that agrees with the inferred one.
+func detectHashAlgo(checksum, algo string) (string, error) {
+	if algo != "" {
+		if _, ok := hashAlgorithms[algo]; !ok {
+			return "", fmt.Errorf("%w: %q", ErrUnknownHashAlgo, algo)
+		}
+	}
+
+	var inferred string
+
+	switch len(checksum) {
+	case sha1.Size * 2:
+		inferred = "sha1"
+	case sha256.Size * 2:
+		inferred = "sha256"
+	case sha512.Size * 2:
+		inferred = "sha512"
+	default:
+		return "", fmt.Errorf("%w: %d hex digits", ErrUnknownChecksumLength, len(checksum))
+	}
+
+	if algo != "" && algo != inferred {
+		return "", fmt.Errorf("%w: %s given, %d hex digits is %s",
+			ErrHashAlgoMismatch, algo, len(checksum), inferred)
+	}
+
+	return inferred, nil
+}
+
+// Expected holds the values a local file is verified against in -verify-only mode.
+type Expected struct {
+	Checksum  string // Lowercase hex checksum.
+	Algorithm string // Hash algorithm of Checksum, e.g. "sha256".
+	Size      int64  // Size in bytes, or -1 if not checked.
+}
+
+// resolveExpected returns the expected checksum and size, taking each flag value if set
+// and otherwise the corresponding environment variable from getenv. A checksum is required;
+// the size is optional. Values from either source are validated the same way.
+// The hash algorithm is inferred from the checksum length and must agree with algo, if set.
+func resolveExpected(flagChecksum, flagSize, algo string, getenv func(string) string) (Expected, error) {
+	checksum, checksumSource := flagChecksum, "-expected-checksum"
+	if checksum == "" {
+		checksum, checksumSource = getenv(envExpectedSHA256), envExpectedSHA256
+	}
+
+	sizeText, sizeSource := flagSize, "-expected-size"
+	if sizeText == "" {
+		sizeText, sizeSource = getenv(envExpectedSize), envExpectedSize
+	}
+
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum == "" {
+		return Expected{}, fmt.Errorf("%w: no checksum given by -expected-checksum or %s",
+			ErrInvalidExpected, envExpectedSHA256)
+	}
+
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return Expected{}, fmt.Errorf("%w: %s is not a hex digest", ErrInvalidExpected, checksumSource)
+	}
+
+	algorithm, err := detectHashAlgo(checksum, algo)
+	if err != nil {
+		return Expected{}, fmt.Errorf("%w: %s: %w", ErrInvalidExpected, checksumSource, err)
+	}
+
+	expected := Expected{Checksum: checksum, Algorithm: algorithm, Size: -1}
+
+	if sizeText = strings.TrimSpace(sizeText); sizeText != "" {
+		size, err := strconv.ParseInt(sizeText, 10, 64)
+		if err != nil || size < 0 {
+			return Expected{}, fmt.Errorf("%w: %s is not a non-negative integer", ErrInvalidExpected, sizeSource)
+		}
+		expected.Size = size
+	}
+
+	return expected, nil
+}
+
+// verifyExpected verifies the file at path against expected.
+func verifyExpected(path string, expected Expected) error {
+	newHash, ok := hashAlgorithms[expected.Algorithm]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownHashAlgo, expected.Algorithm)
+	}
+
+	size, checksum, err := hashFile(path, newHash())
+	if err != nil {
+		return err
+	}
+
+	if checksum != expected.Checksum {
+		return fmt.Errorf("%w: got %v want %v", ErrChecksumMismatch, checksum, expected.Checksum)
+	}
+
+	if expected.Size >= 0 && size != expected.Size {
+		return fmt.Errorf("%w: got %v want %v", ErrSizeMismatch, size, expected.Size)
+	}
+
+	return nil
+}
+
+// runVerifyOnly implements the -verify-only mode and returns the exit code.
+func runVerifyOnly(path, flagChecksum, flagSize, algo string) int {
+	expected, err := resolveExpected(flagChecksum, flagSize, algo, os.Getenv)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitErrUsage
+	}
+
+	err = verifyExpected(path, expected)
+	if err != nil {
+		fmt.Printf("Verification failed: %v\n", err)
+		return ExitErrVerify
+	}
+
+	fmt.Printf("%s verified.\n", path)
+
+	return 0
+}
+
+// VerifyAllSummary counts the results of verifying a directory of archives.
+type VerifyAllSummary struct {
+	Passed  int
+	Failed  int
+	Unknown int
+}
+
+// verifyAll verifies each regular file in dir whose name matches a file in releaseInfo.
+// It writes one line per file to w and returns the aggregate counts.
+// Files not present in releaseInfo are reported as unknown.
+func verifyAll(w io.Writer, dir string, releaseInfo ReleaseInfo) (VerifyAllSummary, error) {
+	var summary VerifyAllSummary
+
+	known := make(map[string]ReleaseFile)
+	for _, release := range releaseInfo {
+		for _, file := range release.Files {
+			known[file.Filename] = file
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return summary, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		file, ok := known[entry.Name()]
+		if !ok {
+			fmt.Fprintf(w, "UNKNOWN %s\n", entry.Name())
+			summary.Unknown++
+			continue
+		}
+
+		err = VerifyLocalFile(filepath.Join(dir, entry.Name()), file)
+		if err != nil {
+			fmt.Fprintf(w, "FAIL    %s: %v\n", entry.Name(), err)
+			summary.Failed++
+			continue
+		}
+
+		fmt.Fprintf(w, "PASS    %s\n", entry.Name())
+		summary.Passed++
+	}
+
+	return summary, nil
+}
+
+// runVerifyAll implements the -verify-all mode and returns the exit code.
+func runVerifyAll(dir string, strict bool) int {
+	releaseInfo, err := loadReleaseInfo(allReleasesURL)
+	if err != nil {
+		fmt.Printf("Error getting release info: %v\n", err)
+		return ExitErrReleaseInfo
+	}
+
+	summary, err := verifyAll(os.Stdout, dir, releaseInfo)
+	if err != nil {
+		fmt.Printf("Error verifying files: %v\n", err)
+		return ExitErrVerify
+	}
+
+	fmt.Printf("%d passed, %d failed, %d unknown\n",
+		summary.Passed, summary.Failed, summary.Unknown)
+
+	if summary.Failed > 0 || (strict && summary.Unknown > 0) {
+		return ExitErrVerify
+	}
+
+	return 0
+}
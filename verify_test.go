@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyAll(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, data := range map[string]string{
+		"good.tar.gz":    "\x00",
+		"bad.tar.gz":     "\x01",
+		"unknown.tar.gz": "",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oneZeroByte := "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"
+	releaseInfo := ReleaseInfo{{
+		Version: "go1.21.0",
+		Files: []ReleaseFile{
+			{Filename: "good.tar.gz", SHA256: oneZeroByte, Size: 1},
+			{Filename: "bad.tar.gz", SHA256: oneZeroByte, Size: 1},
+		},
+	}}
+
+	var out bytes.Buffer
+
+	summary, err := verifyAll(&out, dir, releaseInfo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := VerifyAllSummary{Passed: 1, Failed: 1, Unknown: 1}
+	if summary != want {
+		t.Errorf("Unexpected summary.\n Got: %+v\nWant: %+v\nOutput:\n%s", summary, want, out.String())
+	}
+}
+
+// chdir changes the working directory for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+func TestDownloadSkippedIfValid(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	file := ReleaseFile{
+		Filename: "go0.0.0.test-amd64.tar.gz",
+		SHA256:   "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		Size:     1,
+	}
+
+	if err := os.WriteFile(file.Filename, []byte{0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file does not exist upstream, so any download attempt would fail.
+	if _, err := downloadAndVerifyFile(file, true, DownloadOptions{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestResolveExpected(t *testing.T) {
+	const sum = "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"
+	const otherSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	testCases := []struct {
+		name          string
+		flagChecksum  string
+		flagSize      string
+		env           map[string]string
+		expected      Expected
+		expectedError error
+	}{
+		{
+			name:         "Flags only",
+			flagChecksum: sum,
+			flagSize:     "1",
+			expected:     Expected{Checksum: sum, Algorithm: "sha256", Size: 1},
+		},
+		{
+			name:     "Env only",
+			env:      map[string]string{envExpectedSHA256: sum, envExpectedSize: "1"},
+			expected: Expected{Checksum: sum, Algorithm: "sha256", Size: 1},
+		},
+		{
+			name:         "Flags take precedence over env",
+			flagChecksum: sum,
+			flagSize:     "1",
+			env:          map[string]string{envExpectedSHA256: otherSum, envExpectedSize: "0"},
+			expected:     Expected{Checksum: sum, Algorithm: "sha256", Size: 1},
+		},
+		{
+			name:         "Flag checksum with env size",
+			flagChecksum: sum,
+			env:          map[string]string{envExpectedSize: "1"},
+			expected:     Expected{Checksum: sum, Algorithm: "sha256", Size: 1},
+		},
+		{
+			name:         "Size optional",
+			flagChecksum: sum,
+			expected:     Expected{Checksum: sum, Algorithm: "sha256", Size: -1},
+		},
+		{
+			name:          "No checksum",
+			expectedError: ErrInvalidExpected,
+		},
+		{
+			name:          "Malformed env checksum",
+			env:           map[string]string{envExpectedSHA256: "xyz"},
+			expectedError: ErrInvalidExpected,
+		},
+		{
+			name:          "Malformed env size",
+			env:           map[string]string{envExpectedSHA256: sum, envExpectedSize: "-5"},
+			expectedError: ErrInvalidExpected,
+		},
+		{
+			name:         "Valid flag overrides malformed env",
+			flagChecksum: sum,
+			flagSize:     "1",
+			env:          map[string]string{envExpectedSHA256: "xyz", envExpectedSize: "abc"},
+			expected:     Expected{Checksum: sum, Algorithm: "sha256", Size: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(key string) string { return tc.env[key] }
+
+			got, err := resolveExpected(tc.flagChecksum, tc.flagSize, "", getenv)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %+v\nWant: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDetectHashAlgo(t *testing.T) {
+	testCases := []struct {
+		name          string
+		checksum      string
+		algo          string
+		expected      string
+		expectedError error
+	}{
+		{name: "sha1", checksum: strings.Repeat("a", 40), expected: "sha1"},
+		{name: "sha256", checksum: strings.Repeat("a", 64), expected: "sha256"},
+		{name: "sha512", checksum: strings.Repeat("a", 128), expected: "sha512"},
+		{name: "Agreeing algo", checksum: strings.Repeat("a", 128), algo: "sha512", expected: "sha512"},
+		{name: "Disagreeing algo", checksum: strings.Repeat("a", 64), algo: "sha512", expectedError: ErrHashAlgoMismatch},
+		{name: "Unknown algo", checksum: strings.Repeat("a", 64), algo: "md5", expectedError: ErrUnknownHashAlgo},
+		{name: "Unrecognized length", checksum: strings.Repeat("a", 32), expectedError: ErrUnknownChecksumLength},
+		{name: "Empty", checksum: "", expectedError: ErrUnknownChecksumLength},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := detectHashAlgo(tc.checksum, tc.algo)
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Unexpected algorithm.\n Got: %q\nWant: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestVerifyExpectedSHA512(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte{0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := "b8244d028981d693af7b456af8efa4cad63d282e19ff14942c246e50d9351d22704a802a71c3580b6370de4ceb293c324a8423342557d4e5c38438f0e36910ee"
+
+	expected, err := resolveExpected(sum, "1", "", func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := verifyExpected(path, expected); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// setSizeCheck sets sizeCheck for the duration of the test.
+func setSizeCheck(t *testing.T, mode string) {
+	t.Helper()
+
+	prev := sizeCheck
+	sizeCheck = mode
+	t.Cleanup(func() { sizeCheck = prev })
+}
+
+func TestCheckChecksumAndSize(t *testing.T) {
+	file := ReleaseFile{Filename: "go.tar.gz", SHA256: "aaaa", Size: 10}
+
+	testCases := []struct {
+		name          string
+		mode          string
+		size          int64
+		checksum      string
+		expectedError error
+	}{
+		{name: "error match", mode: SizeCheckError, size: 10, checksum: "aaaa"},
+		{name: "error size mismatch", mode: SizeCheckError, size: 11, checksum: "aaaa", expectedError: ErrSizeMismatch},
+		{name: "error both mismatch", mode: SizeCheckError, size: 11, checksum: "bbbb", expectedError: ErrChecksumMismatch},
+		{name: "warn match", mode: SizeCheckWarn, size: 10, checksum: "aaaa"},
+		{name: "warn size mismatch", mode: SizeCheckWarn, size: 11, checksum: "aaaa"},
+		{name: "warn checksum mismatch", mode: SizeCheckWarn, size: 10, checksum: "bbbb", expectedError: ErrChecksumMismatch},
+		{name: "warn both mismatch", mode: SizeCheckWarn, size: 11, checksum: "bbbb", expectedError: ErrChecksumMismatch},
+		{name: "off size mismatch", mode: SizeCheckOff, size: 11, checksum: "aaaa"},
+		{name: "off both mismatch", mode: SizeCheckOff, size: 11, checksum: "bbbb", expectedError: ErrChecksumMismatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setSizeCheck(t, tc.mode)
+
+			err := checkChecksumAndSize(file, tc.size, tc.checksum)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	const sum = "85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a"
+	const otherSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	path := filepath.Join("testdata", "testfile_1B")
+
+	testCases := []struct {
+		name          string
+		file          ReleaseFile
+		sizeCheck     string
+		expected      VerifyResult
+		expectedError error
+	}{
+		{
+			name:     "Match",
+			file:     ReleaseFile{SHA256: sum, Size: 1},
+			expected: VerifyResult{OK: true, Checksum: sum, Size: 1},
+		},
+		{
+			name:          "Checksum mismatch",
+			file:          ReleaseFile{SHA256: otherSum, Size: 1},
+			expected:      VerifyResult{Checksum: sum, Size: 1, ChecksumMismatch: true},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:          "Size mismatch",
+			file:          ReleaseFile{SHA256: sum, Size: 2},
+			expected:      VerifyResult{Checksum: sum, Size: 1, SizeMismatch: true},
+			expectedError: ErrSizeMismatch,
+		},
+		{
+			name:          "Both mismatch",
+			file:          ReleaseFile{SHA256: otherSum, Size: 2},
+			expected:      VerifyResult{Checksum: sum, Size: 1, ChecksumMismatch: true, SizeMismatch: true},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:      "Size mismatch allowed",
+			file:      ReleaseFile{SHA256: sum, Size: 2},
+			sizeCheck: SizeCheckOff,
+			expected:  VerifyResult{OK: true, Checksum: sum, Size: 1, SizeMismatch: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := VerifyFile(tc.file, path, VerifyOptions{SizeCheck: tc.sizeCheck})
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %+v\nWant: %+v", got, tc.expected)
+			}
+		})
+	}
+
+	_, err := VerifyFile(ReleaseFile{SHA256: sum, Size: 1}, filepath.Join(t.TempDir(), "missing"), VerifyOptions{})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, os.ErrNotExist)
+	}
+}
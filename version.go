@@ -0,0 +1,243 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnofficialBuild = errors.New("not an official release build")
+	ErrPrerelease      = errors.New("refusing to install a pre-release")
+)
+
+var (
+	// releaseVersionPattern matches stable release versions, e.g. go1.21 or go1.21.5.
+	releaseVersionPattern = regexp.MustCompile(`^go1(\.(0|[1-9]\d*)){1,2}$`)
+
+	// prereleaseVersionPattern matches beta and release candidate versions, e.g. go1.22rc1.
+	prereleaseVersionPattern = regexp.MustCompile(`^go1(\.(0|[1-9]\d*)){1,2}(beta|rc)\d+$`)
+)
+
+// checkOfficialVersion returns ErrUnofficialBuild unless v, as returned by runtime.Version,
+// is a stable Go release. Development builds, such as "devel go1.22-abc123 ...", and beta
+// and release candidate versions are rejected, since updating from them to the latest stable
+// release may be an unintended downgrade.
+func checkOfficialVersion(v string) error {
+	switch {
+	case releaseVersionPattern.MatchString(v):
+		return nil
+	case strings.HasPrefix(v, "devel"):
+		return fmt.Errorf("%w: %q is a development build", ErrUnofficialBuild, v)
+	case prereleaseVersionPattern.MatchString(v):
+		return fmt.Errorf("%w: %q is a pre-release", ErrUnofficialBuild, v)
+	default:
+		return fmt.Errorf("%w: %q is not a release version", ErrUnofficialBuild, v)
+	}
+}
+
+// checkStableRelease returns ErrPrerelease if version is not a stable release in info, unless
+// allowPrerelease is set. Seeing pre-releases, with -unstable or -version, does not imply
+// installing them, so downloads and installs are guarded separately.
+func checkStableRelease(info ReleaseInfo, version string, allowPrerelease bool) error {
+	if allowPrerelease {
+		return nil
+	}
+
+	release, err := findRelease(info, version)
+	stable := err == nil && release.Stable
+
+	// Do not trust a feed that marks a beta or rc as stable.
+	if v, ok := parseGoVersion(version); ok && v.Prerelease != "" {
+		stable = false
+	}
+
+	if !stable {
+		return fmt.Errorf("%w: %s is not a stable release, use -allow-prerelease to install it", ErrPrerelease, version)
+	}
+
+	return nil
+}
+
+// goVersionPattern matches release, beta, and release candidate versions and captures
+// the major, minor, patch, and pre-release parts.
+var goVersionPattern = regexp.MustCompile(`^go(\d+)\.(\d+)(?:\.(\d+))?((?:beta|rc)\d+)?$`)
+
+// goVersion is a parsed Go release version such as go1.21.5 or go1.22rc1.
+type goVersion struct {
+	Major, Minor, Patch int
+	Prerelease          string // e.g. "rc1", or empty for a stable release.
+}
+
+// parseGoVersion parses v, reporting false if it is not a release version.
+func parseGoVersion(v string) (goVersion, bool) {
+	m := goVersionPattern.FindStringSubmatch(v)
+	if m == nil {
+		return goVersion{}, false
+	}
+
+	var gv goVersion
+	gv.Major, _ = strconv.Atoi(m[1])
+	gv.Minor, _ = strconv.Atoi(m[2])
+	gv.Patch, _ = strconv.Atoi(m[3]) // Zero if absent, as in go1.21.
+	gv.Prerelease = m[4]
+
+	return gv, true
+}
+
+// MinorLine returns the minor release line of the version, e.g. "go1.21".
+func (v goVersion) MinorLine() string {
+	return fmt.Sprintf("go%d.%d", v.Major, v.Minor)
+}
+
+var ErrInvalidMinorLine = errors.New("invalid minor line")
+
+// parseMinorLine parses a minor release line such as "go1.21" or "1.21".
+func parseMinorLine(s string) (goVersion, error) {
+	line := s
+	if !strings.HasPrefix(line, "go") {
+		line = "go" + line
+	}
+
+	v, ok := parseGoVersion(line)
+	if !ok || v.MinorLine() != line {
+		return goVersion{}, fmt.Errorf("%w: %q, want e.g. 1.21", ErrInvalidMinorLine, s)
+	}
+
+	return v, nil
+}
+
+// exceedsMinorLine reports whether version is a release of a minor line newer than line.
+// It reports false if version is not a release version.
+func exceedsMinorLine(version string, line goVersion) bool {
+	v, ok := parseGoVersion(version)
+	if !ok {
+		return false
+	}
+
+	return goVersion{Major: v.Major, Minor: v.Minor}.Compare(line) > 0
+}
+
+// minorLines returns the minor release lines of the stable releases in info, newest first.
+func minorLines(info ReleaseInfo) []goVersion {
+	seen := make(map[string]bool)
+	var lines []goVersion
+
+	for _, release := range info {
+		v, ok := parseGoVersion(release.Version)
+		if !ok || !release.Stable || seen[v.MinorLine()] {
+			continue
+		}
+
+		seen[v.MinorLine()] = true
+		lines = append(lines, goVersion{Major: v.Major, Minor: v.Minor})
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Major != lines[j].Major {
+			return lines[i].Major > lines[j].Major
+		}
+		return lines[i].Minor > lines[j].Minor
+	})
+
+	return lines
+}
+
+// prereleaseRank orders pre-releases before the release: beta, then rc, then the release itself.
+// It returns the rank and the pre-release number, e.g. 1 and 2 for rc2.
+func prereleaseRank(pre string) (int, int) {
+	for rank, prefix := range []string{"beta", "rc"} {
+		if n, ok := strings.CutPrefix(pre, prefix); ok {
+			num, _ := strconv.Atoi(n)
+			return rank, num
+		}
+	}
+
+	return 2, 0
+}
+
+// Compare returns -1, 0, or +1 depending on whether v is older than, the same as, or newer than w.
+func (v goVersion) Compare(w goVersion) int {
+	vRank, vNum := prereleaseRank(v.Prerelease)
+	wRank, wNum := prereleaseRank(w.Prerelease)
+
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch, vRank - wRank, vNum - wNum} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// CompareGoVersions is the default version comparator of SelectCriteria. It returns -1, 0, or +1
+// depending on whether a is older than, the same as, or newer than b. A version that cannot be
+// parsed is older than any release version, and two such versions compare as the same.
+func CompareGoVersions(a, b string) int {
+	va, aok := parseGoVersion(a)
+	vb, bok := parseGoVersion(b)
+
+	switch {
+	case aok && bok:
+		return va.Compare(vb)
+	case aok:
+		return 1
+	case bok:
+		return -1
+	}
+
+	return 0
+}
+
+var ErrUnknownVersion = errors.New("unknown version")
+
+// SummarizeUpgrade describes in one line how current compares with latest, e.g.
+//
+//	Go update available: go1.21.5 → go1.22.3 (1 minor, 0 patch behind)
+//
+// Patches behind are only counted within the same minor line, since a newer minor release
+// supersedes the patches of older lines. A development build, or a version newer than latest,
+// is described as ahead. It returns ErrUnknownVersion if either version cannot be parsed.
+func SummarizeUpgrade(current, latest string) (string, error) {
+	l, ok := parseGoVersion(latest)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownVersion, latest)
+	}
+
+	if strings.HasPrefix(current, "devel") {
+		return fmt.Sprintf("Go %s is a development build, ahead of %s", current, latest), nil
+	}
+
+	c, ok := parseGoVersion(current)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownVersion, current)
+	}
+
+	switch c.Compare(l) {
+	case 0:
+		return fmt.Sprintf("Go %s is up to date", current), nil
+	case 1:
+		return fmt.Sprintf("Go %s is ahead of the latest release %s", current, latest), nil
+	}
+
+	var behind string
+	switch {
+	case c.Major != l.Major:
+		behind = fmt.Sprintf("%d major", l.Major-c.Major)
+	case c.Minor != l.Minor:
+		behind = fmt.Sprintf("%d minor, 0 patch", l.Minor-c.Minor)
+	default:
+		behind = fmt.Sprintf("0 minor, %d patch", l.Patch-c.Patch)
+	}
+
+	return fmt.Sprintf("Go update available: %s → %s (%s behind)", current, latest, behind), nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckOfficialVersion(t *testing.T) {
+	testCases := []struct {
+		version       string
+		expectedError error
+	}{
+		{version: "go1.21.5", expectedError: nil},
+		{version: "go1.21", expectedError: nil},
+		{version: "go1.9.2", expectedError: nil},
+		{version: "go1.22rc1", expectedError: ErrUnofficialBuild},
+		{version: "go1.21beta2", expectedError: ErrUnofficialBuild},
+		{version: "devel go1.22-a1b2c3d Tue Nov 7 18:34:56 2023 +0000", expectedError: ErrUnofficialBuild},
+		{version: "devel +a1b2c3d", expectedError: ErrUnofficialBuild},
+		{version: "go1.21.5 X:boringcrypto", expectedError: ErrUnofficialBuild},
+		{version: "go1.21.05", expectedError: ErrUnofficialBuild},
+		{version: "", expectedError: ErrUnofficialBuild},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			err := checkOfficialVersion(tc.version)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestParseGoVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected goVersion
+		ok       bool
+	}{
+		{version: "go1.21.5", expected: goVersion{Major: 1, Minor: 21, Patch: 5}, ok: true},
+		{version: "go1.21", expected: goVersion{Major: 1, Minor: 21}, ok: true},
+		{version: "go1.22rc1", expected: goVersion{Major: 1, Minor: 22, Prerelease: "rc1"}, ok: true},
+		{version: "go1.21beta2", expected: goVersion{Major: 1, Minor: 21, Prerelease: "beta2"}, ok: true},
+		{version: "devel go1.22-a1b2c3d", ok: false},
+		{version: "1.21.5", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, ok := parseGoVersion(tc.version)
+
+			if got != tc.expected || ok != tc.ok {
+				t.Errorf("Unexpected result.\n Got: %+v, %v\nWant: %+v, %v", got, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestGoVersionCompare(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "go1.21.5", b: "go1.21.5", expected: 0},
+		{a: "go1.21", b: "go1.21.0", expected: 0},
+		{a: "go1.21.4", b: "go1.21.5", expected: -1},
+		{a: "go1.22.0", b: "go1.21.12", expected: 1},
+		{a: "go1.22rc1", b: "go1.22.0", expected: -1},
+		{a: "go1.22beta2", b: "go1.22rc1", expected: -1},
+		{a: "go1.22rc2", b: "go1.22rc1", expected: 1},
+		{a: "go1.22rc1", b: "go1.21.12", expected: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			a, _ := parseGoVersion(tc.a)
+			b, _ := parseGoVersion(tc.b)
+
+			if got := a.Compare(b); got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %d\nWant: %d", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSummarizeUpgrade(t *testing.T) {
+	testCases := []struct {
+		current, latest string
+		expected        string
+		expectedError   error
+	}{
+		{current: "go1.21.5", latest: "go1.22.3", expected: "Go update available: go1.21.5 → go1.22.3 (1 minor, 0 patch behind)"},
+		{current: "go1.21.4", latest: "go1.21.5", expected: "Go update available: go1.21.4 → go1.21.5 (0 minor, 1 patch behind)"},
+		{current: "go1.20", latest: "go1.22.1", expected: "Go update available: go1.20 → go1.22.1 (2 minor, 0 patch behind)"},
+		{current: "go1.22rc1", latest: "go1.22.0", expected: "Go update available: go1.22rc1 → go1.22.0 (0 minor, 0 patch behind)"},
+		{current: "go1.22.3", latest: "go1.22.3", expected: "Go go1.22.3 is up to date"},
+		{current: "go1.23rc1", latest: "go1.22.3", expected: "Go go1.23rc1 is ahead of the latest release go1.22.3"},
+		{
+			current:  "devel go1.23-abc123 Tue Jan 2 10:00:00 2024 +0000",
+			latest:   "go1.22.3",
+			expected: "Go devel go1.23-abc123 Tue Jan 2 10:00:00 2024 +0000 is a development build, ahead of go1.22.3",
+		},
+		{current: "unknown", latest: "go1.22.3", expectedError: ErrUnknownVersion},
+		{current: "go1.22.3", latest: "latest", expectedError: ErrUnknownVersion},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.current+" "+tc.latest, func(t *testing.T) {
+			got, err := SummarizeUpgrade(tc.current, tc.latest)
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+
+			if got != tc.expected {
+				t.Errorf("Unexpected summary.\n Got: %q\nWant: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCheckStableRelease(t *testing.T) {
+	// A feed that wrongly marks a release candidate as stable.
+	mislabeled := ReleaseInfo{{Version: "go1.23rc1", Stable: true}}
+
+	testCases := []struct {
+		name          string
+		info          ReleaseInfo
+		version       string
+		allow         bool
+		expectedError error
+	}{
+		{name: "Stable", info: testReleaseInfo, version: "go1.21.5"},
+		{name: "Older stable", info: testReleaseInfo, version: "go1.20.12"},
+		{name: "Release candidate", info: testReleaseInfo, version: "go1.22rc1", expectedError: ErrPrerelease},
+		{name: "Release candidate allowed", info: testReleaseInfo, version: "go1.22rc1", allow: true},
+		{name: "Mislabeled release candidate", info: mislabeled, version: "go1.23rc1", expectedError: ErrPrerelease},
+		{name: "Not in feed", info: testReleaseInfo, version: "go1.19", expectedError: ErrPrerelease},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkStableRelease(tc.info, tc.version, tc.allow)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Unexpected error.\n Got: %v\nWant: %v", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestExceedsMinorLine(t *testing.T) {
+	line, err := parseMinorLine("1.21")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "go1.21.0", expected: false},
+		{version: "go1.21.9", expected: false},
+		{version: "go1.20.14", expected: false},
+		{version: "go1.22rc1", expected: true},
+		{version: "go1.22.0", expected: true},
+		{version: "go2.0", expected: true},
+		{version: "devel go1.23-abc", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			if got := exceedsMinorLine(tc.version, line); got != tc.expected {
+				t.Errorf("Unexpected result.\n Got: %v\nWant: %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Codes of the warnings written by warn.
+const (
+	WarnSizeMismatch = "size_mismatch" // The size differs from the feed but the checksum matches.
+	WarnExcessData   = "excess_data"   // More bytes were received than the feed lists.
+	WarnEOL          = "eol"           // The selected version is end-of-life.
+	WarnMusl         = "musl"          // The system uses musl libc, which the official archives do not support.
+	WarnNetrc        = "netrc"         // The netrc file could not be read.
+	WarnCodesign     = "codesign"      // The code signature of the file cannot be verified on this system.
+	WarnNonAtomic    = "non_atomic"    // The destination filesystem does not rename files atomically.
+	WarnNoLock       = "no_lock"       // The install lock is not enforced on this system.
+)
+
+// Warning is a non-fatal problem, written as a single line of JSON in -json mode.
+type Warning struct {
+	Level   string `json:"level"` // Always "warning", to tell warnings apart from progress events.
+	Code    string `json:"code"`  // One of the Warn constants.
+	Message string `json:"message"`
+}
+
+var (
+	// warnOutput is where warn writes warnings.
+	warnOutput io.Writer = os.Stderr
+
+	// warnJSON makes warnings JSON Lines instead of prose. Set by the -json flag.
+	warnJSON bool
+)
+
+// warn writes a warning with code and a message formatted from format and args to warnOutput.
+func warn(code, format string, args ...any) {
+	writeWarning(warnOutput, code, fmt.Sprintf(format, args...))
+}
+
+// writeWarning writes a warning with code and msg to w, as a line of JSON if warnJSON is set
+// and as a "Warning: msg" line otherwise.
+func writeWarning(w io.Writer, code, msg string) {
+	if !warnJSON {
+		fmt.Fprintf(w, "Warning: %s\n", msg)
+		return
+	}
+
+	data, err := json.Marshal(Warning{Level: "warning", Code: code, Message: msg})
+	if err != nil {
+		return
+	}
+
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// captureWarnings sends warnings to a buffer in the given format for the duration of the test.
+func captureWarnings(t *testing.T, jsonl bool) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	prevOutput, prevJSON := warnOutput, warnJSON
+	warnOutput, warnJSON = &buf, jsonl
+	t.Cleanup(func() { warnOutput, warnJSON = prevOutput, prevJSON })
+
+	return &buf
+}
+
+func TestWarnJSONL(t *testing.T) {
+	stderr := captureWarnings(t, true)
+	setSizeCheck(t, SizeCheckWarn)
+
+	file := ReleaseFile{Filename: "go.tar.gz", SHA256: "aaaa", Size: 10}
+	if err := checkChecksumAndSize(file, 11, "aaaa"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	warn(WarnEOL, "%s is end-of-life", "go1.19.13")
+
+	var got []Warning
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		var w Warning
+		if err := json.Unmarshal(scanner.Bytes(), &w); err != nil {
+			t.Fatalf("Unexpected error parsing %q: %v", scanner.Text(), err)
+		}
+		got = append(got, w)
+	}
+
+	want := []Warning{
+		{Level: "warning", Code: WarnSizeMismatch, Message: "go.tar.gz size is 11, not 10, but the checksum matches"},
+		{Level: "warning", Code: WarnEOL, Message: "go1.19.13 is end-of-life"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Unexpected warnings.\n Got: %+v\nWant: %+v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Unexpected warning.\n Got: %+v\nWant: %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestWarnProse(t *testing.T) {
+	stderr := captureWarnings(t, false)
+
+	warn(WarnEOL, "%s is end-of-life", "go1.19.13")
+
+	want := "Warning: go1.19.13 is end-of-life\n"
+	if stderr.String() != want {
+		t.Errorf("Unexpected warning.\n Got: %q\nWant: %q", stderr.String(), want)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// conditionalFeed fetches the release feed with conditional requests,
+// reusing the previous result when the server reports it unchanged.
+// If file is set, the feed is read from it instead, and only read again once it is modified.
+type conditionalFeed struct {
+	url          string
+	file         string
+	etag         string
+	lastModified string
+	modTime      time.Time
+	releaseInfo  ReleaseInfo
+}
+
+// Get returns the current release info and whether it changed since the previous call.
+// If the feed cannot be fetched, it is read from the first of fallbackReleaseURLs that
+// succeeds, which is always reported as changed.
+func (f *conditionalFeed) Get(ctx context.Context) (ReleaseInfo, bool, error) {
+	if f.file != "" {
+		return f.readFile()
+	}
+
+	releaseInfo, changed, err := f.fetch(ctx)
+	if err != nil {
+		releaseInfo, err = fallbackReleaseInfo(err)
+		changed = err == nil
+	}
+
+	return releaseInfo, changed, err
+}
+
+// readFile reads the feed from f.file, reusing the previous result if the file is not modified.
+func (f *conditionalFeed) readFile() (ReleaseInfo, bool, error) {
+	fi, err := os.Stat(f.file)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get release info: %w", err)
+	}
+
+	if f.releaseInfo != nil && fi.ModTime().Equal(f.modTime) {
+		return f.releaseInfo, false, nil
+	}
+
+	releaseInfo, err := readReleaseInfoFile(f.file)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.modTime = fi.ModTime()
+	f.releaseInfo = releaseInfo
+
+	return releaseInfo, true, nil
+}
+
+// fetch fetches the feed from f.url with a conditional request.
+func (f *conditionalFeed) fetch(ctx context.Context) (ReleaseInfo, bool, error) {
+	err := checkSecureURL(f.url)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get release info: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get release info: %w", err)
+	}
+
+	if f.releaseInfo != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get release info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && f.releaseInfo != nil {
+		return f.releaseInfo, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to get release info: %q %s",
+			f.url, http.StatusText(resp.StatusCode))
+	}
+
+	releaseInfo, err := DecodeReleases(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	f.releaseInfo = releaseInfo
+
+	return releaseInfo, true, nil
+}
+
+// watcher periodically checks the feed and reports newly released versions.
+type watcher struct {
+	feed            *conditionalFeed
+	criteria        SelectCriteria
+	current         string // Version to compare against, normally runtime.Version().
+	download        bool   // Download newly detected versions.
+	allowPrerelease bool   // Download pre-releases too.
+	downloadOpts    DownloadOptions
+	manifest        ManifestOptions // Signed manifest to check a download against, if its URL is set.
+	events          *slog.Logger    // Destination for structured events.
+	notifier        *Notifier       // Optional webhook for detected updates.
+
+	notified   string // Latest version already reported.
+	downloaded string // Latest version already downloaded, if download is set.
+}
+
+// checkOnce fetches the feed and emits an event if a version other than the current one
+// is newly available. If download is set, the version is downloaded unless it already was,
+// so a failed download is tried again by the next check. It returns the file if it was newly
+// reported or downloaded.
+func (w *watcher) checkOnce(ctx context.Context) (*ReleaseFile, error) {
+	releaseInfo, _, err := w.feed.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := SelectFile(releaseInfo, w.criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Version == w.current {
+		return nil, nil
+	}
+
+	isNew := file.Version != w.notified
+	if isNew {
+		w.notified = file.Version
+		w.events.Info("update available",
+			"current", w.current,
+			"latest", file.Version,
+			"file", file.Filename)
+
+		if w.notifier != nil {
+			err = w.notifier.Notify(ctx, newUpdateNotification(w.current, file.Version))
+			if err != nil {
+				w.events.Warn("notification failed", "error", err)
+			}
+		}
+	}
+
+	if !w.download || file.Version == w.downloaded {
+		if isNew {
+			return &file, nil
+		}
+		return nil, nil
+	}
+
+	err = checkStableRelease(releaseInfo, file.Version, w.allowPrerelease)
+	if err != nil {
+		// The release stays refused, so only warn once.
+		if !isNew {
+			return nil, nil
+		}
+		w.events.Warn("download skipped", "version", file.Version, "error", err)
+		return &file, nil
+	}
+
+	// The manifest is fetched for each download, as a newly released file is only in a newer one.
+	opts, err := w.manifest.downloadOptions(w.downloadOpts)
+	if err != nil {
+		return &file, err
+	}
+
+	result, err := downloadAndVerifyFile(file, true, opts)
+	if err != nil {
+		return &file, err
+	}
+	w.downloaded = file.Version
+	w.events.Info("downloaded", "version", file.Version, "path", result.Path)
+
+	return &file, nil
+}
+
+// run checks the feed every interval until ctx is done. Failures are logged and
+// the next check proceeds as scheduled.
+func (w *watcher) run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := w.checkOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			w.events.Warn("check failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
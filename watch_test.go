@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFeedJSON = `[{"version":"go1.21.5","stable":true,"files":[
+{"filename":"go1.21.5.linux-amd64.tar.gz","os":"linux","arch":"amd64","version":"go1.21.5",
+"sha256":"e2bc0b3e4b64111ec117295c088bde5f00eeed1567999ff77bc859d7df70078e","size":66618285,"kind":"archive"}]}]`
+
+func TestWatcherCheckOnce(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	var requests, notModified int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, testFeedJSON)
+	}))
+	defer server.Close()
+
+	var events bytes.Buffer
+
+	w := &watcher{
+		feed:     &conditionalFeed{url: server.URL},
+		criteria: SelectCriteria{OS: "linux", Arch: "amd64"},
+		current:  "go1.21.4",
+		events:   slog.New(slog.NewTextHandler(&events, nil)),
+	}
+
+	file, err := w.checkOnce(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file == nil || file.Version != "go1.21.5" {
+		t.Fatalf("Expected update to go1.21.5, got %+v", file)
+	}
+
+	// A second check sees the same version and must not report it again.
+	file, err = w.checkOnce(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file != nil {
+		t.Errorf("Unexpected repeated update: %+v", file)
+	}
+
+	if requests != 2 || notModified != 1 {
+		t.Errorf("Unexpected requests.\n Got: %d (%d not modified)\nWant: 2 (1 not modified)", requests, notModified)
+	}
+
+	if n := strings.Count(events.String(), "update available"); n != 1 {
+		t.Errorf("Unexpected events.\n Got: %d\nWant: 1\n%s", n, events.String())
+	}
+}
+
+func TestConditionalFeedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.json")
+	if err := os.WriteFile(path, []byte(testFeedJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := &conditionalFeed{url: "https://invalid.example/dl/?mode=json", file: path}
+
+	testCases := []struct {
+		name    string
+		modTime time.Time
+		changed bool
+	}{
+		{"first", time.Time{}, true},
+		{"unmodified", time.Time{}, false},
+		{"modified", time.Now().Add(time.Hour), true},
+	}
+
+	for _, tc := range testCases {
+		if !tc.modTime.IsZero() {
+			if err := os.Chtimes(path, tc.modTime, tc.modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		info, changed, err := feed.Get(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", tc.name, err)
+		}
+		if len(info) != 1 || changed != tc.changed {
+			t.Errorf("Unexpected result of %s read.\n Got: %d releases, changed %v\nWant: 1 release, changed %v",
+				tc.name, len(info), changed, tc.changed)
+		}
+	}
+}
+
+func TestConditionalFeedFallback(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testFeedJSON)
+	}))
+	defer mirror.Close()
+
+	prev := fallbackReleaseURLs
+	fallbackReleaseURLs = []string{mirror.URL}
+	t.Cleanup(func() { fallbackReleaseURLs = prev })
+
+	feed := &conditionalFeed{url: primary.URL}
+
+	info, changed, err := feed.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(info) != 1 || info[0].Version != "go1.21.5" || !changed {
+		t.Errorf("Unexpected result.\n Got: %+v, changed %v\nWant: go1.21.5 from the mirror, changed true", info, changed)
+	}
+}
+
+func TestWatcherRetriesFailedDownload(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	const feed = `[{"version":"go1.22.3","stable":true,"files":[
+{"filename":"testfile_1B","os":"linux","arch":"amd64","version":"go1.22.3",
+"sha256":"85f97e04d754c81dac21f0ce857adc81170d08c6cfef7cf90edbbabf39d9671a","size":1,"kind":"archive"}]}]`
+
+	var downloads int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, feed)
+	})
+	mux.HandleFunc("/dl/", func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		if downloads == 1 {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "testfile_1B"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var events bytes.Buffer
+
+	w := &watcher{
+		feed:         &conditionalFeed{url: server.URL + "/feed"},
+		criteria:     SelectCriteria{OS: "linux", Arch: "amd64"},
+		current:      "go1.22.2",
+		download:     true,
+		downloadOpts: DownloadOptions{OutputDir: t.TempDir(), BaseURL: server.URL + "/dl", Progress: ProgressNone},
+		events:       slog.New(slog.NewTextHandler(&events, nil)),
+	}
+
+	// The first download fails, and the next check downloads again without reporting the version again.
+	if _, err := w.checkOnce(context.Background()); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+
+	file, err := w.checkOnce(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file == nil || file.Version != "go1.22.3" {
+		t.Fatalf("Expected download of go1.22.3, got %+v", file)
+	}
+
+	// Once downloaded, the version is left alone.
+	file, err = w.checkOnce(context.Background())
+	if err != nil || file != nil {
+		t.Errorf("Unexpected repeated download: %+v, %v", file, err)
+	}
+
+	if downloads != 2 {
+		t.Errorf("Unexpected downloads.\n Got: %d\nWant: %d", downloads, 2)
+	}
+
+	if n := strings.Count(events.String(), "update available"); n != 1 {
+		t.Errorf("Unexpected events.\n Got: %d\nWant: 1\n%s", n, events.String())
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright 2023 Bill Nixon. All rights reserved.
+// Use of this source code is governed by the license found in the LICENSE file.
+
+package main
+
+// isCrossDevice reports false; plan9 has no distinct cross-device rename error.
+func isCrossDevice(err error) bool {
+	return false
+}
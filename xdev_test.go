@@ -0,0 +1,76 @@
+//go:build !plan9
+
+package main
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestDownloadTempDirCrossDevice(t *testing.T) {
+	setAllowInsecure(t, true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0})
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	// Pretend tempDir is on another filesystem than outputDir.
+	var crossed bool
+	rename = func(oldpath, newpath string) error {
+		if filepath.Dir(oldpath) != filepath.Dir(newpath) {
+			crossed = true
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	t.Cleanup(func() { rename = os.Rename })
+
+	filePath := filepath.Join(outputDir, "testfile")
+	opts := DownloadOptions{TempDir: tempDir, FileMode: 0o600, Progress: ProgressNone}
+
+	_, checksum, err := DownloadFileWithProgressAndChecksum(server.URL, filePath, 1, sha256.New(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !crossed {
+		t.Error("Expected the download to be moved across filesystems")
+	}
+
+	want := "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"
+	if checksum != want {
+		t.Errorf("Unexpected checksum.\n Got: %s\nWant: %s", checksum, want)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("Unexpected mode.\n Got: %v\nWant: %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	for _, dir := range []string{tempDir, outputDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range entries {
+			if entry.Name() != "testfile" {
+				t.Errorf("Unexpected file %q left in %s", entry.Name(), dir)
+			}
+		}
+	}
+}